@@ -1,13 +1,42 @@
 /*
   Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
 
-How-to run: 
-  go run dining_philosophers_chandy-misra.go 2>&1 |tee /tmp/tmp.log
+How-to run:
+  go run dining_philosophers-chandy-misra.go 2>&1 |tee /tmp/tmp.log
+or, as a starvation-freedom test with 128 philosophers eating 20 times each,
+the program exits with 1 if one of them did not:
+  go run dining_philosophers-chandy-misra.go -nodes=128 -nbIterations=20 -think=5ms -cs=5ms -timeout=2m
+go test runs it with 128 philosophers eating 5 times each.
 
 Parameters:
-- Number of nodes is set with NB_NODES global variable
-- Number of iterations is hardcoded in main function
-*/ 
+- -nodes: number of philosophers, at least 2, NB_NODES global variable
+- -nbIterations: number of meals of each philosopher, NB_ITERATIONS global variable
+- -think: a philosopher thinks between 0 and this duration before being hungry
+- -cs: duration of a meal
+- -timeout: the philosophers that did not eat all their meals after this
+  duration starve, the run fails
+- -seed: seed of the think times, based on the time if 0
+- -v: log the messages
+
+The philosophers are on a ring: fork #k is shared by philosopher #k and
+philosopher #k+1, philosopher #i eats with its left fork #i-1 and its right
+fork #i, modulo the number of philosophers. Other topologies are handled by
+the ChandyMisra package of the drinking philosophers, see its -topology flag.
+
+Each fork has a request token, held by the neighbor that does not hold the
+fork when none asked for it. Initially fork #k is dirty, in the hand of the
+neighbor with the lowest id, the token in the other one's, the precedence
+graph is acyclic.
+* a hungry philosopher sends the token of its missing forks
+* a philosopher holding a fork and its token sends the fork if it is dirty
+  and it is not eating, the fork is cleaned when sent. If it is hungry, it
+  asks for the fork back at once
+* a hungry philosopher holding its two forks eats, its forks become dirty
+* after eating, it sends the forks that were asked for
+Neighbors never eat at the same time, the run ends on a Fatal otherwise.
+A philosopher that ate all its meals keeps answering the requests of its
+neighbors.
+*/
 
 /*
     Go implementation of Chandy-Misra mutual exclusion algorithm
@@ -22,294 +51,298 @@ Complexity is O(Log(n))
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"math/rand"
+	"os"
 	"sync"
-	"strings"
-	"strconv"
 	"time"
 )
 
 /* global variable declaration */
-var NB_NODES int          = 4
+var NB_NODES int          = 5
 var NB_ITERATIONS int     = 10
-var CURRENT_ITERATION int = 0
+var THINK_TIME            = 100 * time.Millisecond
+var CS_TIME               = 500 * time.Millisecond
+var VERBOSE bool          = false
+
 var STATE_THINKING int    = 0
+var STATE_HUNGRY int      = 1
 var STATE_EATING int      = 2
 
-/*
-// Debug function
-func displayNodes() {
-	for i := 0; i < len(nodes); i++ {
-		log.Print("Node #", nodes[i].id, ", last=", nodes[i].last, ", next=", nodes[i].next)
-	}
+var STATE_NAMES = []string{"thinking", "hungry", "eating"}
+
+// Message types
+var REQUEST_FORK int      = 0
+var SEND_FORK int         = 1
+
+var MESSAGE_NAMES = []string{"REQUEST_FORK", "SEND_FORK"}
+
+// A philosopher has at most one fork and one request token in flight for each
+// of its two forks, so that sends never block
+var INBOX_SIZE int        = 4
+
+// eating is the philosophers eating, to check mutual exclusion
+var eating []bool
+var eatingMutex sync.Mutex
+
+type Message struct {
+	MessageType int
+	From        int
+	ForkId      int
 }
-*/
-type Request struct {
-	philosopherId int
-	forkId        int
+
+type Fork struct {
+	id     int
+	held   bool
+	clean  bool
+	token  bool // the request token of the fork
 }
 
 type Philosopher struct {
-	id           int
-	firstForkId  int
-	secondForkId int
-	firstForkClean  bool
-	secondForkClean bool
-	firstForkStatus  bool
-	secondForkStatus bool
-	firstForkRequested  bool
-	secondForkRequested bool
-	state        int
-	nbCS         int
-	queue      []Request
-	channel    chan string
-	messages   []chan string
+	id         int
+	neighbor   [2]int // left and right
+	fork       [2]Fork // left and right
+	state      int
+	mutex      sync.Mutex // protects nbCS and maxWait, read by run on a timeout
+	nbCS       int
+	maxWait    time.Duration // longest time the philosopher was hungry
+	hungrySince time.Time
+	rand       *rand.Rand
+	inbox      chan Message
+	inboxes    []chan Message
+	done       *sync.WaitGroup
 }
 
 func (p *Philosopher) String() string {
 	var val string
-	val = fmt.Sprintf("Philosopher #%d, state=%d, first fork=%d/%v/%v, second fork=%d/%v/%v \n",
+	val = fmt.Sprintf("Philosopher #%d, state=%s, left fork=%d/%v/%v, right fork=%d/%v/%v",
 		p.id,
-		p.state,
-		p.firstForkId,
-		p.firstForkClean,
-		p.firstForkStatus,
-		p.secondForkId,
-		p.secondForkClean,
-		p.secondForkStatus)
+		STATE_NAMES[p.state],
+		p.fork[0].id,
+		p.fork[0].held,
+		p.fork[0].clean,
+		p.fork[1].id,
+		p.fork[1].held,
+		p.fork[1].clean)
 	return val
 }
 
-func (p *Philosopher) enterCS() {
-	log.Print("Philosopher #", p.id, " ######################### enterCS")
-	p.state = STATE_EATING
-	p.nbCS ++
-	CURRENT_ITERATION ++
-	// log.Print(p)
-	time.Sleep(500 * time.Millisecond)
+func (p *Philosopher) send(side int, messageType int) {
+	var m Message = Message{messageType, p.id, p.fork[side].id}
+	if VERBOSE {
+		log.Print("Philosopher #", p.id, " sends ", MESSAGE_NAMES[messageType], " for fork #", m.ForkId, " to Philosopher #", p.neighbor[side])
+	}
+	p.inboxes[p.neighbor[side]] <- m
 }
 
-func (p *Philosopher) releaseCS() {
-	log.Print("Philosopher #", p.id," releaseCS #########################")	
-	p.state = STATE_THINKING
-	p.firstForkClean  = false
-	p.secondForkClean  = false
-	// log.Print(p)
+// side returns the side of the fork, with 2 philosophers both forks are shared with the same neighbor
+func (p *Philosopher) side(forkId int) int {
+	if p.fork[0].id == forkId {
+		return 0
+	}
+	if p.fork[1].id == forkId {
+		return 1
+	}
+	log.Fatal("Philosopher #", p.id, " does not share fork #", forkId)
+	return -1
 }
 
-func (p *Philosopher) requestFork(philosopherId int, forkId int) {
-	for i := 0; i < len(p.messages); i++ {
-		if i == philosopherId {
-			var content = fmt.Sprintf("REQ%d%d", p.id, forkId)
-			// log.Print(p)
-			log.Print("Philosopher #", p.id, ", SENDING request ", content, " for fork #", forkId, " to Philosopher #", philosopherId)	
-			p.messages[i] <- content
+func (p *Philosopher) requestForks() {
+	for side := 0; side < 2; side++ {
+		if p.state == STATE_HUNGRY && !p.fork[side].held && p.fork[side].token {
+			p.fork[side].token = false
+			p.send(side, REQUEST_FORK)
 		}
 	}
 }
 
-func (p *Philosopher) sendFork(philosopherId int, forkId int) {
-	for i := 0; i < len(p.messages); i++ {
-		if i == philosopherId {
-			var content = fmt.Sprintf("REP%d%d", p.id, forkId)
-			log.Print("Philosopher #", p.id, ", SENDING reply ", content, " with fork #", forkId, " to Philosopher #", philosopherId)	
-			p.messages[i] <- content
+func (p *Philosopher) sendForks() {
+	for side := 0; side < 2; side++ {
+		var f *Fork = &p.fork[side]
+		if p.state != STATE_EATING && f.held && f.token && !f.clean {
+			f.held = false
+			p.send(side, SEND_FORK)
 		}
 	}
+	p.requestForks()
 }
-func (p *Philosopher) waitForReplies() {	
-	// log.Print("Philosopher #", p.id," waitForReplies")	
-	for {
-		select {
-		case msg := <-p.messages[p.id]:
-			if (strings.Contains(msg, "REQ")) {
-				var requester, err = strconv.Atoi(msg[3:4])
-				if err != nil {
-					log.Fatal(err)
-				}
-				var forkId, err2 = strconv.Atoi(msg[4:])
-				if err2 != nil {
-					log.Fatal(err2)
-				}
-				// Only send fork if we have it and it is clean,
-				// otherwise store the request to send it after leaving CS
-				if (forkId == p.firstForkId) {
-					if(p.firstForkClean == true) {
-						// keep the fork
-						log.Print("Philosopher #", p.id,", fork1 #", forkId, " is clean, I keep it for now")
-						var r Request
-						r.philosopherId = requester
-						r.forkId = forkId
-						p.queue = append(p.queue, r)
-
-					} else {
-						p.firstForkStatus = false
-						p.firstForkRequested = false
-						p.sendFork(requester, forkId)
-					}
-				} else {
-					if(p.secondForkClean == true) {
-						// keep the fork
-						log.Print("Philosopher #", p.id,", fork2 #", forkId, " is clean, I keep it for now")
-						var r Request
-						r.philosopherId = requester
-						r.forkId = forkId
-						p.queue = append(p.queue, r)
-					} else {
-						p.secondForkStatus = false
-						p.secondForkRequested = false
-						p.sendFork(requester, forkId)
-					}
-				}
-			}  else if (strings.Contains(msg, "REP")) {
-				var sender, err = strconv.Atoi(msg[3:4])
-				if err != nil {
-					log.Fatal(err)
-				}
-				var forkId, err2 = strconv.Atoi(msg[4:])
-				if err2 != nil {
-					log.Fatal(err2)
-				}
-				log.Print("Philosopher #", p.id, ", RECEIVED reply from Philosopher #", sender, " with fork #", forkId, ",", msg)
-				if (forkId == p.firstForkId) {
-					p.firstForkStatus = true
-					p.firstForkClean  = true
-					p.firstForkRequested  = false
-					log.Print("Philosopher #", p.id, " received fork1")
-				} else {
-					p.secondForkStatus = true
-					p.secondForkClean  = true
-					p.secondForkRequested  = false
-					log.Print("Philosopher #", p.id, " received fork2")
-				}
-				// log.Print(p)
-			} else {
-				log.Fatal("WTF")
-			}
+
+func (p *Philosopher) enterCS() {
+	log.Print("Philosopher #", p.id, " ######################### enterCS")
+	eatingMutex.Lock()
+	for side := 0; side < 2; side++ {
+		if eating[p.neighbor[side]] {
+			log.Fatal("Philosopher #", p.id, " eats with its neighbor #", p.neighbor[side])
 		}
 	}
-	log.Print(p)
+	eating[p.id] = true
+	eatingMutex.Unlock()
+	p.state = STATE_EATING
+	p.mutex.Lock()
+	p.nbCS ++
+	if wait := time.Since(p.hungrySince); wait > p.maxWait {
+		p.maxWait = wait
+	}
+	p.mutex.Unlock()
+	for side := 0; side < 2; side++ {
+		p.fork[side].clean = false
+	}
+}
+
+func (p *Philosopher) releaseCS() {
+	log.Print("Philosopher #", p.id, " releaseCS #########################")
+	eatingMutex.Lock()
+	eating[p.id] = false
+	eatingMutex.Unlock()
+	p.state = STATE_THINKING
+	p.sendForks()
+	if p.meals() == NB_ITERATIONS {
+		log.Print("Philosopher #", p.id, " ate ", NB_ITERATIONS, " times")
+		p.done.Done()
+	}
 }
 
-func (p *Philosopher) requestCS() {
-	// log.Print("Philosopher #", p.id, " requestCS")
+func (p *Philosopher) meals() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.nbCS
+}
 
+func (p *Philosopher) thinkTime() time.Duration {
+	if THINK_TIME <= 0 {
+		return 0
+	}
+	return time.Duration(p.rand.Int63n(int64(THINK_TIME)))
+}
+
+// ChandyMisra handles the messages and the meals of the philosopher, it never returns
+func (p *Philosopher) ChandyMisra() {
+	var thinking <-chan time.Time = time.After(p.thinkTime())
+	var meal <-chan time.Time
 	for {
-		time.Sleep(100 * time.Millisecond)
-		if (p.firstForkStatus == false && p.firstForkRequested != true) {
-			p.firstForkRequested = true
-			if (p.id != 0) {
-				p.requestFork(p.id - 1, p.firstForkId)
-			} else {
-				p.requestFork(3, p.firstForkId)
+		select {
+		case <-thinking:
+			thinking = nil
+			p.state = STATE_HUNGRY
+			p.hungrySince = time.Now()
+			p.requestForks()
+		case <-meal:
+			meal = nil
+			p.releaseCS()
+			if p.meals() < NB_ITERATIONS {
+				thinking = time.After(p.thinkTime())
 			}
-		} else {
-			p.firstForkClean = true
-		}
-		if (p.secondForkStatus == false && p.secondForkRequested != true) {
-			p.secondForkRequested = true
-			if (p.id != NB_NODES - 1) {
-				p.requestFork(p.id + 1, p.secondForkId)
+		case m := <-p.inbox:
+			var f *Fork = &p.fork[p.side(m.ForkId)]
+			if m.MessageType == REQUEST_FORK {
+				f.token = true
+				p.sendForks()
+			} else if m.MessageType == SEND_FORK {
+				f.held = true
+				f.clean = true
 			} else {
-				p.requestFork(0, p.secondForkId)
+				log.Fatal("Unknown message ", m)
 			}
-		} else {
-			p.secondForkClean = true
 		}
-
-		if (p.firstForkStatus == true && p.secondForkStatus == true && p.firstForkClean == true && p.secondForkClean == true) {
-			if (p.state == STATE_EATING) {
-				// log.Print("** Philosopher #", p.id, " is already eating **")
-			} else {
-				p.enterCS()
-				p.releaseCS()
-				for i := 0; i < len(p.queue); i++ {
-					var r Request
-					r = p.queue[i]
-					if (r.forkId == p.firstForkId) {
-						p.firstForkStatus = false
-						p.firstForkRequested = false
-					} else {
-						p.secondForkStatus = false
-						p.secondForkRequested = false
-					}
-					p.sendFork(r.philosopherId, r.forkId)
-					
-				}
-				p.queue = nil
-			}
+		if p.state == STATE_HUNGRY && p.fork[0].held && p.fork[1].held {
+			p.enterCS()
+			meal = time.After(CS_TIME)
 		}
 	}
-
-	log.Print("Philosopher #", p.id," END")	
 }
 
-func (p *Philosopher) ChandyMisra(wg *sync.WaitGroup) {
-	log.Print("Philosopher #", p.id)
+// run starts the philosophers and waits until they all ate NB_ITERATIONS
+// times or the timeout, it returns the number of philosophers that did not and
+// the longest wait of a hungry philosopher
+func run(seed int64, timeout time.Duration) (int, time.Duration) {
+	var philosophers = make([]Philosopher, NB_NODES)
+	var inboxes = make([]chan Message, NB_NODES)
+	var done sync.WaitGroup
+	eatingMutex.Lock()
+	eating = make([]bool, NB_NODES)
+	eatingMutex.Unlock()
 
-	go p.requestCS()
-	go p.waitForReplies()
-	for {
-		time.Sleep(100 * time.Millisecond)
-		if CURRENT_ITERATION == NB_ITERATIONS {
-			break
+	log.Print("nb_process #", NB_NODES, ", seed ", seed)
+
+	for i := 0; i < NB_NODES; i++ {
+		inboxes[i] = make(chan Message, INBOX_SIZE)
+	}
+	for i := 0; i < NB_NODES; i++ {
+		var p *Philosopher = &philosophers[i]
+		p.id = i
+		p.state = STATE_THINKING
+		p.neighbor[0] = (i + NB_NODES - 1) % NB_NODES
+		p.neighbor[1] = (i + 1) % NB_NODES
+		p.fork[0].id = (i + NB_NODES - 1) % NB_NODES
+		p.fork[1].id = i
+		// Initially the forks are dirty, in the hand of the neighbor with the lowest id
+		for side := 0; side < 2; side++ {
+			p.fork[side].held = i < p.neighbor[side]
+			p.fork[side].token = !p.fork[side].held
+			p.fork[side].clean = false
 		}
+		p.rand = rand.New(rand.NewSource(seed + int64(i)))
+		p.inbox = inboxes[i]
+		p.inboxes = inboxes
+		p.done = &done
 	}
 
-	log.Print("Philosopher #", p.id," END after ", NB_ITERATIONS," CS entries")	
-	wg.Done()
-}
-
-func main() {
-	var philosophers = make([]Philosopher, NB_NODES)
-	var wg sync.WaitGroup
-	var messages  = make([]chan string, NB_NODES)
-	
-	log.Print("nb_process #", NB_NODES)
-	
+	var start time.Time = time.Now()
+	done.Add(NB_NODES)
 	for i := 0; i < NB_NODES; i++ {
-		philosophers[i].id = i
-		philosophers[i].nbCS = 0
-		philosophers[i].state = STATE_THINKING
-		if (i == NB_NODES - 1) {
-			philosophers[i].secondForkId  = 0
-			philosophers[i].firstForkId = NB_NODES - 1
-		} else {
-			philosophers[i].firstForkId  = i
-			philosophers[i].secondForkId = i + 1
-		}
-		// Initially forks are in the hand of the philosophers with the same id
-		// Initially all forks are dirty
-		philosophers[i].firstForkStatus  = true
-		philosophers[i].firstForkClean  = false
-		philosophers[i].firstForkRequested = false
-		
-		philosophers[i].secondForkStatus = false
-		philosophers[i].secondForkClean  = false
-		philosophers[i].secondForkRequested = false
-
-		// Break the symmetry of the initialization otherwise the system can deadlock at startup
-		if (i == NB_NODES - 1) {
-			philosophers[i].secondForkStatus = true
-		} else if (i == 0) {
-			philosophers[i].firstForkStatus  = false
+		go philosophers[i].ChandyMisra()
+	}
+	var finished = make(chan bool)
+	go func() {
+		done.Wait()
+		close(finished)
+	}()
+	var starving int = 0
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		for i := 0; i < NB_NODES; i++ {
+			if nbCS := philosophers[i].meals(); nbCS < NB_ITERATIONS {
+				starving ++
+				log.Print("Philosopher #", i, " starves, it ate ", nbCS, " times")
+			}
 		}
-		
-		philosophers[i].channel = messages[i]
-		messages[i] = make(chan string)
+		log.Print("!!!! ", starving, " philosophers did not eat ", NB_ITERATIONS, " times after ", timeout)
 	}
+
+	var maxWait time.Duration = 0
 	for i := 0; i < NB_NODES; i++ {
-		philosophers[i].messages = messages
+		philosophers[i].mutex.Lock()
+		if philosophers[i].maxWait > maxWait {
+			maxWait = philosophers[i].maxWait
+		}
+		philosophers[i].mutex.Unlock()
 	}
-	
-	for i := 0; i < NB_NODES; i++ {
-		wg.Add(1)
-		go philosophers[i].ChandyMisra(&wg)
+	if starving == 0 {
+		log.Print("All the ", NB_NODES, " philosophers ate ", NB_ITERATIONS, " times in ", time.Since(start), ", longest wait ", maxWait)
 	}
-	wg.Wait()
-	for i := 0; i < NB_NODES; i++ {
-		log.Print("Philosopher #", philosophers[i].id," entered CS ", philosophers[i].nbCS," time")	
+	return starving, maxWait
+}
 
+func main() {
+	flag.IntVar(&NB_NODES, "nodes", NB_NODES, "number of philosophers, at least 2")
+	flag.IntVar(&NB_ITERATIONS, "nbIterations", NB_ITERATIONS, "number of meals of each philosopher")
+	flag.DurationVar(&THINK_TIME, "think", THINK_TIME, "maximum think time")
+	flag.DurationVar(&CS_TIME, "cs", CS_TIME, "duration of a meal")
+	timeoutPtr := flag.Duration("timeout", time.Minute, "the run fails if a philosopher did not eat all its meals after this duration")
+	seedPtr := flag.Int64("seed", 0, "seed of the think times, based on the time if 0")
+	flag.BoolVar(&VERBOSE, "v", VERBOSE, "log the messages")
+	flag.Parse()
+	if NB_NODES < 2 {
+		log.Fatal("-nodes must be at least 2")
+	}
+	var seed int64 = *seedPtr
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if starving, _ := run(seed, *timeoutPtr); starving > 0 {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Starvation freedom: each of 128 philosophers on a ring eats all its meals
+func TestManyPhilosophers(t *testing.T) {
+	NB_NODES = 128
+	NB_ITERATIONS = 5
+	THINK_TIME = 5 * time.Millisecond
+	CS_TIME = 5 * time.Millisecond
+	starving, maxWait := run(1, time.Minute)
+	if starving > 0 {
+		t.Error(starving, " philosophers did not eat ", NB_ITERATIONS, " times")
+	}
+	t.Log("longest wait ", maxWait)
+}
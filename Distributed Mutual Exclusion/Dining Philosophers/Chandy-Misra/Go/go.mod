@@ -0,0 +1,3 @@
+module diningchandymisra
+
+go 1.21
@@ -0,0 +1,762 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+How-to run: 
+  go run ./cmd/drinking --algo=AwerbuchSaks 2>&1 |tee /tmp/tmp.log
+  go run ./cmd/drinking --algo=AwerbuchSaks --nodes=8 --requestSize=3 --nbIterations=50 --seed=42 2>&1 |tee /tmp/tmp.log
+  go run ./cmd/drinking --algo=AwerbuchSaks --nodes=8 --requestSize=8 --requestSizeDist=geometric 2>&1 |tee /tmp/tmp.log
+  go run ./cmd/drinking --algo=AwerbuchSaks --nodes=8 --pattern=zipf --cs=exp:100ms --think=0-20ms 2>&1 |tee /tmp/tmp.log
+
+Terminology
+* A scheduler is any computing device which runs the Awerbuch-Saks algorithm
+* A job is created on a scheduler and needs a set of resources to execute, two
+  jobs needing a common resource conflict. Scheduler #i runs the jobs with ids
+  i, i + NB_JOBS, i + 2 * NB_JOBS, ... one after the other
+
+Parameters:
+- Number of schedulers, NB_JOBS global variable, one per node
+- Workload: the resources needed by a job, the think and CS durations, see
+  the Workload package
+- Number of CS entries, NB_ITERATIONS global variable
+- Seed of the random choices of the schedulers
+
+Messages
+* REQ/REP: a new job asks the other schedulers for the jobs it competes
+  with, and their positions. REQ carry a Lamport timestamp, of two jobs
+  created at the same time the one with the lowest (timestamp, scheduler id)
+  is positioned first, the other one waits for its position
+* Schedule(j, Compete): sent by the scheduler to itself once all REP are received
+* Report(k, P): job k is at position P, Imbalance counts the Report sent to
+  a job minus the Report received from it. A job only advances when it does not
+  wait for any Report. It delays its answer to the job directly behind it,
+  which cannot advance to its slot until then
+* Execute: sent by the scheduler to itself when its job reaches position (0, 0)
+* Done: sent once the job executed, its position becomes (0, -1)
+The links are FIFO, the messages to a scheduler go through an outbox.
+A job at position (level, slot) advances by decreasing its slot, from slot 0 it
+moves to the level below. Competitors that finished are removed when they report
+(0, -1), a scheduler answers (0, -1) to the Report sent to its finished jobs.
+
+Response time
+The response time of a job is the time between its creation and its
+execution. The paper bounds it by a polynomial in the number of conflicting
+jobs, it is measured here in longest CS durations and compared with (d + 1)^BOUND_EXPONENT,
+d being the number of jobs the job competed with, see BoundSummary.
+*/ 
+
+/*
+    Go implementation of Awerbuch-Saks mutual exclusion algorithm, dynamic job scheduling
+    Algorithm by Baruch Awerbuch and Michael Saks 1990
+
+References : 
+ * https://doi.org/10.1109/FSCS.1990.89525 : Awerbuch, Baruch, and Mike Saks. "A dining philosophers algorithm with polynomial response time." Proceedings [1990] 31st Annual Symposium on Foundations of Computer Science. IEEE, 1990.
+*/
+
+package AwerbuchSaks
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"drinking/Clock"
+	"drinking/Stats"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+/* global variable declaration */
+var NB_JOBS           int = 4
+var NB_ITERATIONS     int = 10
+var CURRENT_ITERATION int = 0
+var NB_MSG            int = 0
+
+var MAX_SLOTS         = 50
+var OUTBOX_SIZE       = 1024
+
+// BOUND_EXPONENT is the degree of the bound on the response time: the paper
+// shows that a job in conflict with d jobs executes within O(d^2) time
+// units, a unit being the longest message delay or CS. The messages are
+// immediate here, so the unit is the longest CS duration and the constant 1
+var BOUND_EXPONENT    float64 = 2
+
+var REQ_TYPE      int = 0
+var REP_TYPE      int = 1
+var SCHEDULE_TYPE int = 2
+var REPORT_TYPE   int = 3
+var EXECUTE_TYPE  int = 4
+var DONE_TYPE     int = 5
+
+// Status of the current job of a scheduler, answered in REP
+var IDLE      int = 0 // no job, or a job that does not conflict
+var JOINING   int = 1 // the job waits for its REP
+var COMPETING int = 2 // the job has a position
+
+// Position of the jobs that are not competing anymore
+var DONE_POSITION Position = Position{0, -1}
+// Position assumed for a competitor until it reports
+var UNKNOWN_POSITION Position = Position{MAX_SLOTS, MAX_SLOTS}
+
+// globalMutex protects CURRENT_ITERATION, NB_MSG, resourceUser and stats
+var globalMutex sync.Mutex
+// resourceUser is the job using each resource in CS, to check mutual exclusion
+var resourceUser = make(map[int]int)
+var stats []JobStats
+
+// Jobs are the schedulers, Jobs[i] runs the jobs of scheduler #i
+var Jobs []Job
+
+// Recorder records the statistics of the run when set, see the Stats package
+var Recorder *Stats.Recorder
+
+// Tracer records the CS entries and exits when set, see the Trace package,
+// the messages are not traced
+var Tracer *Trace.Tracer
+
+type Position struct {
+	Level int
+	Slot  int
+}
+
+type JobSet struct {
+	JobId     int
+	Position  Position
+	Resources []int
+}
+
+type Request struct {
+	RequesterJobId int // job sending the message
+	JobId          int // job the message is for
+	MessageType    int
+	ResourceId     []int
+	Position       Position
+	Status         int
+	Timestamp      int
+	Compete        []JobSet
+}
+
+// JobStats is the response time of a job
+type JobStats struct {
+	JobId        int
+	ResponseTime time.Duration
+	NbCompetitors int
+	CSDuration   time.Duration
+}
+
+type Job struct {
+	// From the algorithm
+	id         int
+	compete    map[int]JobSet
+	position   Position
+	imbalance  map[int]int
+	// Implementation specific
+	jobId       int // the current job, or the last one
+	status      int
+	resources   []int
+	timestamp   int
+	clock       *Clock.Lamport // The highest timestamp seen in any REQ message sent or received
+	nbRep       int
+	received    []JobSet // competitors found with REP
+	deferredReq []Request // REQ of concurrent jobs answered once positioned
+	competitors map[int]bool // all the jobs the current job competed with
+	created     time.Time
+	csDuration  time.Duration
+	finished    chan bool
+	NbCS       int // the number of time the node entered its Critical Section
+	Messages   []chan []byte
+	outbox     []chan []byte // messages to each scheduler, forwarded in FIFO order
+	workload   *Workload.Generator
+	mutex      sync.Mutex
+	stop       chan bool
+}
+
+func UnmarshalRequest(text []byte, request *Request) error {
+	dec := gob.NewDecoder(bytes.NewReader(text))
+	return dec.Decode(request)
+}
+
+func MarshalRequest(request Request) ([]byte, error) {
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	err := enc.Encode(request)
+	return buffer.Bytes(), err
+}
+
+func inSlice (slice []int, v int) bool {
+	for i := 0; i < len(slice); i ++ {
+		if slice[i] == v {
+			return true
+		}
+	}
+	return false
+}
+
+func conflicts(r1 []int, r2 []int) bool {
+	for i := 0; i < len(r1); i ++ {
+		if inSlice(r2, r1[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func iterationsDone() bool {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return CURRENT_ITERATION >= NB_ITERATIONS
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("(%d, %d)", p.Level, p.Slot)
+}
+
+func (job *Job) String() string {
+	var val string
+	val = fmt.Sprintf("Job #%d Position.level=%d, Position.slot=%d\n",
+		job.jobId,
+		job.position.Level,
+		job.position.Slot)
+	return val
+}
+
+func (job *Job) enterCS() {
+	log.Print("Job #", job.jobId, " ######################### enterCS")
+	globalMutex.Lock()
+	for _, r := range job.resources {
+		if user, ok := resourceUser[r]; ok {
+			log.Fatal("Job #", job.jobId, " enters CS with resource #", r, " used by Job #", user)
+		}
+		resourceUser[r] = job.jobId
+	}
+	CURRENT_ITERATION ++
+	globalMutex.Unlock()
+	Tracer.EnterCS(job.id)
+	// log.Print(n)
+	time.Sleep(job.csDuration)
+}
+
+func (job *Job) releaseCS() {
+	log.Print("Job #", job.jobId," releaseCS #########################")	
+	Tracer.ReleaseCS(job.id)
+	globalMutex.Lock()
+	for _, r := range job.resources {
+		delete(resourceUser, r)
+	}
+	globalMutex.Unlock()
+	// log.Print(n)
+}
+
+// does p2 obstruct p1 ? p2 is the slot p1 advances to, or p2 is about to move
+// to the level of p1, or p1 is about to move to the level of p2 and p2 could
+// still move to the slots p1 may choose
+func obstructs(p1 Position, p2 Position) bool {
+	if (p1.Level == p2.Level) {
+		if (p2.Slot == p1.Slot || p2.Slot == p1.Slot - 1) {
+			return true
+		}
+	}
+	if (p2.Level == p1.Level + 1 && p2.Slot == 0) {
+		return true
+	}
+	if (p1.Level == p2.Level + 1 && p1.Slot == 0 && p2.Slot > 1) {
+		return true
+	}
+	
+	return false
+}
+
+func (job *Job) schedule(receivedCompete []JobSet) {
+	log.Print("Job #", job.jobId," schedule")	
+	var L int = 0
+	for i := 0; i < len(receivedCompete); i ++ {
+		job.compete[receivedCompete[i].JobId] = receivedCompete[i]
+		job.competitors[receivedCompete[i].JobId] = true
+		if receivedCompete[i].Position.Level + 1 > L {
+			L = receivedCompete[i].Position.Level + 1
+		}
+	}
+	job.position.Level = L
+	job.position.Slot = 0
+	job.status = COMPETING
+	for _, r := range job.deferredReq {
+		job.answerReq(r)
+	}
+	job.deferredReq = nil
+	job.announce()
+	job.advanceWhilePossible()
+}
+
+func (job *Job) done() {
+	log.Print("Job #", job.jobId," done")	
+	job.position = DONE_POSITION
+	job.rebalance()
+	job.status = IDLE
+	job.compete = make(map[int]JobSet)
+	job.imbalance = make(map[int]int)
+	job.finished <- true
+}
+
+func (job *Job) report(k int, P Position) {
+	log.Print("Job #", job.jobId," report from Job #", k, " at ", P)	
+	var jk JobSet = job.compete[k]
+	jk.JobId = k
+	jk.Position = P
+	job.compete[k] = jk
+	job.competitors[k] = true
+	job.imbalance[k] --
+	if (job.imbalance[k] == 0) {
+		if (P == DONE_POSITION) {
+			delete(job.compete, k)
+			delete(job.imbalance, k)
+		}
+		job.advanceWhilePossible()
+	} else if (P == DONE_POSITION) {
+		// k finished without waiting for an answer
+		delete(job.compete, k)
+		delete(job.imbalance, k)
+		job.advanceWhilePossible()
+	} else {
+		job.imbalance[k] = -1
+		if (P != Position{job.position.Level, job.position.Slot + 1}) {
+			job.inform(k)
+		}
+	}
+}
+
+// advanceWhilePossible is the while loop of Report(k, P), also run once the job is scheduled
+func (job *Job) advanceWhilePossible() {
+	for job.status == COMPETING && (job.position.Level > 0 || job.position.Slot > 0) {
+		for _, i := range job.imbalance {
+			if i > 0 {
+				return
+			}
+		}
+		// Two Report crossing each other are both taken as answers, the
+		// job still known in the next slot must answer once it moved
+		for k, c := range job.compete {
+			if c.Position.Level == job.position.Level && c.Position.Slot == job.position.Slot - 1 {
+				job.inform(k)
+				return
+			}
+		}
+		job.advance()
+		job.rebalance()
+		job.announce()
+	}
+}
+
+func (job *Job) advance() {
+	log.Print("Job #", job.jobId," advance")	
+	if job.position.Slot > 0 {
+		job.position.Slot --
+	} else {
+		job.position.Level --
+
+		var bit int = (job.jobId >> uint(job.position.Level)) & 1
+		var filled []int
+		for _, k := range job.compete {
+			if k.Position.Level == job.position.Level {
+				filled = append(filled, k.Position.Slot)
+			}
+		}
+
+		// Slot <- min {T | T in Free & Proper }
+		for T := 0; ; T ++ {
+			var proper bool = T % 4 == 2 * bit
+			var free bool = !inSlice(filled, T) && !inSlice(filled, T + 1)
+			if proper && free {
+				job.position.Slot = T
+				break
+			}
+		}
+	}
+}
+
+func (job *Job) rebalance() {
+	log.Print("Job #", job.jobId," rebalance")	
+	for k := range job.compete {
+		if job.imbalance[k] == -1 {
+			job.inform(k)
+		}
+	}
+}
+
+func (job *Job) sendExecute() {
+	log.Print("Job #", job.jobId," sendExecute")	
+	var execute Request
+	execute.MessageType = EXECUTE_TYPE
+	execute.RequesterJobId = job.jobId
+	execute.JobId = job.jobId
+	job.send(job.id, execute)
+}
+
+func (job *Job) announce() {
+	log.Print("Job #", job.jobId," announce")	
+	log.Print(job)
+	if job.position.Level == 0 && job.position.Slot == 0 {
+		job.sendExecute()
+	} else {
+		for k, c := range job.compete {
+			if obstructs(job.position, c.Position) {
+				job.inform(k)
+			}
+		}
+	}
+}
+
+func (job *Job) inform(k int) {
+	log.Print("Job #", job.jobId," inform Job #", k)	
+	job.sendReport(k, job.jobId, job.position)
+	job.imbalance[k] ++
+}
+
+func (job *Job) send(dst int, request Request) {
+	content, err := MarshalRequest(request)
+	if err != nil {
+		log.Fatal(err)
+	}			
+	select {
+	case job.outbox[dst] <- content:
+	case <-job.stop:
+	}
+}
+
+// forward delivers the messages to scheduler dst in the order they were sent,
+// Report(k, P) rely on FIFO links
+func (job *Job) forward(dst int) {
+	for {
+		select {
+		case <-job.stop:
+			return
+		case content := <-job.outbox[dst]:
+			select {
+			case job.Messages[dst] <- content:
+				// Schedule, Execute and Done are sent by the scheduler to itself
+				if dst != job.id {
+					globalMutex.Lock()
+					NB_MSG ++
+					globalMutex.Unlock()
+				}
+			case <-job.stop:
+				return
+			}
+		}
+	}
+}
+
+func (job *Job) sendReport(k int, jobId int, position Position) {
+	var report Request
+	report.MessageType = REPORT_TYPE
+	report.RequesterJobId = jobId
+	report.JobId = k
+	report.Position = position
+	log.Print("Job #", jobId, ",  REPORT with position #", position.Level, ".", position.Slot, " to Job #", k)	
+	job.send(k % NB_JOBS, report)
+}
+
+// answerReq answers the REQ of a new job with the status and position of the current job
+func (job *Job) answerReq(request Request) {
+	var reply Request
+	reply.MessageType = REP_TYPE
+	reply.RequesterJobId = job.jobId
+	reply.JobId = request.RequesterJobId
+	reply.Status = job.status
+	if job.status == COMPETING {
+		if conflicts(job.resources, request.ResourceId) {
+			reply.Position = job.position
+			reply.ResourceId = job.resources
+		} else {
+			reply.Status = IDLE
+		}
+	}
+	job.send(request.RequesterJobId % NB_JOBS, reply)
+}
+
+func (job *Job) waitForReplies() {	
+	// log.Print("Job #", job.id," waitForReplies")	
+	for {
+		select {
+		case <-job.stop:
+			return
+		case msg := <-job.Messages[job.id]:
+			var request Request
+			err := UnmarshalRequest(msg, &request)
+			if err != nil {
+				log.Fatal(err)
+			}			
+			var requester = request.RequesterJobId
+			job.mutex.Lock()
+			if (request.MessageType == REQ_TYPE) {
+				var res = request.ResourceId
+				log.Print("Job #", job.jobId, "<-REQ, Requester #", requester, ", nb of res:", len(res))
+				job.clock.Witness(request.Timestamp)
+				// Of two jobs created at the same time, the one with the
+				// lowest (timestamp, scheduler id) is positioned first
+				if job.status == JOINING && Clock.CompareLamport(job.timestamp, job.id, request.Timestamp, requester % NB_JOBS) == Clock.BEFORE {
+					job.deferredReq = append(job.deferredReq, request)
+				} else {
+					job.answerReq(request)
+				}
+			} else if (request.MessageType == REP_TYPE) {
+				log.Print("Job #", job.jobId, "<-REP from Job #", requester, ", status ", request.Status)
+				job.nbRep ++
+				if request.Status == COMPETING && conflicts(job.resources, request.ResourceId) {
+					job.received = append(job.received, JobSet{requester, request.Position, request.ResourceId})
+				}
+				if job.nbRep == NB_JOBS - 1 {
+					var schedule Request
+					schedule.MessageType = SCHEDULE_TYPE
+					schedule.RequesterJobId = job.jobId
+					schedule.JobId = job.jobId
+					schedule.Compete = job.received
+					job.send(job.id, schedule)
+				}
+			} else if (request.MessageType == SCHEDULE_TYPE) {
+				job.schedule(request.Compete)
+			} else if (request.MessageType == REPORT_TYPE) {
+				log.Print("Job #", request.JobId, ", received REPORT from Job #", requester, " at ", request.Position)
+				if request.JobId == job.jobId && job.status == COMPETING {
+					job.report(requester, request.Position)
+				} else {
+					// the job finished, its competitors remove it
+					job.sendReport(requester, request.JobId, DONE_POSITION)
+				}
+			} else if (request.MessageType == EXECUTE_TYPE) {
+				job.NbCS ++
+				Recorder.EnterCS(job.id)
+				globalMutex.Lock()
+				stats = append(stats, JobStats{job.jobId, time.Since(job.created), len(job.competitors), job.csDuration})
+				globalMutex.Unlock()
+				go func() {
+					job.enterCS()
+					job.releaseCS()
+					var done Request
+					done.MessageType = DONE_TYPE
+					done.RequesterJobId = job.jobId
+					done.JobId = job.jobId
+					job.send(job.id, done)
+				}()
+			} else if (request.MessageType == DONE_TYPE) {
+				job.done()
+			} else {
+				log.Fatal("Fatal Error")
+			}
+			job.mutex.Unlock()
+		}
+	}
+	// log.Print(n)
+	// log.Print("Job #", job.id, " end waitForReplies")
+}
+
+func (job *Job) sendRequest(request Request) {
+	for j := 0; j < len(job.Messages); j++ {
+		if j != job.id {
+			log.Print("Job #", job.jobId, ",  REQUEST for resources ", request.ResourceId, " to scheduler #", j)	
+			job.send(j, request)
+		}
+	}
+}
+
+func (job *Job) requestCS() {
+	// log.Print("Job #", job.id, " requestCS")
+
+	for !iterationsDone() {
+		var next Workload.Request = job.workload.Next()
+		time.Sleep(next.Think)
+
+		job.mutex.Lock()
+		job.jobId += NB_JOBS
+		job.status = JOINING
+		job.timestamp = job.clock.Tick()
+		job.created = time.Now()
+		job.competitors = make(map[int]bool)
+		job.nbRep = 0
+		job.received = nil
+		job.resources = next.Resources
+		job.csDuration = next.CS
+
+		var request Request
+		request.MessageType = REQ_TYPE
+		request.RequesterJobId = job.jobId
+		request.ResourceId = job.resources
+		request.Timestamp = job.timestamp
+		Recorder.Request(job.id)
+		if NB_JOBS == 1 {
+			job.schedule(nil)
+		} else {
+			job.sendRequest(request)
+		}
+		job.mutex.Unlock()
+
+		select {
+		case <-job.finished:
+		case <-job.stop:
+			return
+		}
+	}	
+	// log.Print("Job #", job.id," END")	
+}
+
+func (job *Job) AwerbuchSaks(wg *sync.WaitGroup) {
+	log.Print("Job #", job.id)
+
+	for j := 0; j < NB_JOBS; j++ {
+		go job.forward(j)
+	}
+	go job.requestCS()
+	go job.waitForReplies()
+	for {
+		time.Sleep(100 * time.Millisecond)
+		if iterationsDone() {
+			break
+		}
+	}
+
+	log.Print("Job #", job.id," END after ", NB_ITERATIONS," CS entries")	
+	wg.Done()
+}
+
+// BoundSummary compares the response times with the bound, the jobs over the
+// bound are logged
+func BoundSummary() string {
+	maxResponse, maxRatio, nbOverBound := CheckBound()
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return fmt.Sprintf("%d jobs executed, max response time %v, max response time / bound %.3f, %d jobs over the bound", len(stats), maxResponse, maxRatio, nbOverBound)
+}
+
+// CheckBound returns the max response time, the max ratio of a response time
+// to its bound, and the number of jobs over the bound, which are logged
+func CheckBound() (time.Duration, float64, int) {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	var maxResponse time.Duration = 0
+	var maxRatio float64 = 0
+	var nbOverBound int = 0
+	var maxCS time.Duration = 0
+	for _, s := range stats {
+		if s.CSDuration > maxCS {
+			maxCS = s.CSDuration
+		}
+	}
+	if maxCS == 0 {
+		maxCS = 1
+	}
+	for _, s := range stats {
+		var response float64 = float64(s.ResponseTime) / float64(maxCS)
+		var bound float64 = math.Pow(float64(s.NbCompetitors + 1), BOUND_EXPONENT)
+		if s.ResponseTime > maxResponse {
+			maxResponse = s.ResponseTime
+		}
+		if response / bound > maxRatio {
+			maxRatio = response / bound
+		}
+		if response > bound {
+			nbOverBound ++
+			log.Print("Job #", s.JobId, " response time ", s.ResponseTime, " over the bound (", s.NbCompetitors, " + 1)^", BOUND_EXPONENT, " CS durations of ", maxCS)
+		}
+	}
+	return maxResponse, maxRatio, nbOverBound
+}
+
+// Stop ends the routines of all the schedulers once the run is over
+func Stop() {
+	for i := 0; i < len(Jobs); i++ {
+		close(Jobs[i].stop)
+	}
+}
+
+// Init creates nbJobs schedulers
+func Init(nbJobs int, nbIterations int, workload *Workload.Config, seed int64) {
+	NB_JOBS = nbJobs
+	NB_ITERATIONS = nbIterations
+	CURRENT_ITERATION = 0
+	NB_MSG = 0
+	resourceUser = make(map[int]int)
+	stats = nil
+
+	Jobs = make([]Job, NB_JOBS)
+	var messages = make([]chan []byte, NB_JOBS)
+	
+	log.Print("nb_process #", NB_JOBS, ", seed ", seed, ", workload ", workload)
+
+	// Initialization
+	for i := 0; i < NB_JOBS; i++ {
+		Jobs[i].id = i
+		Jobs[i].jobId = i - NB_JOBS
+		Jobs[i].NbCS = 0 
+		Jobs[i].status = IDLE
+		Jobs[i].compete = make(map[int]JobSet)
+		Jobs[i].imbalance = make(map[int]int)
+		Jobs[i].finished = make(chan bool, 1)
+		Jobs[i].clock = Clock.NewLamport(0)
+		Jobs[i].outbox = make([]chan []byte, NB_JOBS)
+		for j := 0; j < NB_JOBS; j++ {
+			Jobs[i].outbox[j] = make(chan []byte, OUTBOX_SIZE)
+		}
+		Jobs[i].workload = workload.Generator(i, seed + int64(i))
+		Jobs[i].stop = make(chan bool)
+
+		messages[i] = make(chan []byte)
+	}
+	for i := 0; i < NB_JOBS; i++ {
+		Jobs[i].Messages = messages
+	}
+}
+/* Pseudo-code for original article
+program RECEIVE(C)
+
+C = Schedule(j, Compete):
+  forall k in Compete : Position(k) <- (bit(j, k), infinity)
+  L <- max{Level(k) + 1) | k in Compete}
+  Position <- (L, 0)
+  ANNOUNCE
+
+C = Done:
+  Position <-(0,-1)
+  REBALANCE
+
+C = Report(k, P):
+  Compete <- Compete + {k}
+  Imbalance(k) <- Imbalance(k) - 1
+  Position(k) <- P
+  if (Imbalance(k) == 0)
+    if (P == (0, -1)) Compete <- Compete - {k}
+    while ((forall k in Compete) Imbalance(k) <= 0)
+    and (Position > (0,O))
+      ADVANCE
+      REBALANCE
+      ANNOUNCE
+  else Imbalance(k) = -1
+    if (P != Position + 1) then
+      INFORM (k)
+
+procedure ADVANCE
+  if (Slot > 0) Slot <- Slot - 1
+  else
+    Level <- Level - 1
+    Proper <- {T | T congruent (2 . ID[Level]) mod 4}
+    Same <- {k | k in Competel & Level(k) == Level}
+    Filled <- {Slot(k) | k in Same}
+    Free <- {T >= 0 & {T, T + 1) notin Filled}
+    Slot <- min {T | T in Free & Proper }
+
+procedure REBALANCE
+  forall k in Compete:
+    if (Imbalance(k) == -1) INFORM (k)
+
+procedure ANNOUNCE
+  if (Position = (0, 0)) then
+    SEND Execute
+  else forall k in Compete
+    if (Position(k) obstructs Position)
+      INFORM (k)
+
+procedure INFORM (k)
+  SEND Report(j, Position) to p_k
+  Imbalance(k) <- Imbalance(k) + 1
+*/
@@ -0,0 +1,31 @@
+package AwerbuchSaks
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"drinking/internal/testutil"
+	"drinking/Stats"
+)
+
+// Every job executes within the bound on its response time
+func TestBound(t *testing.T) {
+	var nbNodes int = 5
+	var nbIterations int = 20
+	for seed := int64(1); seed <= 10; seed++ {
+		Init(nbNodes, nbIterations, testutil.NewWorkload(t, nbNodes, 3), seed)
+		Recorder = Stats.New()
+		var wg sync.WaitGroup
+		for i := 0; i < nbNodes; i++ {
+			wg.Add(1)
+			go Jobs[i].AwerbuchSaks(&wg)
+		}
+		testutil.Wait(t, &wg, time.Minute)
+		Stop()
+		t.Log(BoundSummary())
+		if maxResponse, maxRatio, nbOverBound := CheckBound(); nbOverBound > 0 {
+			t.Error("seed ", seed, ": ", nbOverBound, " jobs over the bound, max response time ", maxResponse, ", ", maxRatio, " times the bound")
+		}
+	}
+}
@@ -0,0 +1,1185 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+How-to run:
+  go run ./cmd/drinking --algo=BouabdallahLaforest 2>&1 |tee /tmp/tmp.log
+or, with requests of 1 to 3 resources:
+  go run ./cmd/drinking --algo=BouabdallahLaforest --requestSize=3 --requestSizeDist=uniform
+The Control Token tests, with thousands of resources:
+  go test BouabdallahLaforest
+
+Parameters:
+- Number of nodes, each node is given the token of one resource: there are
+  as many resources as nodes
+- Number of CS entries
+- Workload: the resources of each request, the think and CS durations, see
+  the Workload package
+- Seed of the random choices of the nodes
+
+The Control Token, the free tokens A and the tokens B held by each node, see
+controltoken.go, is only known by the node holding it: it travels inside the REP_CT messages,
+serialized with the message, and the nodes share no memory.
+
+Use as a lock
+The nodes of a run and what they share are in a System, several systems can
+run at the same time. A node locks resources with AcquireResources, that
+blocks until the node holds their tokens, and unlocks them with Release:
+  system, err := BouabdallahLaforest.New(nbNodes, nbIterations, workload, seed)
+  if err != nil {
+  	...
+  }
+  system.Start()
+  var node *BouabdallahLaforest.Node = &system.Nodes[i]
+  if err := node.AcquireResources(ctx, []int{0, 2}); err == nil {
+  	// CS
+  	node.Release()
+  }
+  ...
+  system.Stop()
+A node has at most one request: AcquireResources fails while the previous one
+is not released. If ctx is done before the tokens are obtained,
+AcquireResources returns the error of ctx. The request cannot be withdrawn
+from the other nodes: the node releases the tokens as soon as it gets them,
+unless AcquireResources is called again with the same resources, which then
+waits for them. AcquireResources with other resources waits until they are
+released.
+A node checks when it enters its CS that no other node uses its resources,
+the error is sent to Failure otherwise.
+*/ 
+
+/*
+    Go implementation of Bouabdallah-Laforest mutual exclusion algorithm
+    Algorithm by Abdelmadjid Bouabdallah and Christian Laforest 2000
+
+References : 
+ * https://doi.org/10.1145/506117.506125 : A. Bouabdallah and C. Laforest. 2000. A distributed token-based algorithm for the dynamic resource allocation problem. SIGOPS Oper. Syst. Rev. 34, 3 (July 2000), 60–68. 
+*/
+package BouabdallahLaforest
+
+/*
+  logrus package is used for logs: https://github.com/sirupsen/logrus
+*/
+
+import (
+	// "bufio"
+	"bytes" // for gid
+	"context"
+	"encoding/gob"
+	"fmt"
+	"runtime" // for debugging purpose
+	"strconv"
+	"sync"
+	"time"
+
+	"drinking/Stats"
+	"drinking/Trace"
+	"drinking/Workload"
+	log "github.com/sirupsen/logrus"
+)
+
+/* global variable declaration */
+var BL_FREE    bool = false
+var BL_LOCKED  bool = true
+
+var REQ_TYPE      int = 0
+var REP_TYPE      int = 1
+var REQ_CT_TYPE   int = 2
+var REP_CT_TYPE   int = 3
+var INQUIRE_TYPE  int = 4
+var ACK1_TYPE     int = 5
+var ACK2_TYPE     int = 6
+
+var MESSAGE_NAMES = []string{"REQ", "REP", "REQ_CT", "REP_CT", "INQUIRE", "ACK1", "ACK2"}
+
+var Logger = log.New()
+
+// System is a run of the algorithm, its nodes and what they share
+type System struct {
+	Nodes            []Node
+	// Tracer records the execution when set, see the Trace package
+	Tracer           *Trace.Tracer
+	// Recorder records the statistics of the run when set, see the Stats package
+	Recorder         *Stats.Recorder
+	nbNodes          int // and resources
+	nbIterations     int
+	currentIteration int
+	nbMsg            int
+	resourceUser     map[int]int // the node in CS using each resource, to check mutual exclusion
+	mutex            sync.Mutex  // protects nbMsg, currentIteration and resourceUser
+	failure          chan error  // receives the first error of the nodes
+}
+
+func (s *System) countMessage() {
+	s.mutex.Lock()
+	s.nbMsg ++
+	s.mutex.Unlock()
+}
+
+// NbMsg returns the number of messages received by the nodes
+func (s *System) NbMsg() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.nbMsg
+}
+
+// iterationsDone returns true when the nodes entered their CS nbIterations times in total
+func (s *System) iterationsDone() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.currentIteration >= s.nbIterations
+}
+
+// Failure returns the channel receiving the first error of the nodes, such as
+// an inconsistent Control Token, after which the run should be stopped
+func (s *System) Failure() <-chan error {
+	return s.failure
+}
+
+// reportError reports an error of a node, such as an inconsistent Control Token
+func (s *System) reportError(err error) {
+	Logger.Error(err)
+	select {
+	case s.failure <- err:
+	default:
+	}
+}
+/*
+// Debug function
+func displayNodes() {
+	for i := 0; i < len(nodes); i++ {
+		Logger.Print("Node #", nodes[i].id, ", last=", nodes[i].last, ", next=", nodes[i].next)
+	}
+}
+*/
+type Token struct {
+	id     int
+	locked bool
+}
+
+type Request struct {
+	RequesterNodeId int
+	RequestId      int
+	MessageType    int
+	ResourceId     []int
+	CT             *ControlToken // the Control Token sent with REP_CT, nil otherwise
+	TraceId        int
+	TraceSender    int // the node that sent the message, a forwarded request keeps its requester
+}
+
+type Node struct {
+	// From the algorithm
+	id                        int
+	has_CT                    bool
+	controlToken              *ControlToken // nil when has_CT is false
+	tokens                    []Token
+	tokensNeeded              []int
+	requesting                bool
+	currentlyRequestingTokens []int
+	currentRequest            Request
+	RequestIdCounter          int
+	waitingSet                map[int][]int
+	next                      int // the dynamic distributed list
+	last                      int // called father in the original paper. Called last here as in Sopena et al. as it stores the last requester
+	inBLCS                    bool
+	requestInCS               Request
+	// Implementation specific
+	mutex          sync.Mutex
+	NbCS           int // the number of time the node entered its Critical Section
+	queue          []Request
+	Messages       []chan bytes.Buffer
+	workload       *Workload.Generator
+	acquiring      *Request  // the request of AcquireResources, nil until it is released
+	abandoned      bool      // AcquireResources returned before acquiring was granted
+	granted        chan bool // receives when the node enters its CS for acquiring
+	released       chan bool // closed when acquiring is released
+	stop           chan bool
+	system         *System
+}
+
+////////////////////////////////////////////////////////////
+// Debug functions
+////////////////////////////////////////////////////////////
+// get ID of go routine
+func getGID() uint64 {
+    b := make([]byte, 64)
+    b = b[:runtime.Stack(b, false)]
+    b = bytes.TrimPrefix(b, []byte("goroutine "))
+    b = b[:bytes.IndexByte(b, ' ')]
+    n, _ := strconv.ParseUint(string(b), 10, 64)
+    return n
+}
+
+////////////////////////////////////////////////////////////
+// Utility functions
+////////////////////////////////////////////////////////////
+func UnmarshalRequest(b bytes.Buffer, request *Request) error {
+	dec := gob.NewDecoder(&b)
+
+	error := dec.Decode(request)
+
+	return error
+}
+
+func MarshalRequest(request Request) (bytes.Buffer, error) {
+	var buffer bytes.Buffer        
+	enc := gob.NewEncoder(&buffer) 
+	error := enc.Encode(request)
+
+	return buffer, error
+}
+
+func messageName(messageType int) string {
+	if messageType >= 0 && messageType < len(MESSAGE_NAMES) {
+		return MESSAGE_NAMES[messageType]
+	}
+	return strconv.Itoa(messageType)
+}
+
+// traceState records the position of the node in the distributed list and the tokens it holds
+func (n *Node) traceState() {
+	if n.system.Tracer == nil {
+		return
+	}
+	var tokens []int
+	for i := 0; i < len(n.tokens); i++ {
+		tokens = append(tokens, n.tokens[i].id)
+	}
+	var state = map[string]interface{}{
+		"has_CT": n.has_CT,
+		"last": n.last,
+		"next": n.next,
+		"requesting": n.requesting,
+		"tokens": tokens,
+	}
+	n.system.Tracer.State(n.id, state)
+}
+
+func (r *Request) String() string {
+	var val string
+	var res string = ""
+
+	for i := 0; i < len(r.ResourceId); i ++ {
+		res +=  strconv.Itoa(r.ResourceId[i]) + ", "
+	}
+
+	val = fmt.Sprintf("Request #=%d, requester node=%d, messageType=%d, resources={%s}",
+		r.RequestId,
+		r.RequesterNodeId,
+		r.MessageType,
+		res)
+	return val
+}
+
+////////////////////////////////////////////////////////////
+// ControlToken class, see controltoken.go
+////////////////////////////////////////////////////////////
+
+// removeNeededTokensFromFreeTokens gives the free tokens needed by node to node, locked
+func (ct *ControlToken) removeNeededTokensFromFreeTokens(node *Node) error {
+	Logger.Debug("Node #", node.id, ", BEGIN ControlToken.removeNeededTokensFromFreeTokens ct.A=", ct.FreeTokens(), " node.tokensNeeded=", node.tokensNeeded)
+	for _, token := range node.tokensNeeded {
+		if !ct.IsFree(token) {
+			continue
+		}
+		if err := ct.Move(token, node.id); err != nil {
+			return err
+		}
+		var t Token
+		t.id = token
+		node.addTokenToSet(t, BL_LOCKED)
+	}
+	Logger.Debug("Node #", node.id, ", END ControlToken.removeNeededTokensFromFreeTokens ct.A=", ct.FreeTokens(), " node.tokensNeeded=", node.tokensNeeded)
+	return nil
+}
+
+func (ct *ControlToken) updateForRequest(
+	n *Node,
+	request Request,
+	missingTokens *([]int),
+	requestedResourcesForNode *(map[int][]int)) error {
+	
+	Logger.Debug("Node #", n.id, ", BEGIN ControlToken.updateForRequest, ", ct.String(), ", routine #", getGID())
+	// First move the tokens already owned by the node in B to A
+	for _, token := range ct.TokensOf(n.id) {
+		if err := ct.Free(token); err != nil {
+			return err
+		}
+	}
+	Logger.Debug(ct.String())
+	
+	// Remove needed tokens from A
+	n.tokensNeeded = request.ResourceId
+	Logger.Debug("Node #", n.id, ", n.tokensNeeded", n.tokensNeeded, ", routine #", getGID())
+	if err := ct.removeNeededTokensFromFreeTokens(n); err != nil {
+		return err
+	}
+	Logger.Debug("Node #", n.id, ", updateForRequest 1 ", ct.String(), ", routine #", getGID())
+	
+	// The tokens held by other nodes in B are requested from them
+	for _, token := range n.tokensNeeded {
+		owner, ok := ct.OwnerOf(token)
+		if !ok {
+			return fmt.Errorf("Node #%d requests token #%d that is not in the Control Token", n.id, token)
+		}
+		if owner != n.id && owner != FREE_TOKEN {
+			(*requestedResourcesForNode)[owner] = append((*requestedResourcesForNode)[owner], token)
+			*missingTokens = append(*missingTokens, token)
+		}
+		// the node is the last one given the token
+		if err := ct.Move(token, n.id); err != nil {
+			return err
+		}
+	}
+	Logger.Debug("Node #", n.id, ", ControlToken.updateForRequest requestedResourcesForNode ", requestedResourcesForNode, ", missingTokens ", missingTokens, ", routine #", getGID())
+
+	// Put unneeded tokens in Control Token
+	for i := 0; i < len(n.tokens);  {
+		if n.tokens[i].locked == BL_FREE {
+			n.tokens[i] = n.tokens[len(n.tokens) - 1]
+			n.tokens = n.tokens[:len(n.tokens) - 1]
+		} else {
+			i++
+		}
+	}
+	if err := ct.Check(n.system.nbNodes); err != nil {
+		return fmt.Errorf("Node #%d, inconsistent ControlToken after the request: %v", n.id, err)
+	}
+	Logger.Debug("Node #", n.id, ", END ControlToken.updateForRequest", ct.String(), ", routine #", getGID())
+	return nil
+}
+
+////////////////////////////////////////////////////////////
+// Node class
+////////////////////////////////////////////////////////////
+func (n *Node) String() string {
+	var val string
+	val = fmt.Sprintf("Node #%d next=%d, last=%d",
+		n.id,
+		n.next,
+		n.last)
+	return val
+}
+
+func (n *Node) enterCS() {
+	Logger.Debug("Node #", n.id, " ######################### enterCS")
+	Logger.Debug("Node #", n.id, ", ", n.controlToken.String())
+	n.system.mutex.Lock()
+	n.system.currentIteration ++
+	for _, resource := range n.requestInCS.ResourceId {
+		if user, used := n.system.resourceUser[resource]; used {
+			n.system.reportError(fmt.Errorf("Node #%d enters its CS with resource #%d used by Node #%d", n.id, resource, user))
+		}
+		n.system.resourceUser[resource] = n.id
+	}
+	n.system.mutex.Unlock()
+	n.NbCS ++
+	n.system.Recorder.EnterCS(n.id)
+	n.system.Tracer.EnterCS(n.id)
+	n.traceState()
+	Logger.Debug(n)
+}
+
+func (node *Node) executeCSCode(duration time.Duration) {
+	Logger.Debug("Node #", node.id, " ######################### executeCSCode")
+	time.Sleep(duration)
+}
+
+func (n *Node) releaseCS() {
+	Logger.Debug("Node #", n.id," releaseCS #########################")	
+	n.system.Tracer.ReleaseCS(n.id)
+	n.system.mutex.Lock()
+	for _, resource := range n.requestInCS.ResourceId {
+		if n.system.resourceUser[resource] == n.id {
+			delete(n.system.resourceUser, resource)
+		}
+	}
+	n.system.mutex.Unlock()
+	n.leaveBLCS()
+	Logger.Debug(n)
+}
+
+func (n *Node) leaveBLCS() {
+	Logger.Debug("Node #", n.id, ", BEGIN leaveBLCS", ", routine #", getGID())
+	for i := 0; i < len(n.tokens); i ++ {
+		n.tokens[i].locked = BL_FREE
+	}
+	n.tokensNeeded = make([]int, 0)
+	// n.tokens = make([]Token, 0)
+	for key, _ := range n.waitingSet {
+		if len(n.waitingSet[key]) > 0 {
+			var tokens []int = n.waitingSet[key]
+			for _, token := range tokens {
+				n.removeTokenFromSet(token)
+			}
+			go n.sendACK2(&tokens, key)
+		}
+	}
+	n.waitingSet = make(map[int][]int)
+	
+	if n.has_CT == true {
+		// the tokens of the node go back to A, with the Control Token
+		for _, token := range n.controlToken.TokensOf(n.id) {
+			if err := n.controlToken.Free(token); err != nil {
+				n.system.reportError(err)
+			}
+			n.removeTokenFromSet(token)
+		}
+		if err := n.controlToken.Check(n.system.nbNodes); err != nil {
+			n.system.reportError(fmt.Errorf("Node #%d, inconsistent ControlToken on leaving the CS: %v", n.id, err))
+		}
+		if n.next != -1 {
+			n.last = n.next
+			n.sendCT(n.next)
+			n.next = -1
+		}
+	}	
+	Logger.Debug("Node #", n.id, ", END leaveBLCS", ", routine #", getGID())
+}
+
+func (n *Node) ownsToken(id int) bool {
+	for i := 0; i < len(n.tokens); i++ {
+		if n.tokens[i].id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) lockResource(id int) {
+	for i := 0; i < len(n.tokens); i++ {
+		if n.tokens[i].id == id {
+			n.tokens[i].locked = true
+			return
+		}
+	}	
+}
+
+// sendCT gives the Control Token to dst, called with the mutex held
+func (n *Node) sendCT(dst int) {
+	var request Request
+	request.RequesterNodeId = n.id
+	request.RequestId = 0
+	request.MessageType = REP_CT_TYPE
+	request.TraceId = n.system.Tracer.NextId()
+	request.TraceSender = n.id
+	// the Control Token leaves the node with the message
+	request.CT = n.controlToken
+	
+	content, err := MarshalRequest(request)
+	if err != nil {
+		n.system.reportError(err)
+		return
+	}			
+	// Logger.Debug("Node #", n.id, ",  SEND CT #", request.RequestId, ":", content, " to Node #", dst, ", routine #", getGID())	
+	Logger.Debug("Node #", n.id, ",  SEND CT #", request.RequestId, " to Node #", dst, ", routine #", getGID())	
+	n.has_CT = false
+	n.controlToken = nil
+	n.traceState()
+	n.system.Tracer.Send(n.id, dst, messageName(request.MessageType), request.TraceId)
+	go n.transmit(dst, content)
+}
+
+func (n *Node) handleCTRequest(request Request) {
+	Logger.Debug("Node #", n.id, ",  handleCTRequest request:", request.String(), ", routine #", getGID())
+	if n.last == -1 {
+		if n.requesting {
+			n.next = request.RequesterNodeId
+		} else {
+			n.sendCT(request.RequesterNodeId)
+		}		
+	} else {
+		// Code duplication to remove
+		var fwdRequest Request
+		fwdRequest.MessageType = REQ_CT_TYPE
+		fwdRequest.RequesterNodeId = request.RequesterNodeId
+		fwdRequest.TraceId = n.system.Tracer.NextId()
+		fwdRequest.TraceSender = n.id
+		
+		content, err := MarshalRequest(fwdRequest)
+		if err != nil {
+			n.system.reportError(err)
+			return
+		}			
+		// Logger.Debug("Node #", n.id, ",  FWD REQUEST CT #", request.RequestId, ":", content, " to Node #", n.last)	
+		Logger.Debug("Node #", n.id, ",  FWD REQUEST CT #", request.RequestId, " to Node #", n.last)	
+		n.system.Tracer.Send(n.id, n.last, messageName(fwdRequest.MessageType), fwdRequest.TraceId)
+		go n.transmit(n.last, content)
+	}
+	n.last = request.RequesterNodeId
+	Logger.Debug("Node #", n.id, " handleCTRequest, *update* n.last #", n.last)
+}
+
+func (n *Node) hasAllTokensForRequest(request Request) bool {
+	var resourcesRequested []int = request.ResourceId
+
+	for i := 0; i < len(resourcesRequested); i++ {
+		var hasToken bool = false
+		for j := 0; j < len(n.tokens); j++ {
+			if n.tokens[j].id == resourcesRequested[i] {
+				hasToken = true
+				break
+			}
+		}
+		if (hasToken == false) {
+			Logger.Debug("Node #", n.id, " is missing token ", resourcesRequested[i])
+			return false
+		}
+	}
+	return true
+}
+
+// enterCSIfCan enters the CS if the node holds all the tokens of the request
+// of AcquireResources, that returns. The node stays in its CS until Release.
+// If AcquireResources already returned, the tokens are given back at once
+// without entering the CS
+func (n *Node) enterCSIfCan() bool {
+	Logger.Debug("Node #", n.id, ", enterCSIfCan")
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.acquiring == nil || n.inBLCS {
+		return false
+	}
+	if n.hasAllTokensForRequest(*n.acquiring) {
+		if n.abandoned {
+			Logger.Debug("Node #", n.id, ", gives back the tokens of its abandoned request")
+			n.leaveBLCS()
+			n.endRequest()
+			return false
+		}
+		Logger.Debug("Node #", n.id, ", ENTERS BLCS")
+		n.inBLCS = true
+		n.requestInCS = *n.acquiring
+		n.enterCS()
+		n.granted <- true
+		return true
+	}
+	Logger.Debug("Node #", n.id, ", CANNOT enterCSIfCan")
+	return false
+}
+
+func (n *Node) requestTokens(request Request, dst int, tokens[]int) {
+	Logger.Debug("Node #", n.id, ", requestTokens")
+	var inquireRequest Request
+	inquireRequest.MessageType = INQUIRE_TYPE
+	inquireRequest.RequestId = request.RequestId
+	inquireRequest.RequesterNodeId = n.id
+		
+	inquireRequest.ResourceId = make([]int, len(tokens))
+	copy(inquireRequest.ResourceId, tokens)
+	inquireRequest.TraceId = n.system.Tracer.NextId()
+	inquireRequest.TraceSender = n.id
+	content, err := MarshalRequest(inquireRequest)
+	if err != nil {
+		n.system.reportError(err)
+		return
+	}			
+	// Logger.Debug("Node #", n.id, ", send INQUIRE #", inquireRequest.RequestId, ":", content, " to Node #", dst, " for res ", tokens)	
+	Logger.Debug("Node #", n.id, ", send INQUIRE #", inquireRequest.RequestId, " to Node #", dst, " for res ", tokens)	
+	n.system.Tracer.Send(n.id, dst, messageName(inquireRequest.MessageType), inquireRequest.TraceId)
+	n.transmit(dst, content)
+}
+
+// addTokenToSet adds token to the tokens of the node, or sets its status if the node already holds it
+func (n *Node) addTokenToSet(token Token, status bool) {
+	for i := 0; i < len(n.tokens); i++ {
+		if n.tokens[i].id == token.id {
+			n.tokens[i].locked = status
+			return
+		}
+	}
+	token.locked = status
+	n.tokens = append(n.tokens, token)
+}
+
+func (n *Node) updateCTForRequest(request Request) {
+	var missingTokens             []int
+	var requestedResourcesForNode map[int][]int
+	requestedResourcesForNode = make(map[int][]int)
+
+	n.mutex.Lock()
+	Logger.Debug("Node #", n.id, ", updateCTForRequest")
+	var err error = n.controlToken.updateForRequest(n, request, &missingTokens, &requestedResourcesForNode)
+	if err != nil {
+		n.mutex.Unlock()
+		n.system.reportError(err)
+		return
+	}
+	for i := 0; i < len(missingTokens); {
+		if n.ownsToken(missingTokens[i]) == true {
+			missingTokens[i] = missingTokens[len(missingTokens) - 1]
+			missingTokens = missingTokens[:len(missingTokens) - 1]
+		} else {
+			i++
+		}
+	}
+	n.currentlyRequestingTokens = missingTokens
+	n.mutex.Unlock()
+
+	if len(missingTokens) == 0 {
+		Logger.Debug("Node #", n.id, ", updateCTForRequest 1 ")
+		n.enterCSIfCan();
+	} else {
+		Logger.Debug("Node #", n.id, ", updateCTForRequest 3 ", requestedResourcesForNode)
+		// each node holding missing tokens is inquired
+		for key, _ := range requestedResourcesForNode {
+			var tokens []int = requestedResourcesForNode[key]
+			Logger.Debug("Node #", n.id, ", updateCTForRequest node ", key, " holds tokens", tokens)
+			
+			go n.requestTokens(request, key, tokens);
+		}
+	}
+}
+
+func (n *Node) handleRequest(request Request) {
+	Logger.Debug("Node #", n.id," handleRequest")	
+	n.mutex.Lock()
+	var hasAllTokens bool = true
+	for i := 0; i < len(request.ResourceId); i++ {
+		if ! n.ownsToken(request.ResourceId[i]) {
+			hasAllTokens = false
+		}
+	}
+	if hasAllTokens {
+		Logger.Debug("Node #", n.id," handleRequest hasAllTokens")	
+		// locked before an INQUIRE takes them
+		for i := 0; i < len(request.ResourceId); i++ {
+			n.lockResource(request.ResourceId[i])
+		}
+		n.mutex.Unlock()
+		n.enterCSIfCan()
+	} else {
+		Logger.Debug("Node #", n.id," handleRequest NOT hasAllTokens")
+		n.currentRequest = request
+		if n.has_CT == true {
+			Logger.Debug("Node #", n.id," handleRequest NOT hasAllTokens 1")	
+			// the Control Token stays with the node until it leaves its CS
+			n.requesting = true
+			n.mutex.Unlock()
+			n.updateCTForRequest(request)
+		} else {
+			Logger.Debug("Node #", n.id," handleRequest NOT hasAllTokens 2")
+			if n.requesting == false {
+				n.requestCT()
+			}
+			n.mutex.Unlock()
+		}
+	}
+}
+
+func (n *Node) isTokenInSet(token int) bool{
+    for i:= 0; i < len(n.tokens); i ++ {
+        if n.tokens[i].id == token {
+            return true
+        }
+    }
+    return false
+}
+
+func (n *Node) isTokenLocked(token int) bool{
+    for i:= 0; i < len(n.tokens); i ++ {
+	    if n.tokens[i].id == token {
+		    if n.tokens[i].locked == BL_LOCKED {
+			    return true
+		    } else {
+			    return false
+		    }
+        }
+    }
+    return false
+}
+
+func (n *Node) removeTokenFromSet(token int) bool{
+    for i:= 0; i < len(n.tokens); i ++ {
+	    if n.tokens[i].id == token {
+		    n.tokens[i] = n.tokens[len(n.tokens) - 1]
+		    n.tokens = n.tokens[:len(n.tokens) - 1]
+		    return true
+	    }
+    }
+    return false
+}
+
+func (n *Node) isTokenNeeded(token int) bool{
+    for i:= 0; i < len(n.tokensNeeded); i ++ {
+        if n.tokensNeeded[i] == token {
+            return true
+        }
+    }
+    return false
+}
+
+func (n *Node) receiveInquire(request Request) {
+	Logger.Debug("** Node #", n.id, "  receiveInquire ******************")
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var requester int = request.RequesterNodeId
+	var sentTokens []int
+	var notSentTokens []int
+	for i := 0; i < len(request.ResourceId); i++ {
+		var token int = request.ResourceId[i]
+		Logger.Debug("** Node #", n.id, "  receiveInquire i=", i, " token=", token)
+		if n.isTokenInSet(token) && !n.isTokenLocked(token) {
+			Logger.Debug("removeFromSet", token, "n ",n)
+			n.removeTokenFromSet(token)			
+			sentTokens = append(sentTokens, token)
+			Logger.Debug("removeFromSet", token, "n ", n, " end")
+		} else {
+			notSentTokens = append(notSentTokens, token)
+			Logger.Debug("notSentTokens", notSentTokens)
+		}
+	}
+
+	n.waitingSet[requester] = make([]int, len(notSentTokens))
+	copy (n.waitingSet[requester], notSentTokens)
+	
+	if len(sentTokens) > 0 {
+		go n.sendACK1(&sentTokens, requester)
+	} else {
+		Logger.Debug("** Node #", n.id, ", no ACK1 sent")
+	}
+	Logger.Debug("n=", n)
+}
+
+func (n *Node) sendACK1(sentTokens *([]int), dst int) {
+	var ack1Request Request
+	ack1Request.MessageType = ACK1_TYPE
+	ack1Request.RequesterNodeId = n.id
+		
+	ack1Request.ResourceId = make([]int, len(*sentTokens))
+	copy(ack1Request.ResourceId, *sentTokens)
+	ack1Request.TraceId = n.system.Tracer.NextId()
+	ack1Request.TraceSender = n.id
+	content, err := MarshalRequest(ack1Request)
+	if err != nil {
+		n.system.reportError(err)
+		return
+	}			
+	// Logger.Debug("Node #", n.id, ", send ACK1 #", ack1Request.RequestId, ":", content, " to Node #", dst, " with tokens", ack1Request.ResourceId, ", routine #", getGID())	
+	Logger.Debug("Node #", n.id, ", send ACK1 #", ack1Request.RequestId, " to Node #", dst, " with tokens", ack1Request.ResourceId, ", routine #", getGID())	
+	n.system.Tracer.Send(n.id, dst, messageName(ack1Request.MessageType), ack1Request.TraceId)
+	n.transmit(dst, content)
+}
+
+func (n *Node) sendACK2(tokens *([]int), dst int) {
+	var ack2Request Request
+	ack2Request.MessageType = ACK2_TYPE
+	ack2Request.RequesterNodeId = n.id
+		
+	ack2Request.ResourceId = make([]int, len(*tokens))
+	copy(ack2Request.ResourceId, *tokens)
+	ack2Request.TraceId = n.system.Tracer.NextId()
+	ack2Request.TraceSender = n.id
+	content, err := MarshalRequest(ack2Request)
+	if err != nil {
+		n.system.reportError(err)
+		return
+	}			
+	// Logger.Debug("Node #", n.id, ", send ACK2 #", ack2Request.RequestId, ":", content, " to Node #", dst, " with tokens", ack2Request.ResourceId, ", routine #", getGID())	
+	Logger.Debug("Node #", n.id, ", send ACK2 #", ack2Request.RequestId, " to Node #", dst, " with tokens", ack2Request.ResourceId, ", routine #", getGID())	
+	n.system.Tracer.Send(n.id, dst, messageName(ack2Request.MessageType), ack2Request.TraceId)
+	n.transmit(dst, content)
+}
+
+func (n *Node) receiveACK1(request Request) {
+	Logger.Debug("** Node #", n.id, "  receiveACK1 *******", ", routine #", getGID())
+	var requestTokens []int = request.ResourceId
+	n.mutex.Lock()
+	for i := 0; i < len(requestTokens); i ++ {
+		var token Token
+		token.id = requestTokens[i]
+		Logger.Debug("** Node #", n.id, "  receiveACK1, token ", token.id, " received")
+		token.locked = BL_LOCKED		
+		n.addTokenToSet(token, BL_LOCKED)
+		
+		n.removeTokenFromCurrentlyRequestingTokens(requestTokens[i])
+	}
+	Logger.Debug("Node #", n.id, " is still waiting for ", len(n.currentlyRequestingTokens), " tokens:", n.currentlyRequestingTokens)
+	n.mutex.Unlock()
+	n.enterCSIfCan()
+}
+
+func (n *Node) removeTokenFromCurrentlyRequestingTokens(token int)  {
+	for i := 0; i < len(n.currentlyRequestingTokens); i ++ {
+		if token == n.currentlyRequestingTokens[i] {
+			n.currentlyRequestingTokens[i] = n.currentlyRequestingTokens[len(n.currentlyRequestingTokens) - 1]
+			n.currentlyRequestingTokens = n.currentlyRequestingTokens[:len(n.currentlyRequestingTokens) - 1]				
+			break
+		}
+	}
+}
+func (n *Node) receiveACK2(request Request) bool {
+	Logger.Debug("** Node #", n.id, "  receiveACK2 *******", ", routine #", getGID())
+	var requestTokens []int = request.ResourceId
+
+	n.mutex.Lock()
+	for i := 0; i < len(requestTokens); i ++ {
+		var token Token
+		token.id = requestTokens[i]
+		Logger.Debug("** Node #", n.id, "  receiveACK2, token ", token.id, "received")
+		token.locked = BL_LOCKED		
+		n.addTokenToSet(token, BL_LOCKED)
+
+		n.removeTokenFromCurrentlyRequestingTokens(requestTokens[i])
+	}
+	Logger.Debug("Node #", n.id, " is still waiting for ", len(n.currentlyRequestingTokens), " tokens:", n.currentlyRequestingTokens)
+	n.mutex.Unlock()
+	n.enterCSIfCan()
+	return true
+}
+
+func (n *Node) receiveCT(ct *ControlToken) {
+	Logger.Debug("** Node #", n.id, " Got TOKEN **", ", routine #", getGID())
+	if ct == nil {
+		n.system.reportError(fmt.Errorf("Node #%d received REP_CT without the Control Token", n.id))
+		return
+	}
+	// the index of the tokens is not sent
+	ct.rebuild()
+	Logger.Debug("** Node #", n.id, ", CT=", ct.String())
+	n.mutex.Lock()
+ 	Logger.Debug("** Node #", n.id, " needs **", n.currentRequest.ResourceId)
+	n.controlToken = ct
+	n.has_CT = true
+	n.traceState()
+ 	Logger.Debug("** Node #", n.id, " tokens before=", n.tokens)
+	for _, possessed := range ct.TokensOf(n.id) {
+		if !n.isTokenInSet(possessed) {
+			var t Token
+			t.id = possessed
+			n.addTokenToSet(t, BL_FREE)
+		}
+	}
+ 	Logger.Debug("** Node #", n.id, " tokens after=", n.tokens)
+	
+	for i := 0; i < len(n.currentRequest.ResourceId); i++ {
+		var token int = n.currentRequest.ResourceId[i]
+		if !n.isTokenInSet(token) && !n.isTokenNeeded(token) {
+			n.tokensNeeded = append (n.tokensNeeded, token)
+		} else {
+			n.lockResource(token)
+		}
+	}
+	var request Request = n.currentRequest
+	n.mutex.Unlock()
+
+	n.updateCTForRequest(request)
+	Logger.Debug("** Node #", n.id, ", END receiveCT")
+}
+
+// transmit sends content to dst, unless the node is stopped before dst receives it
+func (n *Node) transmit(dst int, content bytes.Buffer) {
+	select {
+	case n.Messages[dst] <- content:
+		n.system.countMessage()
+	case <-n.stop:
+	}
+}
+
+func (n *Node) rcv() {	
+	Logger.Debug("Node #", n.id," rcv", ", routine #", getGID())	
+	for {
+		select {
+		case <-n.stop:
+			return
+		case msg := <-n.Messages[n.id]:
+			var request Request
+			err := UnmarshalRequest(msg, &request)
+			if err != nil {
+				n.system.reportError(fmt.Errorf("Node #%d: %v", n.id, err))
+				continue
+			}			
+			var requester = request.RequesterNodeId
+			n.system.Tracer.Receive(n.id, request.TraceSender, messageName(request.MessageType), request.TraceId)
+			if (request.MessageType == REP_TYPE) {
+				// Logger.Info("Node #", n.id, ", received REPLY from Node #", requester, ",", msg)
+				Logger.Info("Node #", n.id, ", received REPLY from Node #", requester)
+			} else if (request.MessageType == REQ_CT_TYPE) {
+				// Logger.Info("Node #", n.id, ", received REQUEST Control Token from Node #", requester, ",", msg)
+				Logger.Info("Node #", n.id, ", received REQUEST Control Token from Node #", requester)
+				n.mutex.Lock()
+				n.handleCTRequest(request)
+				n.mutex.Unlock()
+			} else if (request.MessageType == REP_CT_TYPE) {
+				// Logger.Info("Node #", n.id, ", received REPLY Control Token from Node #", requester, ",", msg)
+				Logger.Info("Node #", n.id, ", received REPLY Control Token from Node #", requester)
+				go n.receiveCT(request.CT)
+			} else if (request.MessageType == INQUIRE_TYPE) {
+				// Logger.Info("Node #", n.id, ", received INQUIRE from Node #", requester, ",", msg)
+				Logger.Info("Node #", n.id, ", received INQUIRE from Node #", requester)
+				go n.receiveInquire(request)
+			} else if (request.MessageType == ACK1_TYPE) {
+				// Logger.Info("Node #", n.id, ", received ACK1 from Node #", requester, ",", msg)
+				Logger.Info("Node #", n.id, ", received ACK1 from Node #", requester)
+				go n.receiveACK1(request)
+			} else if (request.MessageType == ACK2_TYPE) {
+				// Logger.Info("Node #", n.id, ", received ACK2 from Node #", requester, ",", msg)
+				Logger.Info("Node #", n.id, ", received ACK2 from Node #", requester)
+				go n.receiveACK2(request)
+			} else {
+				n.system.reportError(fmt.Errorf("Node #%d received the unknown message type %d", n.id, request.MessageType))
+			}
+		}
+	}
+}
+
+// requestCT asks the Control Token to the last requester known by the node,
+// called with the mutex held
+func (n *Node) requestCT() {
+	Logger.Debug(n)
+	var request Request
+	request.RequesterNodeId = n.id
+	request.RequestId = 0
+	request.MessageType = REQ_CT_TYPE
+	request.TraceId = n.system.Tracer.NextId()
+	request.TraceSender = n.id
+	
+	content, err := MarshalRequest(request)
+	if err != nil {
+		n.system.reportError(err)
+		return
+	}			
+	// Logger.Debug("Node #", n.id, ",  REQUEST CT #", request.RequestId, ":", content, " to Node #", n.last)
+	Logger.Debug("Node #", n.id, ",  REQUEST CT #", request.RequestId, " to Node #", n.last)
+	n.requesting = true	
+
+	n.system.Tracer.Send(n.id, n.last, messageName(request.MessageType), request.TraceId)
+	go n.transmit(n.last, content)
+
+	n.last = -1		
+}
+
+func (n *Node) buildRequest(resources []int) Request {
+	var request Request
+	request.MessageType = REQ_TYPE
+	request.RequesterNodeId = n.id
+	request.RequestId = n.RequestIdCounter
+	n.RequestIdCounter ++
+	request.ResourceId = resources
+	return request
+}
+
+// checkResources returns an error if ids are not distinct resources among nbResources
+func checkResources(ids []int, nbResources int) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("no resource requested")
+	}
+	var seen = make(map[int]bool)
+	for _, id := range ids {
+		if id < 0 || id >= nbResources {
+			return fmt.Errorf("resource #%d not in [0, %d)", id, nbResources)
+		}
+		if seen[id] {
+			return fmt.Errorf("resource #%d requested twice", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// sameResources returns true if ids1 and ids2 are the same distinct resources
+func sameResources(ids1 []int, ids2 []int) bool {
+	if len(ids1) != len(ids2) {
+		return false
+	}
+	var set = make(map[int]bool)
+	for _, id := range ids1 {
+		set[id] = true
+	}
+	for _, id := range ids2 {
+		if !set[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// AcquireResources blocks until the node holds the tokens of the resources
+// ids, the node is then in its CS until Release. If ctx is done before, it
+// returns the error of ctx and the tokens are released as soon as the node
+// gets them, unless AcquireResources is called again with the same resources
+func (n *Node) AcquireResources(ctx context.Context, ids []int) error {
+	if err := checkResources(ids, n.system.nbNodes); err != nil {
+		return fmt.Errorf("Node #%d: %v", n.id, err)
+	}
+	n.mutex.Lock()
+	// the request abandoned by a previous call is still pending, it is taken
+	// over if it has the same resources, otherwise it is released first
+	for n.acquiring != nil && n.abandoned && (n.inBLCS || !sameResources(n.acquiring.ResourceId, ids)) {
+		var released chan bool = n.released
+		n.mutex.Unlock()
+		select {
+		case <-released:
+		case <-n.stop:
+			return fmt.Errorf("Node #%d is stopped", n.id)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		n.mutex.Lock()
+	}
+	if n.acquiring != nil && !n.abandoned {
+		n.mutex.Unlock()
+		return fmt.Errorf("Node #%d already requests resources %v", n.id, n.acquiring.ResourceId)
+	}
+	if n.acquiring != nil {
+		Logger.Debug("Node #", n.id, " takes over the request of ", n.acquiring.ResourceId)
+		n.abandoned = false
+		n.mutex.Unlock()
+	} else {
+		var request Request = n.buildRequest(append([]int(nil), ids...))
+		n.acquiring = &request
+		n.released = make(chan bool)
+		n.mutex.Unlock()
+		n.system.Recorder.Request(n.id)
+
+		Logger.Debug("Node #", n.id, "<-REQ#", request.RequestId, ", nb of res:", len(request.ResourceId), " res ", request.ResourceId)
+		n.handleRequest(request)
+	}
+
+	select {
+	case <-n.granted:
+		return nil
+	case <-n.stop:
+		return fmt.Errorf("Node #%d is stopped", n.id)
+	case <-ctx.Done():
+		n.mutex.Lock()
+		defer n.mutex.Unlock()
+		// the request cannot be withdrawn from the other nodes
+		select {
+		case <-n.granted:
+			return nil
+		default:
+		}
+		n.abandoned = true
+		return ctx.Err()
+	}
+}
+
+// Release leaves the CS entered with AcquireResources: the tokens are sent to
+// the nodes waiting for them, and the Control Token to the next requester
+func (n *Node) Release() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.inBLCS == false {
+		return
+	}
+	n.releaseCS()
+	n.endRequest()
+}
+
+// endRequest forgets the request of AcquireResources once its tokens are
+// given back, called with the mutex held
+func (n *Node) endRequest() {
+	n.inBLCS = false
+	n.acquiring = nil
+	n.abandoned = false
+	close(n.released)
+
+	// Send the Control Token
+	n.requesting = false
+	
+	// Finished using the Control Token, keep it going if there is a Next
+	if n.next != -1 {
+		n.sendCT(n.next)
+		n.last = n.next
+		n.next = -1
+	}
+}
+
+// BouabdallahLaforest requests the resources of the workload until the nodes
+// entered their CS nbIterations times in total
+func (n *Node) BouabdallahLaforest(wg *sync.WaitGroup) {
+	Logger.Debug("Node #", n.id)
+
+	for n.system.iterationsDone() == false {
+		var next Workload.Request = n.workload.Next()
+		time.Sleep(next.Think)
+		if err := n.AcquireResources(context.Background(), next.Resources); err != nil {
+			n.system.reportError(err)
+			break
+		}
+		n.executeCSCode(next.CS)
+		n.Release()
+	}
+
+	Logger.Debug("Node #", n.id," END after ", n.system.nbIterations," CS entries")
+	wg.Done()
+}
+
+// Start starts the routines receiving the messages of the nodes
+func (s *System) Start() {
+	for i := 0; i < len(s.Nodes); i++ {
+		go s.Nodes[i].rcv()
+	}
+}
+
+// Stop ends the routines of all the nodes once the run is over
+func (s *System) Stop() {
+	for i := 0; i < len(s.Nodes); i++ {
+		close(s.Nodes[i].stop)
+	}
+}
+
+// New creates the nodes of a run, each one with the token of the resource of
+// its id, the workload must have one resource per node
+func New(nbNodes int, nbIterations int, workload *Workload.Config, seed int64) (*System, error) {
+	Logger.Info("BouabdallahLaforest.New, nb_process #", nbNodes, ", seed ", seed, ", workload ", workload)
+	if workload.NbResources != nbNodes {
+		return nil, fmt.Errorf("BouabdallahLaforest needs one resource per node, %d resources for %d nodes", workload.NbResources, nbNodes)
+	}
+	var s = &System{nbNodes: nbNodes, nbIterations: nbIterations}
+	s.resourceUser = make(map[int]int)
+	s.failure = make(chan error, 1)
+
+	s.Nodes = make([]Node, nbNodes)
+	var messages = make([]chan bytes.Buffer, nbNodes)
+
+	// Initialization
+	for i := 0; i < nbNodes; i++ {
+		var n *Node = &s.Nodes[i]
+		n.system = s
+		n.id = i
+		n.NbCS = 0
+		
+		n.requesting = false
+		n.next = -1
+		n.last = 0
+		n.RequestIdCounter = i * 10
+		
+		// Initially the first node holds the Control Token
+		if n.last == n.id {
+			n.has_CT = true
+			n.last = -1
+		} else {
+			n.has_CT = false
+		}
+
+		messages[i] = make(chan bytes.Buffer)
+		
+		n.waitingSet = make(map[int][]int)
+
+		var t Token
+		t.id = i
+		t.locked = false
+		// Initially each node owns its token
+		n.tokens = append(n.tokens, t)
+		n.workload = workload.Generator(i, seed + int64(i))
+		n.granted = make(chan bool, 1)
+		n.stop = make(chan bool)
+	}
+	// the Control Token, where each node holds the token of its id in B, the
+	// first node holds it and the others never see it until they receive it
+	var controlToken *ControlToken = NewControlToken(nbNodes)
+	for i := 0; i < nbNodes; i++ {
+		if err := controlToken.Move(i, i); err != nil {
+			return nil, err
+		}
+	}
+	Logger.Debug(controlToken.String())
+	s.Nodes[0].controlToken = controlToken
+	
+	for i := 0; i < nbNodes; i++ {
+		s.Nodes[i].Messages = messages
+	}
+	return s, nil
+}
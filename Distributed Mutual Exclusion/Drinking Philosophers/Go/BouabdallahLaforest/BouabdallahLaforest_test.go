@@ -0,0 +1,138 @@
+package BouabdallahLaforest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"drinking/internal/testutil"
+	"drinking/Stats"
+	"drinking/Workload"
+)
+
+// run runs a system until the nodes entered their CS nbIterations times
+func run(t *testing.T, nbNodes int, nbIterations int, seed int64) *System {
+	var workload *Workload.Config = testutil.NewWorkload(t, nbNodes, 3)
+	system, err := New(nbNodes, nbIterations, workload, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	system.Recorder = Stats.New()
+	var tracer *testutil.Tracer = testutil.NewTracer()
+	system.Tracer = tracer.Tracer
+	system.Start()
+	var wg sync.WaitGroup
+	for i := 0; i < nbNodes; i++ {
+		wg.Add(1)
+		go system.Nodes[i].BouabdallahLaforest(&wg)
+	}
+	testutil.Wait(t, &wg, time.Minute)
+	system.Stop()
+	testutil.CheckExclusion(t, tracer, testutil.Requests(workload, seed))
+	select {
+	case err := <-system.Failure():
+		t.Error("seed ", seed, ": ", err)
+	default:
+	}
+	var nbCS int = 0
+	for i := 0; i < nbNodes; i++ {
+		nbCS += system.Nodes[i].NbCS
+	}
+	if nbCS < nbIterations {
+		t.Error("seed ", seed, ": ", nbCS, " CS entries instead of ", nbIterations)
+	}
+	if system.NbMsg() == 0 {
+		t.Error("seed ", seed, ": no message sent")
+	}
+	return system
+}
+
+// The systems share nothing, they run at the same time
+func TestSystems(t *testing.T) {
+	var wg sync.WaitGroup
+	for seed := int64(1); seed <= 3; seed++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			run(t, 4, 20, seed)
+		}(seed)
+	}
+	wg.Wait()
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(4, 10, testutil.NewWorkload(t, 5, 1), 1); err == nil {
+		t.Error("more resources than nodes accepted")
+	}
+}
+
+// lockSystem returns a started system of nbNodes nodes used as a lock
+func lockSystem(t *testing.T, nbNodes int) *System {
+	system, err := New(nbNodes, 0, testutil.NewWorkload(t, nbNodes, 1), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	system.Start()
+	return system
+}
+
+// A request canceled before its grant is taken over by the next request with
+// the same resources, and its tokens given back on its grant otherwise,
+// without entering the CS
+func TestCancel(t *testing.T) {
+	for _, retry := range [][]int{{0, 1}, {1}} {
+		var system *System = lockSystem(t, 3)
+		var n0, n1 *Node = &system.Nodes[0], &system.Nodes[1]
+		if err := n0.AcquireResources(context.Background(), []int{0}); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+		if err := n1.AcquireResources(ctx, []int{0, 1}); err != context.DeadlineExceeded {
+			t.Error("acquired resource #0 held by Node #0: ", err)
+		}
+		cancel()
+		var acquired = make(chan error, 1)
+		go func() {
+			acquired <- n1.AcquireResources(context.Background(), retry)
+		}()
+		select {
+		case err := <-acquired:
+			t.Fatal("retry ", retry, " did not wait for Node #0: ", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		n0.Release()
+		select {
+		case err := <-acquired:
+			if err != nil {
+				t.Error("retry ", retry, ": ", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("retry ", retry, " not granted")
+		}
+		n1.Release()
+		// the abandoned request did not enter the CS
+		n1.mutex.Lock()
+		if n1.NbCS != 1 {
+			t.Error("retry ", retry, ": Node #1 entered its CS ", n1.NbCS, " times")
+		}
+		n1.mutex.Unlock()
+		system.mutex.Lock()
+		if system.currentIteration != 2 {
+			t.Error("retry ", retry, ": ", system.currentIteration, " CS entries")
+		}
+		system.mutex.Unlock()
+		// resource #0 is free again
+		ctx, cancel = context.WithTimeout(context.Background(), 5 * time.Second)
+		if err := system.Nodes[2].AcquireResources(ctx, []int{0}); err != nil {
+			t.Error("retry ", retry, ", resource #0 not released: ", err)
+		}
+		cancel()
+		system.Stop()
+		select {
+		case err := <-system.Failure():
+			t.Error("retry ", retry, ": ", err)
+		default:
+		}
+	}
+}
@@ -0,0 +1,161 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Control Token of Bouabdallah-Laforest: each resource token is either free,
+in the set A of the Control Token, or in the set B of the last node it was
+given to.
+
+Owner maps each token to its node, or to FREE_TOKEN when it is in A, so a
+token is in exactly one set by construction and its owner is found in O(1).
+The tokens of each set are indexed by holders, the reverse index of Owner,
+which is not serialized and is rebuilt when the Control Token is received.
+Move is the only operation changing the sets, it updates Owner and the index
+together.
+*/
+
+package BouabdallahLaforest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FREE_TOKEN is the owner of the tokens in A
+var FREE_TOKEN int = -1
+
+type ControlToken struct {
+	Owner   map[int]int          // token => node holding it in B, FREE_TOKEN if it is in A
+	holders map[int]map[int]bool // node => its tokens, FREE_TOKEN => A, rebuilt from Owner
+}
+
+// NewControlToken returns the Control Token with the tokens 0 to nbTokens - 1 free
+func NewControlToken(nbTokens int) *ControlToken {
+	var ct ControlToken
+	ct.Owner = make(map[int]int)
+	for token := 0; token < nbTokens; token++ {
+		ct.Owner[token] = FREE_TOKEN
+	}
+	ct.rebuild()
+	return &ct
+}
+
+// rebuild builds the index of the tokens of each node from Owner
+func (ct *ControlToken) rebuild() {
+	if ct.Owner == nil {
+		ct.Owner = make(map[int]int)
+	}
+	ct.holders = make(map[int]map[int]bool)
+	for token, owner := range ct.Owner {
+		if ct.holders[owner] == nil {
+			ct.holders[owner] = make(map[int]bool)
+		}
+		ct.holders[owner][token] = true
+	}
+}
+
+func sortedTokens(set map[int]bool) []int {
+	var tokens = make([]int, 0, len(set))
+	for token := range set {
+		tokens = append(tokens, token)
+	}
+	sort.Ints(tokens)
+	return tokens
+}
+
+func (ct *ControlToken) String() string {
+	if ct == nil {
+		return "ControlToken not held"
+	}
+	var nodes []int
+	for node := range ct.holders {
+		if node != FREE_TOKEN && len(ct.holders[node]) > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Ints(nodes)
+	var B []string
+	for _, node := range nodes {
+		B = append(B, strconv.Itoa(node) + fmt.Sprint(ct.TokensOf(node)))
+	}
+	return fmt.Sprintf("ControlToken A=%v, B={%s}", ct.FreeTokens(), strings.Join(B, ", "))
+}
+
+// FreeTokens returns the tokens of A, sorted
+func (ct *ControlToken) FreeTokens() []int {
+	return sortedTokens(ct.holders[FREE_TOKEN])
+}
+
+// TokensOf returns the tokens of node in B, sorted
+func (ct *ControlToken) TokensOf(node int) []int {
+	return sortedTokens(ct.holders[node])
+}
+
+// OwnerOf returns the node holding token in B, FREE_TOKEN if it is in A, and
+// false if the token is unknown
+func (ct *ControlToken) OwnerOf(token int) (int, bool) {
+	owner, ok := ct.Owner[token]
+	return owner, ok
+}
+
+func (ct *ControlToken) IsFree(token int) bool {
+	owner, ok := ct.Owner[token]
+	return ok && owner == FREE_TOKEN
+}
+
+// Move moves token to the set B of node to, or to A if to is FREE_TOKEN
+func (ct *ControlToken) Move(token int, to int) error {
+	from, ok := ct.Owner[token]
+	if !ok {
+		return fmt.Errorf("token #%d is not in the Control Token", token)
+	}
+	if to < FREE_TOKEN {
+		return fmt.Errorf("token #%d cannot be moved to node #%d", token, to)
+	}
+	if from == to {
+		return nil
+	}
+	delete(ct.holders[from], token)
+	if ct.holders[to] == nil {
+		ct.holders[to] = make(map[int]bool)
+	}
+	ct.holders[to][token] = true
+	ct.Owner[token] = to
+	return nil
+}
+
+// Free moves token to A
+func (ct *ControlToken) Free(token int) error {
+	return ct.Move(token, FREE_TOKEN)
+}
+
+// Check returns an error if the tokens 0 to nbTokens - 1 are not each in
+// exactly one set, or if the index does not match Owner
+func (ct *ControlToken) Check(nbTokens int) error {
+	if len(ct.Owner) != nbTokens {
+		return fmt.Errorf("%d tokens in the Control Token instead of %d", len(ct.Owner), nbTokens)
+	}
+	for token := 0; token < nbTokens; token++ {
+		owner, ok := ct.Owner[token]
+		if !ok {
+			return fmt.Errorf("token #%d is missing from the Control Token", token)
+		}
+		if !ct.holders[owner][token] {
+			return fmt.Errorf("token #%d of node #%d is missing from the index", token, owner)
+		}
+	}
+	var nbIndexed int = 0
+	for node, tokens := range ct.holders {
+		for token := range tokens {
+			if owner, ok := ct.Owner[token]; !ok || owner != node {
+				return fmt.Errorf("token #%d is indexed for node #%d instead of #%d", token, node, owner)
+			}
+			nbIndexed ++
+		}
+	}
+	if nbIndexed != nbTokens {
+		return fmt.Errorf("%d tokens in the index instead of %d", nbIndexed, nbTokens)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package BouabdallahLaforest
+
+import (
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+var NB_TEST_TOKENS int = 5000
+var NB_TEST_NODES  int = 64
+
+// model is the owner of each token, the reference of the properties
+type model map[int]int
+
+// checkAgainst returns false if ct does not hold the tokens as in m
+func checkAgainst(t *testing.T, ct *ControlToken, m model) bool {
+	if err := ct.Check(NB_TEST_TOKENS); err != nil {
+		t.Log(err)
+		return false
+	}
+	var nbTokens int = len(ct.FreeTokens())
+	for node := 0; node < NB_TEST_NODES; node++ {
+		for _, token := range ct.TokensOf(node) {
+			if m[token] != node {
+				t.Log("token #", token, " of node #", node, " is owned by #", m[token])
+				return false
+			}
+		}
+		nbTokens += len(ct.TokensOf(node))
+	}
+	for token, owner := range m {
+		if got, ok := ct.OwnerOf(token); !ok || got != owner {
+			t.Log("token #", token, " is owned by #", got, " instead of #", owner)
+			return false
+		}
+	}
+	return nbTokens == NB_TEST_TOKENS
+}
+
+func newModel() model {
+	var m = make(model)
+	for token := 0; token < NB_TEST_TOKENS; token++ {
+		m[token] = FREE_TOKEN
+	}
+	return m
+}
+
+func TestMovesKeepEachTokenOnce(t *testing.T) {
+	var property = func(seed int64) bool {
+		var r = rand.New(rand.NewSource(seed))
+		var ct *ControlToken = NewControlToken(NB_TEST_TOKENS)
+		var m model = newModel()
+		for k := 0; k < 20000; k++ {
+			// some tokens and destinations are invalid
+			var token int = r.Intn(NB_TEST_TOKENS + 10)
+			var to int = r.Intn(NB_TEST_NODES + 2) - 2
+			var err error = ct.Move(token, to)
+			var valid bool = token < NB_TEST_TOKENS && to >= FREE_TOKEN
+			if valid != (err == nil) {
+				t.Log("move of token #", token, " to #", to, ": ", err)
+				return false
+			}
+			if valid {
+				m[token] = to
+			}
+		}
+		return checkAgainst(t, ct, m)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 20}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestControlTokenInMessages(t *testing.T) {
+	var property = func(seed int64) bool {
+		var r = rand.New(rand.NewSource(seed))
+		var ct *ControlToken = NewControlToken(NB_TEST_TOKENS)
+		var m model = newModel()
+		for token := 0; token < NB_TEST_TOKENS; token++ {
+			m[token] = r.Intn(NB_TEST_NODES + 1) - 1
+			ct.Move(token, m[token])
+		}
+		var request Request
+		request.MessageType = REP_CT_TYPE
+		request.CT = ct
+		content, err := MarshalRequest(request)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		var received Request
+		if err = UnmarshalRequest(content, &received); err != nil {
+			t.Log(err)
+			return false
+		}
+		received.CT.rebuild()
+		return checkAgainst(t, received.CT, m) && received.CT.String() == ct.String()
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 10}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckFindsCorruption(t *testing.T) {
+	var ct *ControlToken = NewControlToken(NB_TEST_TOKENS)
+	delete(ct.Owner, 42)
+	if ct.Check(NB_TEST_TOKENS) == nil {
+		t.Error("missing token not found")
+	}
+
+	ct = NewControlToken(NB_TEST_TOKENS)
+	ct.holders[7] = map[int]bool{42: true}
+	if ct.Check(NB_TEST_TOKENS) == nil {
+		t.Error("token indexed twice not found")
+	}
+
+	ct = NewControlToken(NB_TEST_TOKENS)
+	ct.Owner[42] = 3
+	if ct.Check(NB_TEST_TOKENS) == nil {
+		t.Error("owner out of the index not found")
+	}
+}
+
+// updateForRequest gives the requested tokens to the requester, and reports
+// as missing the ones that other nodes hold
+func TestUpdateForRequest(t *testing.T) {
+	var system = &System{nbNodes: NB_TEST_TOKENS}
+	var property = func(seed int64) bool {
+		var r = rand.New(rand.NewSource(seed))
+		var ct *ControlToken = NewControlToken(NB_TEST_TOKENS)
+		var m model = newModel()
+		var nodes = make([]Node, NB_TEST_NODES)
+		for i := 0; i < NB_TEST_NODES; i++ {
+			nodes[i].id = i
+			nodes[i].system = system
+		}
+		for k := 0; k < 200; k++ {
+			var n *Node = &nodes[r.Intn(NB_TEST_NODES)]
+			var request Request
+			request.ResourceId = r.Perm(NB_TEST_TOKENS)[:1 + r.Intn(20)]
+			var missingTokens []int
+			var requestedResourcesForNode = make(map[int][]int)
+			if err := ct.updateForRequest(n, request, &missingTokens, &requestedResourcesForNode); err != nil {
+				t.Log(err)
+				return false
+			}
+			var nbMissing int = 0
+			for _, token := range request.ResourceId {
+				if m[token] != FREE_TOKEN && m[token] != n.id {
+					nbMissing ++
+				}
+			}
+			if nbMissing != len(missingTokens) {
+				t.Log(len(missingTokens), " missing tokens instead of ", nbMissing)
+				return false
+			}
+			for owner, tokens := range requestedResourcesForNode {
+				for _, token := range tokens {
+					if m[token] != owner {
+						t.Log("token #", token, " requested from #", owner, " owned by #", m[token])
+						return false
+					}
+				}
+			}
+			// the tokens of the node that it did not request again are free
+			for token, owner := range m {
+				if owner == n.id {
+					m[token] = FREE_TOKEN
+				}
+			}
+			for _, token := range request.ResourceId {
+				m[token] = n.id
+			}
+			if !checkAgainst(t, ct, m) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 5}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,338 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+How-to run: 
+  go run ./cmd/drinking --algo=ChandyMisra 2>&1 |tee /tmp/tmp.log
+
+Parameters:
+- Number of nodes
+- Number of iterations
+- Conflict graph: the philosophers share a fork with their neighbors only, see
+  the Topology package. The forks start in the hand of the neighbor with the
+  lowest id, which keeps the precedence graph acyclic for any topology
+
+The forks are handled by the chandymisra subroutine of the Dining package,
+the same as the dining layer of Rhee and ChandyMisraDrinking: this package
+carries its messages between the philosophers, runs their CS, and tells it
+what the failure detector suspects.
+
+Fault-tolerant mode:
+  When a failure detector is enabled with EnableFailureDetector, a philosopher
+  does not wait anymore for the forks it shares with a suspected neighbor, it
+  uses them as if they were its own, and it does not send them forks anymore,
+  see Dining.Suspecter.
+  When the neighbor is trusted again, forks are requested again as usual. A
+  wrong suspicion can let two neighbors eat at the same time, so the strategy
+  of the failure detector must be chosen so that mistakes are rare.
+  When faults are injected, the heartbeats go through the network too: a
+  partition makes the philosophers on the other side suspected.
+*/ 
+
+/*
+    Go implementation of Chandy-Misra mutual exclusion algorithm
+
+References :
+* https://www.cs.utexas.edu/users/misra/scannedPdf.dir/DrinkingPhil.pdf: Chandy, K.M.; Misra, J. (1984). The Drinking Philosophers Problem. ACM Transactions on Programming Languages and Systems.
+* https://en.wikipedia.org/wiki/Dining_philosophers_problem#Chandy/Misra_solution
+
+Message complexity is O(Log(n)) where N is the number of philosophers
+*/
+
+package ChandyMisra
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"drinking/Dining"
+	"drinking/FailureDetector"
+	"drinking/Stats"
+	"drinking/Topology"
+	"drinking/Trace"
+)
+
+/* global variable declaration */
+var NB_MSG            int = 0
+var CURRENT_ITERATION int = 0
+var CS_DURATION       time.Duration = 500 * time.Millisecond
+
+var STATE_THINKING    int = 0
+var STATE_HUNGRY      int = 1
+var STATE_EATING      int = 2
+
+var Philosophers []Philosopher
+
+// globalMutex protects the variables shared by all the philosophers: NB_MSG,
+// CURRENT_ITERATION and the state of the philosophers, which checkSanity reads
+// for all of them. A philosopher holds it while it handles a message or an
+// event of its failure detector, and releases it while eating
+var globalMutex sync.Mutex
+
+// running are the routines of the philosophers, Stop waits for them
+var running sync.WaitGroup
+
+// Tracer records the execution when set, see the Trace package.
+// Fork messages carry no id, they are matched in order on each link
+var Tracer *Trace.Tracer
+
+// Recorder records the response times when set, see the Stats package
+var Recorder *Stats.Recorder
+
+// checkSanity logs an error if a neighbor of philosopher id that did not
+// crash eats with it, which only a wrong suspicion allows. Called with
+// globalMutex held
+func checkSanity(id int) {
+	for _, j := range Philosophers[id].Neighbors {
+		if Philosophers[j].State == STATE_EATING && !Philosophers[j].Crashed {
+			log.Print("ERR Sanity Check philosopher #", id, " eats with its neighbor #", j)
+		}
+	}
+}
+
+// Message between two neighbors, about the fork of their edge
+type Message struct {
+	Type   int // Dining.REQUEST_FORK or Dining.SEND_FORK
+	From   int
+	ForkId int // see ForkId
+}
+
+// ForkId returns the id of the fork shared by the philosophers i and j
+func ForkId(i int, j int, nbNodes int) int {
+	return min(i, j) * nbNodes + max(i, j)
+}
+
+type Philosopher struct {
+	Id           int
+	Neighbors    []int // one fork is shared with each of them
+	State        int
+	NbCS         int
+	Messages     []chan Message
+	NbNodes      int
+	NbIterations int
+	Detector     *FailureDetector.FailureDetector
+	Crashed      bool
+	dining       Dining.Subroutine
+	stop         chan bool
+	stopped      bool
+}
+
+func (p *Philosopher) String() string {
+	return fmt.Sprintf("Philosopher #%d, state=%d, neighbors=%v", p.Id, p.State, p.Neighbors)
+}
+
+// traceState records the state of the philosopher
+func (p *Philosopher) traceState() {
+	if Tracer == nil {
+		return
+	}
+	var state = map[string]interface{}{
+		"State": p.State,
+	}
+	Tracer.State(p.Id, state)
+}
+
+// EnterCS is called by the dining subroutine with globalMutex held, the
+// philosopher eats in a different routine
+func (p *Philosopher) EnterCS() {
+	log.Print("Philosopher #", p.Id, " ######################### Philosopher.EnterCS")
+	p.State = STATE_EATING
+	p.NbCS ++
+	CURRENT_ITERATION ++
+	Recorder.EnterCS(p.Id)
+	Tracer.EnterCS(p.Id)
+	p.traceState()
+	checkSanity(p.Id)
+	running.Add(1)
+	go p.ExecuteCSCode()
+}
+
+// ExecuteCSCode eats without globalMutex, then releases the CS and requests
+// it again, unless the philosopher crashed or was stopped meanwhile
+func (p *Philosopher) ExecuteCSCode() {
+	defer running.Done()
+	log.Print("Philosopher #", p.Id, " ######################### Philosopher.ExecuteCSCode")
+	time.Sleep(CS_DURATION)
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	if p.Crashed || p.stopped {
+		return
+	}
+	p.ReleaseCS()
+	p.RequestCS()
+}
+
+// ReleaseCS is called with globalMutex held, the subroutine sends the forks
+// requested while eating
+func (p *Philosopher) ReleaseCS() {
+	log.Print("Philosopher #", p.Id," Philosopher.ReleaseCS #########################")	
+	p.State = STATE_THINKING
+	Tracer.ReleaseCS(p.Id)
+	p.traceState()
+	p.dining.Release()
+}
+
+// send counts the message of the subroutine, which is sent in a different
+// routine. Called with globalMutex held
+func (p *Philosopher) send(dst int, m Dining.Message) {
+	var message = Message{Type: m.Type, From: p.Id, ForkId: ForkId(p.Id, dst, p.NbNodes)}
+	NB_MSG ++
+	Tracer.Send(p.Id, dst, Dining.MessageName(m.Type), 0)
+	go func() {
+		p.Messages[dst] <- message
+	}()
+}
+
+func (p *Philosopher) WaitForReplies() {	
+	defer running.Done()
+	log.Print("Philosopher #", p.Id," WaitForReplies")	
+	// a nil channel is never ready, so events are only received when a failure detector is enabled
+	var events chan FailureDetector.Event
+	if p.Detector != nil {
+		events = p.Detector.Events
+	}
+	for {
+		select {
+		case <-p.stop:
+			log.Print("Philosopher #", p.Id, " stops receiving")
+			return
+		case e := <-events:
+			globalMutex.Lock()
+			var suspecter Dining.Suspecter = p.dining.(Dining.Suspecter)
+			if e.Type == FailureDetector.SUSPECT {
+				log.Print("Philosopher #", p.Id, ", neighbor #", e.NodeId, " is suspected, its fork is not needed anymore")
+				suspecter.Suspect(e.NodeId)
+			} else {
+				log.Print("Philosopher #", p.Id, ", neighbor #", e.NodeId, " is trusted again")
+				suspecter.Trust(e.NodeId)
+			}
+			globalMutex.Unlock()
+		case msg := <-p.Messages[p.Id]:
+			globalMutex.Lock()
+			Tracer.Receive(p.Id, msg.From, Dining.MessageName(msg.Type), 0)
+			if msg.Type == Dining.SEND_FORK {
+				log.Print("Philosopher #", p.Id, ", RECEIVED fork #", msg.ForkId, " from Philosopher #", msg.From)
+			}
+			p.dining.Receive(Dining.Message{Type: msg.Type, Sender: msg.From})
+			globalMutex.Unlock()
+		}
+	}
+}
+
+// RequestCS is called with globalMutex held
+func (p *Philosopher) RequestCS() {
+	if CURRENT_ITERATION >= p.NbIterations {
+		return
+	}
+	log.Print("Philosopher #", p.Id, " wants to enter CS")
+	p.State = STATE_HUNGRY
+	Recorder.Request(p.Id)
+	p.traceState()
+	p.dining.Request()
+}
+
+// Crash stops the philosopher: it does not receive messages nor send heartbeats anymore
+func (p *Philosopher) Crash() {
+	log.Print("Philosopher #", p.Id, " !!!!!!!!!!!!!!!!!!!!!!!!! CRASH")
+	globalMutex.Lock()
+	p.Crashed = true
+	globalMutex.Unlock()
+	if p.Detector != nil {
+		p.Detector.Stop()
+	}
+	close(p.stop)
+}
+
+func (p *Philosopher) ChandyMisra(wg *sync.WaitGroup) {
+	log.Print("Philosopher #", p.Id)
+
+	if p.Detector != nil {
+		p.Detector.Start()
+	}
+	running.Add(1)
+	go p.WaitForReplies()
+	globalMutex.Lock()
+	p.RequestCS()
+	globalMutex.Unlock()
+	for {
+		time.Sleep(100 * time.Millisecond)
+		if CurrentIteration() >= p.NbIterations {
+			break
+		}
+	}
+
+	log.Print("Philosopher #", p.Id," END after ", p.NbIterations, " CS entries")	
+	wg.Done()
+}
+
+// InitPhilosopher initializes the philosopher id of graph and its dining
+// subroutine, it shares a fork with each of its neighbors
+func InitPhilosopher(p *Philosopher, id int, graph *Topology.Graph, nbIterations int) {
+	p.Id = id
+	p.NbCS = 0
+	p.NbNodes = graph.NbNodes
+	p.NbIterations = nbIterations
+	p.State = STATE_THINKING
+	p.Crashed = false
+	p.stop = make(chan bool)
+	p.Neighbors = append([]int(nil), graph.Neighbors[id]...)
+	p.dining = Dining.NewChandyMisra(id, graph, p.send, p.EnterCS)
+}
+
+// CurrentIteration returns the number of CS entries of all the philosophers
+func CurrentIteration() int {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return CURRENT_ITERATION
+}
+
+// Stop ends the routines of the philosophers that did not crash once the run
+// is over, and waits for them
+func Stop() {
+	for i := 0; i < len(Philosophers); i++ {
+		var p *Philosopher = &Philosophers[i]
+		globalMutex.Lock()
+		var crashed bool = p.Crashed
+		p.stopped = true
+		globalMutex.Unlock()
+		if crashed {
+			continue
+		}
+		if p.Detector != nil {
+			p.Detector.Stop()
+		}
+		close(p.stop)
+	}
+	running.Wait()
+}
+
+// Init creates the philosophers of the conflict graph
+func Init(graph *Topology.Graph, nbIterations int) {
+	log.Print("ChandyMisra.Init")	
+	var nbNodes int = graph.NbNodes
+	NB_MSG = 0
+	CURRENT_ITERATION = 0
+	Philosophers = make([]Philosopher, nbNodes)
+	var messages  = make([]chan Message, nbNodes)
+	
+	log.Print("nb_process #", nbNodes, ", conflict graph ", graph)
+	
+	for i := 0; i < nbNodes; i++ {
+		InitPhilosopher(&Philosophers[i], i, graph, nbIterations)
+		messages[i] = make(chan Message)
+	}
+
+	for i := 0; i < nbNodes; i++ {
+		Philosophers[i].Messages = messages
+	}
+}
+
+// EnableFailureDetector gives each philosopher a failure detector monitoring all its neighbors
+func EnableFailureDetector(strategy func() FailureDetector.Strategy) {
+	var nbNodes int = len(Philosophers)
+	var heartbeats = FailureDetector.NewHeartbeatChannels(nbNodes)
+	for i := 0; i < nbNodes; i++ {
+		Philosophers[i].Detector = FailureDetector.New(i, Philosophers[i].Neighbors, heartbeats, strategy)
+	}
+}
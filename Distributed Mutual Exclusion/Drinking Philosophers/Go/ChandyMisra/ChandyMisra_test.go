@@ -0,0 +1,85 @@
+package ChandyMisra
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"drinking/Dining"
+	"drinking/FailureDetector"
+	"drinking/internal/testutil"
+	"drinking/Topology"
+)
+
+// run runs the philosophers of graph until nbIterations CS entries in total,
+// philosopher crash crashes after crashAfter of them if it is not -1
+func run(t *testing.T, graph *Topology.Graph, nbIterations int, crash int, crashAfter int) {
+	var wg sync.WaitGroup
+	for i := 0; i < graph.NbNodes; i++ {
+		wg.Add(1)
+		go Philosophers[i].ChandyMisra(&wg)
+	}
+	if crash >= 0 {
+		for CurrentIteration() < crashAfter {
+			time.Sleep(10 * time.Millisecond)
+		}
+		Philosophers[crash].Crash()
+	}
+	testutil.Wait(t, &wg, time.Minute)
+	Stop()
+	var nbCS int = 0
+	for i := 0; i < graph.NbNodes; i++ {
+		nbCS += Philosophers[i].NbCS
+	}
+	if nbCS < nbIterations {
+		t.Error(graph, ": ", nbCS, " CS entries instead of ", nbIterations)
+	}
+}
+
+// With a failure detector, the neighbors of a crashed philosopher do not wait
+// for its forks
+func TestCrash(t *testing.T) {
+	graph, err := Topology.Parse(Topology.RING, 5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Init(graph, 8)
+	EnableFailureDetector(FailureDetector.NewPhiAccrual(8.0))
+	run(t, graph, 8, 2, 2)
+}
+
+// The messages carry the ids of the philosophers above 99
+func TestMessageIds(t *testing.T) {
+	Init(Topology.Ring(120), 1)
+	globalMutex.Lock()
+	Philosophers[105].send(104, Dining.Message{Type: Dining.REQUEST_FORK, Sender: 105})
+	Philosophers[110].send(111, Dining.Message{Type: Dining.SEND_FORK, Sender: 110})
+	globalMutex.Unlock()
+	if m := <-Philosophers[104].Messages[104]; m.Type != Dining.REQUEST_FORK || m.From != 105 || m.ForkId != 104 * 120 + 105 {
+		t.Error(m)
+	}
+	if m := <-Philosophers[111].Messages[111]; m.Type != Dining.SEND_FORK || m.From != 110 || m.ForkId != 110 * 120 + 111 {
+		t.Error(m)
+	}
+}
+
+// Each of the 120 philosophers of a ring eats, the forks do not bounce
+// between the neighbors without a meal: a meal costs a request and a fork
+// per fork, besides the messages in flight when the run stops
+func TestManyPhilosophers(t *testing.T) {
+	defer func() { CS_DURATION = 500 * time.Millisecond }()
+	CS_DURATION = time.Millisecond
+	var graph *Topology.Graph = Topology.Ring(120)
+	Init(graph, 1200)
+	run(t, graph, 1200, -1, 0)
+	for i := 0; i < graph.NbNodes; i++ {
+		if Philosophers[i].NbCS == 0 {
+			t.Error("philosopher #", i, " never ate")
+		}
+	}
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	if NB_MSG > 4 * CURRENT_ITERATION + 4 * graph.NbNodes {
+		t.Error(NB_MSG, " messages for ", CURRENT_ITERATION, " CS entries")
+	}
+}
@@ -0,0 +1,534 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+How-to run:
+  go run ./cmd/drinking --algo=ChandyMisraDrinking 2>&1 |tee /tmp/tmp.log
+
+Parameters:
+- Number of nodes
+- Number of iterations
+- Workload: the resources of each session, the think and CS durations, see
+  the Workload package
+- Seed of the random choices of the nodes (waiting times and requested resources)
+
+Protocol
+Each resource is a bottle on every edge of the conflict graph, so two
+neighbors needing the same resource compete for the bottle of their edge. A
+philosopher drinks, i.e. enters its CS, when it holds the bottles of all its
+resources on all its edges. The conflict graph is complete: any two
+philosophers may need the same resource.
+
+The forks are handled by the chandymisra subroutine of the Dining package:
+its messages are carried by the messages of the node, and it grants its CS,
+i.e. the philosopher eats, when no neighbor eats.
+
+Each bottle has a request token, held by one of the two neighbors of its
+edge. A philosopher sends the token to ask for the bottle, and keeps the
+token when it sends the bottle back, so that it can ask for it again.
+Initially the bottles are in the hand of the neighbor with the lowest id, the
+tokens in the other one's.
+
+States: a philosopher is tranquil, thirsty or drinking, its dining layer is
+thinking, hungry or eating.
+1. a tranquil philosopher becomes thirsty with a session, a set of resources,
+   and hungry. It asks for the missing bottles
+2. a philosopher holding a bottle and its token sends it, unless it needs it
+   and it is drinking or eating. An eating philosopher has no eating
+   neighbor, so its neighbors send it the bottles it needs unless they drink
+   with them. It asks again for its missing bottles when it starts eating: a
+   thirsty philosopher giving a bottle away does not ask for it back at once,
+   so that two thirsty philosophers do not send a bottle back and forth
+3. a thirsty philosopher holding all its bottles drinks. An eating
+   philosopher which is not thirsty stops eating, i.e. releases the dining
+   layer. A hungry philosopher drinking without eating stays hungry, it stops
+   eating as soon as it eats
+4. a philosopher leaving its CS becomes tranquil and sends the bottles that
+   were requested while it was drinking
+The dining layer only solves the conflicts between thirsty philosophers, a
+thirsty philosopher whose neighbors do not need its resources drinks without
+eating.
+A node checks when it enters its CS that no other node uses its resources,
+the run ends on a Fatal otherwise.
+*/
+
+/*
+    Go implementation of Chandy-Misra drinking philosophers algorithm, uses Chandy-Misra Dining Philosophers algorithm as a subroutine
+
+References :
+* https://www.cs.utexas.edu/users/misra/scannedPdf.dir/DrinkingPhil.pdf: Chandy, K.M.; Misra, J. (1984). The Drinking Philosophers Problem. ACM Transactions on Programming Languages and Systems.
+*/
+
+package ChandyMisraDrinking
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"drinking/Dining"
+	"drinking/Stats"
+	"drinking/Topology"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+/* global variable declaration */
+var NB_MSG            int = 0
+var CURRENT_ITERATION int = 0
+
+// Drinking states
+var STATE_TRANQUIL    int = 0
+var STATE_THIRSTY     int = 1
+var STATE_DRINKING    int = 2
+
+// Message types
+var REQUEST_BOTTLE int = 0
+var SEND_BOTTLE    int = 1
+var DINING_TYPE    int = 2 // message of the dining philosophers subroutine
+
+var MESSAGE_NAMES = []string{"REQUEST_BOTTLE", "SEND_BOTTLE", "DINING"}
+
+// OUTBOX_SIZE is the capacity of the queue of each link. A token is needed to
+// send a bottle or a request, and the subroutine sends at most a fork and a
+// request on a link, so at most 2 + 2 * nbResources messages are queued on it
+var OUTBOX_SIZE = 1024
+
+var Nodes []Node
+
+// Tracer records the execution when set, see the Trace package
+var Tracer *Trace.Tracer
+
+// Recorder records the response times when set, see the Stats package
+var Recorder *Stats.Recorder
+
+// globalMutex protects the variables shared by all the nodes: NB_MSG,
+// CURRENT_ITERATION and resourceUser
+var globalMutex sync.Mutex
+
+// resourceUser is the node in CS using each resource, to check mutual exclusion
+var resourceUser map[int]int
+
+// running are the routines of the nodes, Stop waits for them
+var running sync.WaitGroup
+
+type Message struct {
+	SenderId    int
+	MessageType int
+	ResourceId  int // the bottle
+	Dining      Dining.Message // of DINING_TYPE
+	TraceId     int
+}
+
+type Node struct {
+	Id            int
+	Neighbors     []int
+	Drinking      int
+	eating        bool // in the CS of the dining subroutine
+	NbCS          int
+	NbIterations  int
+	Messages      []chan bytes.Buffer
+	outbox        []chan bytes.Buffer // messages to each neighbor, forwarded in FIFO order
+	dining        Dining.Subroutine
+	// from the paper, per neighbor
+	bottle        [][]bool // neighbor => resource => the bottle is held
+	bottleToken   [][]bool
+	// implementation
+	session       []int // resources of the current session
+	needs         []bool
+	nbResources   int
+	workload      *Workload.Generator
+	csDuration    time.Duration // of the current session
+	mutex         sync.Mutex // held while a message is handled
+	stop          chan bool
+	stopped       bool
+}
+
+////////////////////////////////////////////////////////////
+// Utility functions
+////////////////////////////////////////////////////////////
+func UnmarshalMessage(b bytes.Buffer, message *Message) error {
+	dec := gob.NewDecoder(&b)
+	return dec.Decode(message)
+}
+
+func MarshalMessage(message Message) (bytes.Buffer, error) {
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	err := enc.Encode(message)
+	return buffer, err
+}
+
+func messageName(message Message) string {
+	if message.MessageType == DINING_TYPE {
+		return Dining.MessageName(message.Dining.Type)
+	}
+	if message.MessageType >= 0 && message.MessageType < len(MESSAGE_NAMES) {
+		return MESSAGE_NAMES[message.MessageType]
+	}
+	return strconv.Itoa(message.MessageType)
+}
+
+// iterationsDone returns true when the nodes entered their CS nbIterations times in total
+func iterationsDone(nbIterations int) bool {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return CURRENT_ITERATION >= nbIterations
+}
+
+func (n *Node) String() string {
+	return fmt.Sprintf("Node #%d, drinking state=%d, eating=%t, session=%v",
+		n.Id,
+		n.Drinking,
+		n.eating,
+		n.session)
+}
+
+// traceState records the states of the node
+func (n *Node) traceState() {
+	if Tracer == nil {
+		return
+	}
+	var state = map[string]interface{}{
+		"Drinking": n.Drinking,
+		"Eating": n.eating,
+		"Session": n.session,
+	}
+	Tracer.State(n.Id, state)
+}
+
+// neighborIndex returns the index of the neighbor id in the bottles
+func (n *Node) neighborIndex(id int) int {
+	for j := 0; j < len(n.Neighbors); j++ {
+		if n.Neighbors[j] == id {
+			return j
+		}
+	}
+	log.Fatal("Node #", n.Id, " is not a neighbor of Node #", id)
+	return -1
+}
+
+////////////////////////////////////////////////////////////
+// Messages
+////////////////////////////////////////////////////////////
+
+// send sends a message to dst, called with the mutex held
+func (n *Node) send(dst int, message Message) {
+	message.SenderId = n.Id
+	message.TraceId = Tracer.NextId()
+	Tracer.Send(n.Id, dst, messageName(message), message.TraceId)
+	content, err := MarshalMessage(message)
+	if err != nil {
+		log.Fatal("send ", err)
+	}
+	select {
+	case n.outbox[dst] <- content:
+	case <-n.stop:
+	}
+}
+
+// sendBottle sends a message about the bottle r to the neighbor at index j
+func (n *Node) sendBottle(j int, messageType int, r int) {
+	n.send(n.Neighbors[j], Message{MessageType: messageType, ResourceId: r})
+}
+
+// sendDining sends a message of the dining subroutine to dst
+func (n *Node) sendDining(dst int, message Dining.Message) {
+	n.send(dst, Message{MessageType: DINING_TYPE, Dining: message})
+}
+
+// forward delivers the messages to dst in the order they were sent: a request
+// sent after a bottle must not arrive before it
+func (n *Node) forward(dst int) {
+	defer running.Done()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case content := <-n.outbox[dst]:
+			select {
+			case n.Messages[dst] <- content:
+				globalMutex.Lock()
+				NB_MSG ++
+				globalMutex.Unlock()
+			case <-n.stop:
+				return
+			}
+		}
+	}
+}
+
+func (n *Node) rcv() {
+	defer running.Done()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case content := <-n.Messages[n.Id]:
+			var message Message
+			if err := UnmarshalMessage(content, &message); err != nil {
+				log.Fatal("rcv ", err)
+			}
+			Tracer.Receive(n.Id, message.SenderId, messageName(message), message.TraceId)
+			n.mutex.Lock()
+			var j int = n.neighborIndex(message.SenderId)
+			if message.MessageType == DINING_TYPE {
+				n.dining.Receive(message.Dining)
+			} else if message.MessageType == REQUEST_BOTTLE {
+				n.bottleToken[j][message.ResourceId] = true
+				n.sendBottles()
+			} else if message.MessageType == SEND_BOTTLE {
+				n.bottle[j][message.ResourceId] = true
+			} else {
+				log.Fatal("Unknown message type=", message.MessageType)
+			}
+			n.progress()
+			n.mutex.Unlock()
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Rules, called with the mutex held
+////////////////////////////////////////////////////////////
+
+// requestBottles asks for the missing bottles of a thirsty philosopher
+func (n *Node) requestBottles() {
+	if n.Drinking != STATE_THIRSTY {
+		return
+	}
+	for _, r := range n.session {
+		for j := 0; j < len(n.Neighbors); j++ {
+			if n.bottle[j][r] == false && n.bottleToken[j][r] {
+				n.bottleToken[j][r] = false
+				n.sendBottle(j, REQUEST_BOTTLE, r)
+			}
+		}
+	}
+}
+
+// sendBottles sends the requested bottles, except the ones needed by the
+// philosopher while it drinks or eats
+func (n *Node) sendBottles() {
+	for j := 0; j < len(n.Neighbors); j++ {
+		for r := 0; r < n.nbResources; r++ {
+			if n.bottle[j][r] && n.bottleToken[j][r] {
+				if n.needs[r] && (n.Drinking == STATE_DRINKING || n.eating) {
+					continue
+				}
+				n.bottle[j][r] = false
+				n.sendBottle(j, SEND_BOTTLE, r)
+			}
+		}
+	}
+}
+
+func (n *Node) hasAllBottles() bool {
+	for _, r := range n.session {
+		for j := 0; j < len(n.Neighbors); j++ {
+			if n.bottle[j][r] == false {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// startEating is called by the dining subroutine when the philosopher eats
+func (n *Node) startEating() {
+	log.Print("Node #", n.Id, " eats")
+	n.eating = true
+	n.traceState()
+	n.requestBottles()
+}
+
+// progress lets a thirsty philosopher drink when it can, and an eating
+// philosopher that is not thirsty stop eating
+func (n *Node) progress() {
+	if n.Drinking == STATE_THIRSTY && n.hasAllBottles() {
+		n.Drinking = STATE_DRINKING
+		n.EnterCS()
+		running.Add(1)
+		go n.executeCS()
+	}
+	if n.eating && n.Drinking != STATE_THIRSTY {
+		n.stopEating()
+	}
+}
+
+// stopEating releases the dining layer, the bottles kept while eating are
+// sent on request
+func (n *Node) stopEating() {
+	n.eating = false
+	n.traceState()
+	n.dining.Release()
+	n.sendBottles()
+}
+
+////////////////////////////////////////////////////////////
+// Critical section
+////////////////////////////////////////////////////////////
+
+// EnterCS is called with the mutex held
+func (n *Node) EnterCS() {
+	log.Print("Node #", n.Id, " ######################### EnterCS, session ", n.session)
+	globalMutex.Lock()
+	for _, r := range n.session {
+		if user, ok := resourceUser[r]; ok {
+			log.Fatal("Node #", n.Id, " enters CS with resource #", r, " used by Node #", user)
+		}
+		resourceUser[r] = n.Id
+	}
+	CURRENT_ITERATION ++
+	globalMutex.Unlock()
+	n.NbCS ++
+	Recorder.EnterCS(n.Id)
+	Tracer.EnterCS(n.Id)
+	n.traceState()
+}
+
+func (n *Node) ExecuteCSCode() {
+	time.Sleep(n.csDuration)
+}
+
+// ReleaseCS is called with the mutex held
+func (n *Node) ReleaseCS() {
+	log.Print("Node #", n.Id, " ReleaseCS #########################")
+	globalMutex.Lock()
+	for _, r := range n.session {
+		delete(resourceUser, r)
+	}
+	globalMutex.Unlock()
+	Tracer.ReleaseCS(n.Id)
+	n.Drinking = STATE_TRANQUIL
+	for _, r := range n.session {
+		n.needs[r] = false
+	}
+	n.session = nil
+	n.traceState()
+	n.sendBottles()
+	n.progress()
+}
+
+// executeCS runs the CS outside of the routine receiving the messages, then
+// starts the next session
+func (n *Node) executeCS() {
+	defer running.Done()
+	n.ExecuteCSCode()
+
+	n.mutex.Lock()
+	if n.stopped {
+		n.mutex.Unlock()
+		return
+	}
+	n.ReleaseCS()
+	n.mutex.Unlock()
+	n.requestCS()
+}
+
+// requestCS waits for the think time then makes the philosopher thirsty
+func (n *Node) requestCS() {
+	if iterationsDone(n.NbIterations) {
+		return
+	}
+	var next Workload.Request = n.workload.Next()
+	time.Sleep(next.Think)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.stopped || iterationsDone(n.NbIterations) {
+		return
+	}
+	n.session = next.Resources
+	n.csDuration = next.CS
+	for _, r := range n.session {
+		n.needs[r] = true
+	}
+	log.Print("Node #", n.Id, " is thirsty, session ", n.session)
+	n.Drinking = STATE_THIRSTY
+	Recorder.Request(n.Id)
+	n.traceState()
+	n.requestBottles()
+	// granted at once if the philosopher holds its forks
+	n.dining.Request()
+	n.progress()
+}
+
+func (n *Node) ChandyMisraDrinking(wg *sync.WaitGroup) {
+	running.Add(len(n.Neighbors) + 2)
+	for _, j := range n.Neighbors {
+		go n.forward(j)
+	}
+	go func() {
+		defer running.Done()
+		n.requestCS()
+	}()
+	go n.rcv()
+	for {
+		time.Sleep(100 * time.Millisecond)
+		if iterationsDone(n.NbIterations) {
+			break
+		}
+	}
+
+	log.Print("Node #", n.Id, " END after ", n.NbIterations, " CS entries")
+	wg.Done()
+}
+
+// Stop ends the routines of all the nodes once the run is over
+func Stop() {
+	for i := 0; i < len(Nodes); i++ {
+		Nodes[i].mutex.Lock()
+		Nodes[i].stopped = true
+		close(Nodes[i].stop)
+		Nodes[i].mutex.Unlock()
+	}
+	running.Wait()
+}
+
+func Init(nbNodes int, nbIterations int, workload *Workload.Config, seed int64) {
+	log.Print("ChandyMisraDrinking.Init, seed ", seed, ", workload ", workload)
+	var graph *Topology.Graph = Topology.Complete(nbNodes)
+
+	NB_MSG = 0
+	CURRENT_ITERATION = 0
+	resourceUser = make(map[int]int)
+	Nodes = make([]Node, nbNodes)
+	var messages = make([]chan bytes.Buffer, nbNodes)
+
+	for i := 0; i < nbNodes; i++ {
+		var n *Node = &Nodes[i]
+		n.Id = i
+		n.Neighbors = append([]int(nil), graph.Neighbors[i]...)
+		n.NbIterations = nbIterations
+		messages[i] = make(chan bytes.Buffer)
+		n.Drinking = STATE_TRANQUIL
+		n.nbResources = workload.NbResources
+		n.needs = make([]bool, workload.NbResources)
+		n.bottle = make([][]bool, len(n.Neighbors))
+		n.bottleToken = make([][]bool, len(n.Neighbors))
+		for j := 0; j < len(n.Neighbors); j++ {
+			// the bottles are with the lowest id, the tokens with the other neighbor
+			var holder bool = Topology.InitialHolder(i, n.Neighbors[j]) == i
+			n.bottle[j] = make([]bool, workload.NbResources)
+			n.bottleToken[j] = make([]bool, workload.NbResources)
+			for r := 0; r < workload.NbResources; r++ {
+				n.bottle[j][r] = holder
+				n.bottleToken[j][r] = !holder
+			}
+		}
+		n.dining = Dining.NewChandyMisra(i, graph, n.sendDining, n.startEating)
+		n.workload = workload.Generator(i, seed + int64(i))
+		n.outbox = make([]chan bytes.Buffer, nbNodes)
+		for _, j := range n.Neighbors {
+			n.outbox[j] = make(chan bytes.Buffer, OUTBOX_SIZE)
+		}
+		n.stop = make(chan bool)
+	}
+	for i := 0; i < nbNodes; i++ {
+		Nodes[i].Messages = messages
+	}
+}
@@ -0,0 +1,46 @@
+package ChandyMisraDrinking
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"drinking/internal/testutil"
+	"drinking/Stats"
+	"drinking/Workload"
+)
+
+// The messages of a CS are bounded, a fork or a bottle bouncing between two
+// nodes would not end or would send many more
+func TestMessages(t *testing.T) {
+	var nbIterations int = 40
+	for _, nbNodes := range []int{2, 4, 5, 7, 9} {
+		for seed := int64(1); seed <= 10; seed++ {
+			var workload *Workload.Config = testutil.NewWorkload(t, 2 * nbNodes, 3)
+			workload.Think, _ = Workload.ParseDuration("0-2ms")
+			workload.CS, _ = Workload.ParseDuration("0-2ms")
+			Init(nbNodes, nbIterations, workload, seed)
+			Recorder = Stats.New()
+			var wg sync.WaitGroup
+			for i := 0; i < nbNodes; i++ {
+				wg.Add(1)
+				go Nodes[i].ChandyMisraDrinking(&wg)
+			}
+			testutil.Wait(t, &wg, time.Minute)
+			Stop()
+			var nbCS int = 0
+			for i := 0; i < nbNodes; i++ {
+				nbCS += Nodes[i].NbCS
+			}
+			if nbCS < nbIterations {
+				t.Error(nbNodes, " nodes, seed ", seed, ": ", nbCS, " CS entries instead of ", nbIterations)
+			}
+			globalMutex.Lock()
+			var nbMsg int = NB_MSG
+			globalMutex.Unlock()
+			if nbMsg == 0 || nbMsg > 10 * nbNodes * nbCS {
+				t.Error(nbNodes, " nodes, seed ", seed, ": ", nbMsg, " messages for ", nbCS, " CS entries")
+			}
+		}
+	}
+}
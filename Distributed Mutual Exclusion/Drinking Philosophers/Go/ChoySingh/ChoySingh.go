@@ -0,0 +1,612 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+How-to run:
+  go run ./cmd/drinking --algo=ChoySingh --topology=ring --nodes=8 2>&1 |tee /tmp/tmp.log
+or, to measure the failure locality, with philosopher #0 crashing in its CS
+after 10 CS entries in total, the run is observed for 5s after the crash:
+  go run ./cmd/drinking --algo=ChoySingh --topology=grid --nodes=36 --crash=0 --crashAfter=10 --crashObserve=5s
+
+Parameters:
+- Conflict graph of the philosophers, see the Topology package
+- Number of iterations, ignored when a philosopher crashes: the run then
+  lasts until the end of the observation
+- Workload: the think and CS durations, see the Workload package. The
+  resources of a philosopher are the forks of its edges
+- Seed of the random choices of the nodes (waiting times)
+
+Failure locality
+The failure locality of an algorithm is the largest distance, in the
+conflict graph, between a crashed philosopher and a philosopher that
+starves because of it. It is not bounded with Chandy-Misra, Rhee or Lynch's
+coloring: a chain of waiting philosophers may go through the whole graph. It
+is 4 with the double doorway of Choy and Singh. When a philosopher crashes in
+its CS, the philosophers hungry for longer than half the observation at its
+end are blocked, see Blocked, the blocked radius is their largest distance
+to the crashed philosopher.
+
+Protocol
+The philosophers are colored so that neighbors have distinct colors
+(Topology.Color), the lowest color has the highest priority. Each fork has a
+request token, initially the fork is with the neighbor with the lowest id
+and the token with the other one. A hungry philosopher goes through:
+1. the asynchronous doorway: it sends AD_REQUEST to its neighbors and crosses
+   the doorway with all the AD_ACK. A philosopher behind the asynchronous
+   doorway, until it leaves its CS, defers its AD_ACK, so that a neighbor
+   cannot cross the doorway again while it waits
+2. the synchronous doorway: it sends SD_QUERY to its neighbors, that answer
+   SD_STATUS, whether they are behind the synchronous doorway. A neighbor
+   behind it sends SD_STATUS again when it leaves its CS. The philosopher
+   crosses the doorway when it sees all its neighbors outside of it
+3. behind both doorways, it collects its forks: a philosopher holding a fork
+   and its token sends it, unless it is eating, or it is behind the
+   synchronous doorway and has a lower color than the requester. It asks for
+   the fork again at once if it needs it
+4. with all its forks it eats, i.e. enters its CS. On exit it sends the
+   deferred AD_ACK, the SD_STATUS and the requested forks
+A crashed philosopher stays in its CS and ignores its messages. Its
+neighbors wait for its forks, or at the synchronous doorway where they see
+it inside. The philosophers behind a doorway only stop their neighbors at
+the next doorway, which bounds the blocking to distance 4.
+A node checks when it enters its CS that no neighbor is in CS, the run ends
+on a Fatal otherwise.
+*/
+
+/*
+    Go implementation of Choy-Singh double doorway resource allocation algorithm
+
+References :
+* https://doi.org/10.1145/203095.203101: Choy, M.; Singh, A. K. (1995). Efficient fault-tolerant algorithms for distributed resource allocation. ACM Transactions on Programming Languages and Systems.
+* https://doi.org/10.1145/62546.62572: Styer, E.; Peterson, G. L. (1988). Improved algorithms for distributed resource allocation. Proceedings of the seventh annual ACM Symposium on Principles of Distributed Computing.
+*/
+
+package ChoySingh
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"drinking/Stats"
+	"drinking/Topology"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+/* global variable declaration */
+var NB_MSG            int = 0
+var CURRENT_ITERATION int = 0
+
+// States, a philosopher is behind the asynchronous doorway from
+// STATE_WAITING_SD and behind the synchronous one from STATE_COLLECTING
+var STATE_THINKING   int = 0
+var STATE_WAITING_AD int = 1
+var STATE_WAITING_SD int = 2
+var STATE_COLLECTING int = 3
+var STATE_EATING     int = 4
+
+var STATE_NAMES = []string{"thinking", "waiting AD", "waiting SD", "collecting", "eating"}
+
+// Message types
+var AD_REQUEST   int = 0
+var AD_ACK       int = 1
+var SD_QUERY     int = 2
+var SD_STATUS    int = 3
+var REQUEST_FORK int = 4
+var SEND_FORK    int = 5
+
+var MESSAGE_NAMES = []string{"AD_REQUEST", "AD_ACK", "SD_QUERY", "SD_STATUS", "REQUEST_FORK", "SEND_FORK"}
+
+var Nodes []Node
+
+// Graph is the conflict graph, Colors the color of each philosopher
+var Graph    *Topology.Graph
+var Colors   []int
+var NbColors int
+
+// Tracer records the execution when set, see the Trace package
+var Tracer *Trace.Tracer
+
+// Recorder records the response times when set, see the Stats package
+var Recorder *Stats.Recorder
+
+// globalMutex protects the variables shared by all the nodes: NB_MSG,
+// CURRENT_ITERATION, eating and the crash
+var globalMutex sync.Mutex
+
+// eating is the philosophers in CS, to check mutual exclusion
+var eating []bool
+
+// crashNode crashes in its CS once there were crashAfter CS entries, -1 for
+// none, crashed is closed then
+var crashNode  int = -1
+var crashAfter int = 0
+var crashed    chan bool
+var hasCrashed bool = false
+
+type Message struct {
+	SenderId    int
+	MessageType int
+	Inside      bool // of SD_STATUS, the sender is behind the synchronous doorway
+	Epoch       int  // of SD_STATUS, to keep the last status of the sender
+	TraceId     int
+}
+
+type Node struct {
+	Id            int
+	State         int
+	NbCS          int
+	NbIterations  int
+	Neighbors     []int
+	Messages      []chan bytes.Buffer
+	// per neighbor
+	fork          []bool
+	forkToken     []bool
+	adAck         []bool
+	adDeferred    []bool // the AD_ACK sent on exit
+	sdEpoch       []int  // epoch of the last SD_STATUS received, -1 if none
+	sdInside      []bool
+	sdWatcher     []bool // the neighbors waiting for the SD_STATUS sent on exit
+	// implementation
+	epoch         int // incremented when the node crosses the synchronous doorway or leaves its CS
+	hungrySince   time.Time
+	workload      *Workload.Generator
+	csDuration    time.Duration // of the current request
+	crashed       bool
+	mutex         sync.Mutex // held while a message is handled
+	stop          chan bool
+	stopped       bool
+}
+
+////////////////////////////////////////////////////////////
+// Utility functions
+////////////////////////////////////////////////////////////
+func UnmarshalMessage(b bytes.Buffer, message *Message) error {
+	dec := gob.NewDecoder(&b)
+	return dec.Decode(message)
+}
+
+func MarshalMessage(message Message) (bytes.Buffer, error) {
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	err := enc.Encode(message)
+	return buffer, err
+}
+
+func messageName(messageType int) string {
+	if messageType >= 0 && messageType < len(MESSAGE_NAMES) {
+		return MESSAGE_NAMES[messageType]
+	}
+	return strconv.Itoa(messageType)
+}
+
+// iterationsDone returns true when the nodes entered their CS nbIterations
+// times in total, never when a node is to crash
+func iterationsDone(nbIterations int) bool {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return crashNode < 0 && CURRENT_ITERATION >= nbIterations
+}
+
+func (n *Node) String() string {
+	return fmt.Sprintf("Node #%d, color %d, state=%s, forks=%v",
+		n.Id,
+		Colors[n.Id],
+		STATE_NAMES[n.State],
+		n.fork)
+}
+
+// traceState records the state of the node and the forks it holds
+func (n *Node) traceState() {
+	if Tracer == nil {
+		return
+	}
+	var state = map[string]interface{}{
+		"State": STATE_NAMES[n.State],
+		"Neighbors": n.Neighbors,
+		"Forks": n.fork,
+	}
+	Tracer.State(n.Id, state)
+}
+
+// neighborIndex returns the index of the neighbor id in the forks
+func (n *Node) neighborIndex(id int) int {
+	for j := 0; j < len(n.Neighbors); j++ {
+		if n.Neighbors[j] == id {
+			return j
+		}
+	}
+	log.Fatal("Node #", n.Id, " is not a neighbor of Node #", id)
+	return -1
+}
+
+func (n *Node) behindAD() bool {
+	return n.State >= STATE_WAITING_SD
+}
+
+func (n *Node) behindSD() bool {
+	return n.State >= STATE_COLLECTING
+}
+
+////////////////////////////////////////////////////////////
+// Messages
+////////////////////////////////////////////////////////////
+
+// send sends a message to the neighbor at index j, called with the mutex held
+func (n *Node) send(j int, message Message) {
+	message.SenderId = n.Id
+	message.TraceId = Tracer.NextId()
+	var dst int = n.Neighbors[j]
+	Tracer.Send(n.Id, dst, messageName(message.MessageType), message.TraceId)
+	content, err := MarshalMessage(message)
+	if err != nil {
+		log.Fatal("send ", err)
+	}
+	// counted with the mutex held, so that NB_MSG is final once the nodes are stopped
+	globalMutex.Lock()
+	NB_MSG ++
+	globalMutex.Unlock()
+	go n.transmit(dst, content)
+}
+
+// transmit sends content to dst, unless the node is stopped before dst receives it
+func (n *Node) transmit(dst int, content bytes.Buffer) {
+	select {
+	case n.Messages[dst] <- content:
+	case <-n.stop:
+	}
+}
+
+func (n *Node) rcv() {
+	for {
+		select {
+		case <-n.stop:
+			return
+		case content := <-n.Messages[n.Id]:
+			var message Message
+			if err := UnmarshalMessage(content, &message); err != nil {
+				log.Fatal("rcv ", err)
+			}
+			n.mutex.Lock()
+			if n.crashed || n.stopped {
+				n.mutex.Unlock()
+				continue
+			}
+			Tracer.Receive(n.Id, message.SenderId, messageName(message.MessageType), message.TraceId)
+			var j int = n.neighborIndex(message.SenderId)
+			if message.MessageType == AD_REQUEST {
+				if n.behindAD() {
+					n.adDeferred[j] = true
+				} else {
+					n.send(j, Message{MessageType: AD_ACK})
+				}
+			} else if message.MessageType == AD_ACK {
+				n.adAck[j] = true
+				n.crossAD()
+			} else if message.MessageType == SD_QUERY {
+				n.send(j, Message{MessageType: SD_STATUS, Inside: n.behindSD(), Epoch: n.epoch})
+				n.sdWatcher[j] = n.behindSD()
+			} else if message.MessageType == SD_STATUS {
+				// the status may be older than the last one, messages are not FIFO
+				if n.State == STATE_WAITING_SD && message.Epoch > n.sdEpoch[j] {
+					n.sdEpoch[j] = message.Epoch
+					n.sdInside[j] = message.Inside
+					n.crossSD()
+				}
+			} else if message.MessageType == REQUEST_FORK {
+				n.forkToken[j] = true
+				n.sendForks()
+			} else if message.MessageType == SEND_FORK {
+				n.fork[j] = true
+				n.sendForks()
+				n.progress()
+			} else {
+				log.Fatal("Unknown message type=", message.MessageType)
+			}
+			n.mutex.Unlock()
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Rules, called with the mutex held
+////////////////////////////////////////////////////////////
+
+// crossAD crosses the asynchronous doorway with all the AD_ACK, and queries
+// the neighbors for the synchronous doorway
+func (n *Node) crossAD() {
+	if n.State != STATE_WAITING_AD {
+		return
+	}
+	for j := 0; j < len(n.Neighbors); j++ {
+		if n.adAck[j] == false {
+			return
+		}
+	}
+	n.State = STATE_WAITING_SD
+	n.traceState()
+	for j := 0; j < len(n.Neighbors); j++ {
+		n.sdEpoch[j] = -1
+		n.send(j, Message{MessageType: SD_QUERY})
+	}
+	n.crossSD()
+}
+
+// crossSD crosses the synchronous doorway when all the neighbors are seen outside of it
+func (n *Node) crossSD() {
+	if n.State != STATE_WAITING_SD {
+		return
+	}
+	for j := 0; j < len(n.Neighbors); j++ {
+		if n.sdEpoch[j] == -1 || n.sdInside[j] {
+			return
+		}
+	}
+	n.State = STATE_COLLECTING
+	n.epoch ++
+	n.traceState()
+	n.sendForks()
+	n.progress()
+}
+
+// yields returns true if the fork shared with the neighbor at index j is sent on request
+func (n *Node) yields(j int) bool {
+	if n.State == STATE_EATING {
+		return false
+	}
+	if n.State == STATE_COLLECTING {
+		return Colors[n.Neighbors[j]] < Colors[n.Id]
+	}
+	return true
+}
+
+// requestForks asks for the missing forks behind the synchronous doorway
+func (n *Node) requestForks() {
+	if n.State != STATE_COLLECTING {
+		return
+	}
+	for j := 0; j < len(n.Neighbors); j++ {
+		if n.fork[j] == false && n.forkToken[j] {
+			n.forkToken[j] = false
+			n.send(j, Message{MessageType: REQUEST_FORK})
+		}
+	}
+}
+
+// sendForks sends the requested forks that are yielded, and asks for them
+// again if they are needed
+func (n *Node) sendForks() {
+	for j := 0; j < len(n.Neighbors); j++ {
+		if n.fork[j] && n.forkToken[j] && n.yields(j) {
+			n.fork[j] = false
+			n.send(j, Message{MessageType: SEND_FORK})
+		}
+	}
+	n.requestForks()
+}
+
+// progress enters the CS with all the forks
+func (n *Node) progress() {
+	if n.State != STATE_COLLECTING {
+		return
+	}
+	for j := 0; j < len(n.Neighbors); j++ {
+		if n.fork[j] == false {
+			return
+		}
+	}
+	n.EnterCS()
+	go n.executeCS()
+}
+
+////////////////////////////////////////////////////////////
+// Critical section
+////////////////////////////////////////////////////////////
+
+// EnterCS is called with the mutex held
+func (n *Node) EnterCS() {
+	log.Print("Node #", n.Id, " ######################### EnterCS")
+	globalMutex.Lock()
+	for _, j := range n.Neighbors {
+		if eating[j] {
+			log.Fatal("Node #", n.Id, " enters CS with its neighbor Node #", j)
+		}
+	}
+	eating[n.Id] = true
+	CURRENT_ITERATION ++
+	if n.Id == crashNode && !hasCrashed && CURRENT_ITERATION > crashAfter {
+		n.crashed = true
+		hasCrashed = true
+		close(crashed)
+	}
+	globalMutex.Unlock()
+	n.State = STATE_EATING
+	n.NbCS ++
+	Recorder.EnterCS(n.Id)
+	Tracer.EnterCS(n.Id)
+	n.traceState()
+	if n.crashed {
+		log.Print("Node #", n.Id, " !!!!!!!!!!!!!!!!!!!!!!!!! crashes in CS")
+	}
+}
+
+func (n *Node) ExecuteCSCode() {
+	time.Sleep(n.csDuration)
+}
+
+// ReleaseCS is called with the mutex held
+func (n *Node) ReleaseCS() {
+	log.Print("Node #", n.Id, " ReleaseCS #########################")
+	globalMutex.Lock()
+	eating[n.Id] = false
+	globalMutex.Unlock()
+	Tracer.ReleaseCS(n.Id)
+	n.State = STATE_THINKING
+	n.epoch ++
+	n.traceState()
+	for j := 0; j < len(n.Neighbors); j++ {
+		if n.adDeferred[j] {
+			n.adDeferred[j] = false
+			n.send(j, Message{MessageType: AD_ACK})
+		}
+		if n.sdWatcher[j] {
+			n.sdWatcher[j] = false
+			n.send(j, Message{MessageType: SD_STATUS, Inside: false, Epoch: n.epoch})
+		}
+	}
+	n.sendForks()
+}
+
+// executeCS runs the CS outside of the routine receiving the messages, then
+// starts the next request. A crashed node never leaves its CS
+func (n *Node) executeCS() {
+	n.ExecuteCSCode()
+
+	n.mutex.Lock()
+	if n.stopped || n.crashed {
+		n.mutex.Unlock()
+		return
+	}
+	n.ReleaseCS()
+	n.mutex.Unlock()
+	n.requestCS()
+}
+
+// requestCS waits for the think time then makes the philosopher hungry
+func (n *Node) requestCS() {
+	if iterationsDone(n.NbIterations) {
+		return
+	}
+	var next Workload.Request = n.workload.Next()
+	time.Sleep(next.Think)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.stopped || iterationsDone(n.NbIterations) {
+		return
+	}
+	n.csDuration = next.CS
+	n.State = STATE_WAITING_AD
+	n.hungrySince = time.Now()
+	log.Print("Node #", n.Id, " is hungry")
+	Recorder.Request(n.Id)
+	n.traceState()
+	for j := 0; j < len(n.Neighbors); j++ {
+		n.adAck[j] = false
+		n.send(j, Message{MessageType: AD_REQUEST})
+	}
+	n.crossAD()
+}
+
+func (n *Node) ChoySingh(wg *sync.WaitGroup) {
+	go n.requestCS()
+	go n.rcv()
+	for iterationsDone(n.NbIterations) == false {
+		select {
+		case <-n.stop:
+			wg.Done()
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	log.Print("Node #", n.Id, " END after ", n.NbIterations, " CS entries")
+	wg.Done()
+}
+
+////////////////////////////////////////////////////////////
+// Crash
+////////////////////////////////////////////////////////////
+
+// CrashInCS makes node crash the next time it enters its CS after after CS
+// entries in total, Crashed is closed then
+func CrashInCS(node int, after int) {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	crashNode = node
+	crashAfter = after
+}
+
+func Crashed() <-chan bool {
+	return crashed
+}
+
+// Blocked returns the philosophers, except the crashed ones, hungry for more
+// than wait, and their largest distance to the crashed philosopher, the
+// blocked radius
+func Blocked(crashedNode int, wait time.Duration) ([]int, int) {
+	var distances []int = Graph.Distances(crashedNode)
+	var blocked []int
+	var radius int = 0
+	for i := 0; i < len(Nodes); i++ {
+		var n *Node = &Nodes[i]
+		n.mutex.Lock()
+		if !n.crashed && n.State != STATE_THINKING && time.Since(n.hungrySince) > wait {
+			log.Print("Node #", i, " at distance ", distances[i], " is blocked, ", n)
+			blocked = append(blocked, i)
+			if distances[i] > radius {
+				radius = distances[i]
+			}
+		}
+		n.mutex.Unlock()
+	}
+	return blocked, radius
+}
+
+// Stop ends the routines of all the nodes once the run is over
+func Stop() {
+	for i := 0; i < len(Nodes); i++ {
+		Nodes[i].mutex.Lock()
+		Nodes[i].stopped = true
+		close(Nodes[i].stop)
+		Nodes[i].mutex.Unlock()
+	}
+}
+
+func Init(graph *Topology.Graph, nbIterations int, workload *Workload.Config, seed int64) {
+	log.Print("ChoySingh.Init, seed ", seed, ", conflict graph ", graph, ", workload ", workload)
+	var nbNodes int = graph.NbNodes
+	Graph = graph
+	Colors, NbColors = graph.Color()
+	log.Print("ChoySingh.Init, ", NbColors, " colors")
+
+	NB_MSG = 0
+	CURRENT_ITERATION = 0
+	crashNode = -1
+	crashed = make(chan bool)
+	hasCrashed = false
+	eating = make([]bool, nbNodes)
+	Nodes = make([]Node, nbNodes)
+	var messages = make([]chan bytes.Buffer, nbNodes)
+
+	for i := 0; i < nbNodes; i++ {
+		var n *Node = &Nodes[i]
+		n.Id = i
+		n.State = STATE_THINKING
+		n.NbIterations = nbIterations
+		n.Neighbors = graph.Neighbors[i]
+		messages[i] = make(chan bytes.Buffer)
+		var nbNeighbors int = len(n.Neighbors)
+		n.fork = make([]bool, nbNeighbors)
+		n.forkToken = make([]bool, nbNeighbors)
+		n.adAck = make([]bool, nbNeighbors)
+		n.adDeferred = make([]bool, nbNeighbors)
+		n.sdEpoch = make([]int, nbNeighbors)
+		n.sdInside = make([]bool, nbNeighbors)
+		n.sdWatcher = make([]bool, nbNeighbors)
+		for j := 0; j < nbNeighbors; j++ {
+			// the forks are with the lowest id, the tokens with the other neighbor
+			n.fork[j] = Topology.InitialHolder(i, n.Neighbors[j]) == i
+			n.forkToken[j] = !n.fork[j]
+		}
+		n.workload = workload.Generator(i, seed + int64(i))
+		n.stop = make(chan bool)
+	}
+	for i := 0; i < nbNodes; i++ {
+		Nodes[i].Messages = messages
+	}
+}
@@ -0,0 +1,72 @@
+package ChoySingh
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"drinking/internal/testutil"
+	"drinking/Topology"
+)
+
+// A philosopher crashing in its CS at the center of a 9x9 grid blocks its
+// neighbors, and only the philosophers up to distance 4: the ones further away
+// keep eating
+func TestFailureLocality(t *testing.T) {
+	var crash int = 40
+	graph, err := Topology.Parse("grid:9x9", 81, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Init(graph, 1000000, testutil.NewWorkload(t, graph.NbNodes, 1), 1)
+	CrashInCS(crash, 50)
+	var wg sync.WaitGroup
+	for i := 0; i < graph.NbNodes; i++ {
+		wg.Add(1)
+		go Nodes[i].ChoySingh(&wg)
+	}
+	select {
+	case <-Crashed():
+	case <-time.After(time.Minute):
+		t.Fatal("philosopher #", crash, " did not crash")
+	}
+	time.Sleep(2 * time.Second)
+	blocked, radius := Blocked(crash, time.Second)
+	var distances []int = graph.Distances(crash)
+	var nbCS = make([]int, graph.NbNodes)
+	for i := 0; i < graph.NbNodes; i++ {
+		Nodes[i].mutex.Lock()
+		nbCS[i] = Nodes[i].NbCS
+		Nodes[i].mutex.Unlock()
+	}
+	time.Sleep(time.Second)
+	var nbFar int = 0
+	for i := 0; i < graph.NbNodes; i++ {
+		if distances[i] <= 4 {
+			continue
+		}
+		nbFar ++
+		Nodes[i].mutex.Lock()
+		if Nodes[i].NbCS == nbCS[i] {
+			t.Error("philosopher #", i, " at distance ", distances[i], " did not eat after the crash")
+		}
+		Nodes[i].mutex.Unlock()
+	}
+	Stop()
+	testutil.Wait(t, &wg, time.Minute)
+	if radius < 1 || radius > 4 {
+		t.Error(len(blocked), " philosophers blocked, blocked radius ", radius, ": ", blocked)
+	}
+	var isBlocked = make(map[int]bool)
+	for _, i := range blocked {
+		isBlocked[i] = true
+	}
+	for i := 0; i < graph.NbNodes; i++ {
+		if distances[i] == 1 && !isBlocked[i] {
+			t.Error("neighbor #", i, " of the crashed philosopher is not blocked")
+		}
+	}
+	if nbFar == 0 {
+		t.Error("no philosopher further than 4 from #", crash)
+	}
+}
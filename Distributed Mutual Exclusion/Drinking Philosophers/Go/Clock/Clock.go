@@ -0,0 +1,262 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Logical clocks used by the timestamp-based algorithms and by the traces:
+* Lamport: a scalar clock, if a happens before b then L(a) < L(b)
+* Vector: a vector clock, a happens before b if and only if V(a) < V(b)
+* HLC: a hybrid logical clock, a Lamport clock that stays close to physical time
+
+References :
+ * https://doi.org/10.1145/359545.359563: Leslie Lamport. 1978. Time, clocks, and the ordering of events in a distributed system. Commun. ACM 21, 7 (July 1978), 558–565.
+ * Colin J. Fidge. 1988. Timestamps in message-passing systems that preserve the partial ordering. Proceedings of the 11th Australian Computer Science Conference, 56-66.
+ * https://cse.buffalo.edu/tech-reports/2014-04.pdf: Sandeep S. Kulkarni, Murat Demirbas, Deepak Madeppa, Bharadwaj Avva and Marcelo Leone. 2014. Logical Physical Clocks and Consistent Snapshots in Globally Distributed Databases.
+*/
+
+package Clock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Results of the comparison of two clock values
+var BEFORE     int = -1
+var EQUAL      int = 0
+var AFTER      int = 1
+var CONCURRENT int = 2
+
+////////////////////////////////////////////////////////////
+// Lamport clock
+////////////////////////////////////////////////////////////
+type Lamport struct {
+	time  int
+	mutex sync.Mutex
+}
+
+func NewLamport(initial int) *Lamport {
+	var c Lamport
+	c.time = initial
+	return &c
+}
+
+func (c *Lamport) Now() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.time
+}
+
+// Tick advances the clock for a local event or a send, and returns the timestamp of the event
+func (c *Lamport) Tick() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.time ++
+	return c.time
+}
+
+// Witness takes into account a timestamp seen in a message without counting a new event
+func (c *Lamport) Witness(t int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if t > c.time {
+		c.time = t
+	}
+	return c.time
+}
+
+// Receive advances the clock for the reception of a message timestamped t, and returns the timestamp of the reception
+func (c *Lamport) Receive(t int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if t > c.time {
+		c.time = t
+	}
+	c.time ++
+	return c.time
+}
+
+// CompareLamport orders two events timestamped by Lamport clocks, ties are
+// broken with the ids of the nodes, giving the total order used by the
+// mutual exclusion algorithms
+func CompareLamport(t1 int, id1 int, t2 int, id2 int) int {
+	if t1 < t2 || (t1 == t2 && id1 < id2) {
+		return BEFORE
+	}
+	if t1 == t2 && id1 == id2 {
+		return EQUAL
+	}
+	return AFTER
+}
+
+////////////////////////////////////////////////////////////
+// Vector clock
+////////////////////////////////////////////////////////////
+
+// Vector is the value of a vector clock, a missing entry is 0 so that vectors
+// of different lengths can be compared
+type Vector []int
+
+func (v Vector) get(i int) int {
+	if i < len(v) {
+		return v[i]
+	}
+	return 0
+}
+
+func (v Vector) Copy() Vector {
+	var c = make(Vector, len(v))
+	copy(c, v)
+	return c
+}
+
+// Tick advances the entry of node id, for a local event or a send
+func (v *Vector) Tick(id int) {
+	for len(*v) <= id {
+		*v = append(*v, 0)
+	}
+	(*v)[id] ++
+}
+
+// Merge takes the maximum of each entry, for a reception
+func (v *Vector) Merge(other Vector) {
+	for len(*v) < len(other) {
+		*v = append(*v, 0)
+	}
+	for i := 0; i < len(other); i++ {
+		if other[i] > (*v)[i] {
+			(*v)[i] = other[i]
+		}
+	}
+}
+
+// Receive merges the vector of a message received by node id and ticks
+func (v *Vector) Receive(id int, other Vector) {
+	v.Merge(other)
+	v.Tick(id)
+}
+
+// Compare returns BEFORE if v1 happens before v2, AFTER if v2 happens
+// before v1, EQUAL or CONCURRENT
+func Compare(v1 Vector, v2 Vector) int {
+	var less bool = false
+	var greater bool = false
+	var size int = len(v1)
+	if len(v2) > size {
+		size = len(v2)
+	}
+	for i := 0; i < size; i++ {
+		if v1.get(i) < v2.get(i) {
+			less = true
+		} else if v1.get(i) > v2.get(i) {
+			greater = true
+		}
+	}
+	if less && greater {
+		return CONCURRENT
+	}
+	if less {
+		return BEFORE
+	}
+	if greater {
+		return AFTER
+	}
+	return EQUAL
+}
+
+func HappensBefore(v1 Vector, v2 Vector) bool {
+	return Compare(v1, v2) == BEFORE
+}
+
+func Concurrent(v1 Vector, v2 Vector) bool {
+	return Compare(v1, v2) == CONCURRENT
+}
+
+func (v Vector) String() string {
+	return fmt.Sprint([]int(v))
+}
+
+////////////////////////////////////////////////////////////
+// Hybrid logical clock
+////////////////////////////////////////////////////////////
+
+// Timestamp of a hybrid logical clock: L is the highest physical time known,
+// in nanoseconds, C counts the events that happened while L did not change
+type Timestamp struct {
+	L int64
+	C int
+}
+
+type HLC struct {
+	last     Timestamp
+	physical func() int64
+	mutex    sync.Mutex
+}
+
+func NewHLC() *HLC {
+	return NewHLCWithPhysicalClock(func() int64 { return time.Now().UnixNano() })
+}
+
+// NewHLCWithPhysicalClock uses physical as the physical clock, to simulate clocks drifting apart
+func NewHLCWithPhysicalClock(physical func() int64) *HLC {
+	var c HLC
+	c.physical = physical
+	return &c
+}
+
+// Now returns the timestamp of a local event or a send
+func (c *HLC) Now() Timestamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var pt int64 = c.physical()
+	if pt > c.last.L {
+		c.last.L = pt
+		c.last.C = 0
+	} else {
+		c.last.C ++
+	}
+	return c.last
+}
+
+// Receive returns the timestamp of the reception of a message timestamped m
+func (c *HLC) Receive(m Timestamp) Timestamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var pt int64 = c.physical()
+	var l int64 = c.last.L
+	if m.L > l {
+		l = m.L
+	}
+	if pt > l {
+		l = pt
+	}
+	if l == c.last.L && l == m.L {
+		if m.C > c.last.C {
+			c.last.C = m.C + 1
+		} else {
+			c.last.C ++
+		}
+	} else if l == c.last.L {
+		c.last.C ++
+	} else if l == m.L {
+		c.last.C = m.C + 1
+	} else {
+		c.last.C = 0
+	}
+	c.last.L = l
+	return c.last
+}
+
+// CompareTimestamps orders two HLC timestamps, if a happens before b then t(a) < t(b)
+func CompareTimestamps(t1 Timestamp, t2 Timestamp) int {
+	if t1.L < t2.L || (t1.L == t2.L && t1.C < t2.C) {
+		return BEFORE
+	}
+	if t1 == t2 {
+		return EQUAL
+	}
+	return AFTER
+}
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%d.%d", t.L, t.C)
+}
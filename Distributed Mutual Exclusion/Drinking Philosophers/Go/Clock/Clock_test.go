@@ -0,0 +1,149 @@
+package Clock
+
+import (
+	"testing"
+)
+
+func TestLamport(t *testing.T) {
+	var c *Lamport = NewLamport(0)
+	if c.Tick() != 1 || c.Tick() != 2 {
+		t.Error("tick: ", c.Now())
+	}
+	// a reception takes the maximum, then counts one event
+	if c.Receive(10) != 11 || c.Receive(3) != 12 {
+		t.Error("receive: ", c.Now())
+	}
+	// a witness takes the maximum only
+	if c.Witness(20) != 20 || c.Witness(5) != 20 || c.Now() != 20 {
+		t.Error("witness: ", c.Now())
+	}
+}
+
+func TestCompareLamport(t *testing.T) {
+	var tests = []struct {
+		t1, id1, t2, id2 int
+		expected         int
+	}{
+		{1, 5, 2, 0, BEFORE},
+		{2, 0, 1, 5, AFTER},
+		// ties are broken with the ids
+		{3, 1, 3, 2, BEFORE},
+		{3, 2, 3, 1, AFTER},
+		{3, 1, 3, 1, EQUAL},
+	}
+	for _, test := range tests {
+		if result := CompareLamport(test.t1, test.id1, test.t2, test.id2); result != test.expected {
+			t.Error(test, ": ", result)
+		}
+	}
+}
+
+func TestVector(t *testing.T) {
+	var a, b Vector
+	a.Tick(0)                   // [1]
+	var send Vector = a.Copy()
+	a.Tick(0)                   // [2]
+	b.Tick(1)                   // [0 1]
+	b.Receive(1, send)          // [1 2]
+	if a.String() != "[2]" || b.String() != "[1 2]" {
+		t.Fatal("a=", a, ", b=", b)
+	}
+	if !HappensBefore(send, b) || Compare(b, send) != AFTER {
+		t.Error("the send does not happen before the reception")
+	}
+	if !Concurrent(a, b) {
+		t.Error(a, " and ", b, " are not concurrent")
+	}
+	// missing entries are 0
+	if Compare(Vector{1}, Vector{1, 0, 0}) != EQUAL || !HappensBefore(Vector{1}, Vector{1, 0, 1}) {
+		t.Error("vectors of different lengths")
+	}
+	// Merge takes the maximum of each entry
+	var m Vector = Vector{3, 0}
+	m.Merge(Vector{1, 4, 2})
+	if m.String() != "[3 4 2]" {
+		t.Error("merge: ", m)
+	}
+	// a copy is not modified by the original
+	var c Vector = m.Copy()
+	m.Tick(0)
+	if c[0] != 3 {
+		t.Error("copy modified: ", c)
+	}
+}
+
+// physicalClock returns a physical clock reading the value of *now
+func physicalClock(now *int64) func() int64 {
+	return func() int64 { return *now }
+}
+
+func TestHLC(t *testing.T) {
+	var now1, now2 int64 = 100, 50 // node 2 is late
+	var c1 *HLC = NewHLCWithPhysicalClock(physicalClock(&now1))
+	var c2 *HLC = NewHLCWithPhysicalClock(physicalClock(&now2))
+
+	// L follows the physical clock, C counts the events while it does not change
+	if ts := c1.Now(); ts != (Timestamp{100, 0}) {
+		t.Error("now: ", ts)
+	}
+	var send Timestamp = c1.Now()
+	if send != (Timestamp{100, 1}) {
+		t.Error("now without physical progress: ", send)
+	}
+	// a late node takes the time of the message
+	var receive Timestamp = c2.Receive(send)
+	if receive != (Timestamp{100, 2}) || CompareTimestamps(send, receive) != BEFORE {
+		t.Error("receive from the future: ", receive)
+	}
+	if ts := c2.Now(); ts != (Timestamp{100, 3}) {
+		t.Error("now after receive: ", ts)
+	}
+	// once its physical clock is ahead, it is used again
+	now2 = 200
+	if ts := c2.Now(); ts != (Timestamp{200, 0}) {
+		t.Error("now after the physical clock: ", ts)
+	}
+	// a message from the past only counts one event
+	if ts := c2.Receive(Timestamp{150, 7}); ts != (Timestamp{200, 1}) {
+		t.Error("receive from the past: ", ts)
+	}
+	// same L for the clock and the message: C after both
+	if ts := c2.Receive(Timestamp{200, 5}); ts != (Timestamp{200, 6}) {
+		t.Error("receive with the same L: ", ts)
+	}
+	// the physical clock is ahead of both
+	now2 = 300
+	if ts := c2.Receive(Timestamp{250, 3}); ts != (Timestamp{300, 0}) {
+		t.Error("receive behind the physical clock: ", ts)
+	}
+}
+
+func TestCompareTimestamps(t *testing.T) {
+	var tests = []struct {
+		t1, t2   Timestamp
+		expected int
+	}{
+		{Timestamp{1, 5}, Timestamp{2, 0}, BEFORE},
+		{Timestamp{2, 0}, Timestamp{2, 1}, BEFORE},
+		{Timestamp{2, 1}, Timestamp{2, 0}, AFTER},
+		{Timestamp{2, 1}, Timestamp{2, 1}, EQUAL},
+	}
+	for _, test := range tests {
+		if result := CompareTimestamps(test.t1, test.t2); result != test.expected {
+			t.Error(test, ": ", result)
+		}
+	}
+}
+
+// NewHLC follows the wall clock
+func TestNewHLC(t *testing.T) {
+	var c *HLC = NewHLC()
+	var previous Timestamp = c.Now()
+	for i := 0; i < 100; i++ {
+		var ts Timestamp = c.Now()
+		if CompareTimestamps(previous, ts) != BEFORE {
+			t.Fatal(previous, " then ", ts)
+		}
+		previous = ts
+	}
+}
@@ -0,0 +1,372 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+How-to run:
+  go run ./cmd/drinking --algo=Coordinator 2>&1 |tee /tmp/tmp.log
+or, as a single lock, the baseline of the mutex algorithms:
+  go run ./cmd/drinking --algo=Coordinator --resources=1 --requestSize=1 2>&1 |tee /tmp/tmp.log
+or, with requests of 1 to 3 resources served smallest first:
+  go run ./cmd/drinking --algo=Coordinator --requestSize=3 --requestSizeDist=uniform --queue=smallest 2>&1 |tee /tmp/tmp.log
+
+Benchmark: the baseline of the distributed algorithms, with the same workload
+and the statistics in the format of the Stats package:
+  go run ./cmd/drinking --algo=Coordinator --nodes=4 --nbIterations=100 --requestSize=3 --requestSizeDist=uniform --cs=20ms --think=0-20ms 2>&1 |grep "CS entries in"
+  go run ./cmd/drinking --algo=Rhee --nodes=4 --nbIterations=100 --requestSize=3 --requestSizeDist=uniform --cs=20ms --think=0-20ms 2>&1 |grep "CS entries in"
+  go run ./cmd/drinking --algo=BouabdallahLaforest --nodes=4 --nbIterations=100 --requestSize=3 --requestSizeDist=uniform --cs=20ms --think=0-20ms 2>&1 |grep "CS entries in"
+
+Parameters:
+- Number of nodes, NB_NODES global variable
+- Number of resources, NB_RESOURCES global variable
+- Number of CS entries, NB_ITERATIONS global variable
+- Queue policy of the coordinator, Policy global variable
+- Workload: the resources of each request, the think and CS durations, see
+  the Workload package
+- Seed of the random choices of the nodes
+
+Protocol
+Node #COORDINATOR_NODE is the coordinator of all the resources, and a user as
+the other nodes. A user sends REQ with its resources to the coordinator, that
+answers GRANT when none of them is used, the user enters its CS then sends
+RELEASE. A CS costs 3 messages, whatever the number of resources.
+The coordinator keeps the requests waiting in a queue, ordered by the policy:
+* fifo: by order of arrival
+* smallest: by increasing number of resources, then by order of arrival. The
+  small requests get more concurrency, a large one may starve
+A request is granted when its resources are free and none of them is wanted
+by a request before it in the queue, so that a request is not overtaken by
+the following ones on its resources.
+A node checks when it enters its CS that no other node uses its resources,
+the run ends on a Fatal otherwise.
+*/
+
+package Coordinator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"drinking/Stats"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+/* global variable declaration */
+var NB_NODES          int = 4
+var NB_RESOURCES      int = 4
+var NB_ITERATIONS     int = 10
+var CURRENT_ITERATION int = 0
+var NB_MSG            int = 0
+
+var COORDINATOR_NODE int = 0
+
+var REQ_TYPE     int = 0
+var GRANT_TYPE   int = 1
+var RELEASE_TYPE int = 2
+
+// Queue policies
+var FIFO           string = "fifo"
+var SMALLEST_FIRST string = "smallest"
+
+var POLICIES = []string{FIFO, SMALLEST_FIRST}
+
+// Policy orders the queue of the coordinator, set before Init
+var Policy string = FIFO
+
+// globalMutex protects CURRENT_ITERATION, NB_MSG and resourceUser
+var globalMutex sync.Mutex
+// resourceUser is the node in CS using each resource, to check mutual exclusion
+var resourceUser = make(map[int]int)
+
+var Nodes []Node
+
+// Recorder records the statistics of the run when set, see the Stats package
+var Recorder *Stats.Recorder
+
+// Tracer records the CS entries and exits when set, see the Trace package,
+// the messages are not traced
+var Tracer *Trace.Tracer
+
+type Request struct {
+	requesterNodeId int
+	requestId       int
+	messageType     int
+	resourceId      []int
+}
+
+// State of the coordinator, on node #COORDINATOR_NODE only
+type Coordinator struct {
+	policy string // Policy when the run started
+	busy  map[int]bool // the resources used by a granted request
+	queue []Request // the requests waiting for GRANT, in order of arrival
+}
+
+type Node struct {
+	id            int
+	coordinator   *Coordinator
+	// Implementation specific
+	NbCS          int // the number of time the node entered its Critical Section
+	nbRequests    int
+	Messages      []chan []byte
+	workload      *Workload.Generator
+	csDuration    time.Duration // of the current request
+	stop          chan bool
+}
+
+func (node *Node) String() string {
+	var val string
+	val = fmt.Sprintf("Node #%d\n",
+		node.id)
+	return val
+}
+
+// ParsePolicy returns the queue policy name, whatever its case
+func ParsePolicy(name string) (string, error) {
+	for _, policy := range POLICIES {
+		if strings.EqualFold(name, policy) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown queue policy %q, must be one of %v", name, POLICIES)
+}
+
+func iterationsDone() bool {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return CURRENT_ITERATION >= NB_ITERATIONS
+}
+
+func (node *Node) enterCS(r Request) {
+	log.Print("Node #", node.id, " ######################### enterCS")
+	globalMutex.Lock()
+	for _, res := range r.resourceId {
+		if user, ok := resourceUser[res]; ok {
+			log.Fatal("Node #", node.id, " enters CS with resource #", res, " used by Node #", user)
+		}
+		resourceUser[res] = node.id
+	}
+	CURRENT_ITERATION ++
+	globalMutex.Unlock()
+	node.NbCS ++
+	Recorder.EnterCS(node.id)
+	Tracer.EnterCS(node.id)
+}
+
+func (node *Node) executeCSCode() {
+	log.Print("Node #", node.id, " ######################### executeCSCode")
+	time.Sleep(node.csDuration)
+}
+
+func (node *Node) releaseCS(r Request) {
+	log.Print("Node #", node.id," releaseCS #########################")
+	Tracer.ReleaseCS(node.id)
+	globalMutex.Lock()
+	for _, res := range r.resourceId {
+		delete(resourceUser, res)
+	}
+	globalMutex.Unlock()
+}
+
+// A request is encoded as unsigned varints: requester, request id, message
+// type, number of resources then the resources
+func UnmarshalRequest(text []byte, request *Request) error {
+	var fields []int
+	for len(text) > 0 {
+		v, k := binary.Uvarint(text)
+		if k <= 0 {
+			return fmt.Errorf("malformed request")
+		}
+		fields = append(fields, int(v))
+		text = text[k:]
+	}
+	if len(fields) < 4 || len(fields) != 4 + fields[3] {
+		return fmt.Errorf("malformed request of %d fields", len(fields))
+	}
+	request.requesterNodeId = fields[0]
+	request.requestId      = fields[1]
+	request.messageType    = fields[2]
+	request.resourceId = make ([]int, fields[3])
+	copy(request.resourceId, fields[4:])
+	return nil
+}
+
+func MarshalRequest(request Request) ([]byte, error) {
+	var ret []byte
+	var fields = []int{request.requesterNodeId, request.requestId, request.messageType, len(request.resourceId)}
+	fields = append(fields, request.resourceId...)
+	for _, v := range fields {
+		if v < 0 {
+			return nil, fmt.Errorf("request #%d: negative field %d", request.requestId, v)
+		}
+		ret = binary.AppendUvarint(ret, uint64(v))
+	}
+	return ret, nil
+}
+
+// grant sends GRANT to the queued requests whose resources are free and not
+// wanted by a request before them in the queue
+func (node *Node) grant() {
+	var c *Coordinator = node.coordinator
+	if c.policy == SMALLEST_FIRST {
+		sort.SliceStable(c.queue, func(a int, b int) bool { return len(c.queue[a].resourceId) < len(c.queue[b].resourceId) })
+	}
+	var wanted = make(map[int]bool)
+	var queue []Request
+	for _, request := range c.queue {
+		var free bool = true
+		for _, res := range request.resourceId {
+			if c.busy[res] || wanted[res] {
+				free = false
+			}
+		}
+		if free {
+			for _, res := range request.resourceId {
+				c.busy[res] = true
+			}
+			log.Print("Node #", node.id, " grants REQ#", request.requestId, " of Node #", request.requesterNodeId, ", resources ", request.resourceId)
+			var reply Request = request
+			reply.messageType = GRANT_TYPE
+			// Messages are sent in a different subroutine
+			go node.send(reply, reply.requesterNodeId)
+		} else {
+			queue = append(queue, request)
+			for _, res := range request.resourceId {
+				wanted[res] = true
+			}
+		}
+	}
+	c.queue = queue
+}
+
+func (node *Node) rcv() {
+	for {
+		select {
+		case <-node.stop:
+			return
+		case msg := <-node.Messages[node.id]:
+			var request Request
+			err := UnmarshalRequest(msg, &request)
+			if err != nil {
+				log.Fatal(err)
+			}
+			var requester = request.requesterNodeId
+			if (request.messageType == REQ_TYPE) {
+				log.Print("Node #", node.id, "<-REQ#", request.requestId, ", Requester #", requester, ", resources ", request.resourceId)
+				node.coordinator.queue = append(node.coordinator.queue, request)
+				node.grant()
+			} else if (request.messageType == RELEASE_TYPE) {
+				log.Print("Node #", node.id, "<-RELEASE of REQ#", request.requestId, ", requester =", requester)
+				for _, res := range request.resourceId {
+					delete(node.coordinator.busy, res)
+				}
+				node.grant()
+			} else if (request.messageType == GRANT_TYPE) {
+				log.Print("Node #", node.id, "<-GRANT for REQ#", request.requestId)
+				go node.executeCS(request)
+			} else {
+				log.Fatal("Node #", node.id, " unknown message type ", request.messageType)
+			}
+		}
+	}
+}
+
+// executeCS runs the CS outside of the routine receiving the messages, then
+// requests the next CS
+func (node *Node) executeCS(r Request) {
+	node.enterCS(r)
+	node.executeCSCode()
+	node.releaseCS(r)
+	var release Request = r
+	release.messageType = RELEASE_TYPE
+	go node.send(release, COORDINATOR_NODE)
+	node.requestCS()
+}
+
+func (node *Node) send(request Request, destination int) {
+	content, err := MarshalRequest(request)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Node #", node.id, ", type ", request.messageType, " #", request.requestId, " of ", request.resourceId, " to Node #", destination)
+	select {
+	case node.Messages[destination] <- content:
+		globalMutex.Lock()
+		NB_MSG ++
+		globalMutex.Unlock()
+	case <-node.stop:
+	}
+}
+
+func (node *Node) requestCS() {
+	if iterationsDone() {
+		return
+	}
+	var next Workload.Request = node.workload.Next()
+	time.Sleep(next.Think)
+	node.csDuration = next.CS
+
+	var request Request
+	request.messageType = REQ_TYPE
+	request.requesterNodeId = node.id
+	// request ids are unique without a shared counter
+	request.requestId = node.nbRequests * NB_NODES + node.id
+	node.nbRequests ++
+	request.resourceId = next.Resources
+	Recorder.Request(node.id)
+	go node.send(request, COORDINATOR_NODE)
+}
+
+func (node *Node) Coordinator(wg *sync.WaitGroup) {
+	go node.requestCS()
+	go node.rcv()
+	for {
+		time.Sleep(100 * time.Millisecond)
+		if iterationsDone() {
+			break
+		}
+	}
+
+	log.Print("Node #", node.id," END after ", NB_ITERATIONS," CS entries")
+	wg.Done()
+}
+
+// Stop ends the routines of all the nodes once the run is over
+func Stop() {
+	for i := 0; i < len(Nodes); i++ {
+		close(Nodes[i].stop)
+	}
+}
+
+// Init creates nbNodes nodes sharing nbResources resources, all of them
+// granted by node #COORDINATOR_NODE
+func Init(nbNodes int, nbResources int, nbIterations int, workload *Workload.Config, seed int64) {
+	NB_NODES = nbNodes
+	NB_RESOURCES = nbResources
+	NB_ITERATIONS = nbIterations
+	globalMutex.Lock()
+	CURRENT_ITERATION = 0
+	NB_MSG = 0
+	resourceUser = make(map[int]int)
+	globalMutex.Unlock()
+
+	Nodes = make([]Node, NB_NODES)
+	var messages = make([]chan []byte, NB_NODES)
+
+	log.Print("nb_process #", NB_NODES, ", nb_resources #", NB_RESOURCES, ", policy ", Policy, ", seed ", seed, ", workload ", workload)
+
+	for i := 0; i < NB_NODES; i++ {
+		Nodes[i].id = i
+		Nodes[i].NbCS = 0
+		Nodes[i].workload = workload.Generator(i, seed + int64(i))
+		Nodes[i].stop = make(chan bool)
+		messages[i] = make(chan []byte)
+	}
+	Nodes[COORDINATOR_NODE].coordinator = &Coordinator{policy: Policy, busy: make(map[int]bool)}
+	for i := 0; i < NB_NODES; i++ {
+		Nodes[i].Messages = messages
+	}
+}
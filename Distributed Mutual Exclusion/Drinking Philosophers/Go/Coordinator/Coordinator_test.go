@@ -0,0 +1,50 @@
+package Coordinator
+
+import (
+	"testing"
+
+	"drinking/internal/testutil"
+)
+
+// A request waits for its busy resources, and for the requests before it in
+// the queue on its resources
+func TestGrant(t *testing.T) {
+	defer func() { Policy = FIFO }()
+	var tests = []struct {
+		policy  string
+		busy    []int
+		queue   [][]int // resources of the queued requests, in order of arrival
+		granted []int // the requests granted
+	}{
+		{FIFO, nil, [][]int{{0, 1, 2}, {0}, {3}}, []int{0, 2}},
+		{FIFO, []int{1}, [][]int{{0, 1, 2}, {0}, {3}}, []int{2}},
+		{SMALLEST_FIRST, nil, [][]int{{0, 1, 2}, {0}, {3}}, []int{1, 2}},
+		{SMALLEST_FIRST, nil, [][]int{{0, 1}, {1, 2}, {2}}, []int{0, 2}},
+		{SMALLEST_FIRST, []int{2}, [][]int{{0, 1}, {1, 2}, {2}}, []int{0}},
+	}
+	for _, test := range tests {
+		Policy = test.policy
+		Init(2, 4, 1, testutil.NewWorkload(t, 4, 1), 1)
+		var c *Coordinator = Nodes[COORDINATOR_NODE].coordinator
+		for _, res := range test.busy {
+			c.busy[res] = true
+		}
+		for k, resources := range test.queue {
+			c.queue = append(c.queue, Request{requesterNodeId: 1, requestId: k, messageType: REQ_TYPE, resourceId: resources})
+		}
+		Nodes[COORDINATOR_NODE].grant()
+		var waiting = make(map[int]bool)
+		for _, r := range c.queue {
+			waiting[r.requestId] = true
+		}
+		for _, id := range test.granted {
+			if waiting[id] {
+				t.Error(test.policy, ", busy ", test.busy, ", queue ", test.queue, ": request #", id, " not granted")
+			}
+		}
+		if len(waiting) != len(test.queue) - len(test.granted) {
+			t.Error(test.policy, ", busy ", test.busy, ", queue ", test.queue, ": ", len(test.queue) - len(waiting), " requests granted instead of ", test.granted)
+		}
+		Stop()
+	}
+}
@@ -0,0 +1,412 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+How-to run:
+  go run ./cmd/drinking --algo=Dijkstra 2>&1 |tee /tmp/tmp.log
+or, with requests of 1 to 3 resources:
+  go run ./cmd/drinking --algo=Dijkstra --requestSize=3 --requestSizeDist=uniform 2>&1 |tee /tmp/tmp.log
+or, with a hot spot and short critical sections:
+  go run ./cmd/drinking --algo=Dijkstra --pattern=zipf --cs=50-200ms --think=exp:50ms 2>&1 |tee /tmp/tmp.log
+or, with 4 nodes sharing 64 resources, each request spanning 1 to 32 of them:
+  go run ./cmd/drinking --algo=Dijkstra --nodes=4 --resources=64 --requestSize=32 --requestSizeDist=uniform 2>&1 |tee /tmp/tmp.log
+
+Benchmark: the same workload, with the Rhee and Bouabdallah-Laforest algorithms,
+all of them print their statistics in the format of the Stats package:
+  go run ./cmd/drinking --algo=Dijkstra --nodes=4 --nbIterations=100 --requestSize=3 --requestSizeDist=uniform --cs=20ms --think=0-20ms 2>&1 |grep "CS entries in"
+  go run ./cmd/drinking --algo=Rhee --nodes=4 --nbIterations=100 --requestSize=3 --requestSizeDist=uniform --cs=20ms --think=0-20ms 2>&1 |grep "CS entries in"
+  go run ./cmd/drinking --algo=BouabdallahLaforest --nodes=4 --nbIterations=100 --requestSize=3 --requestSizeDist=uniform --cs=20ms --think=0-20ms 2>&1 |grep "CS entries in"
+Rhee and Bouabdallah-Laforest have one resource per node.
+
+Terminology
+* A scheduler is any computing device which runs the Dijkstra's incremental algorithm
+* Each resource has a manager, the one of resource #r runs on node #r modulo the
+  number of nodes
+
+Parameters:
+- Number of nodes, NB_NODES global variable
+- Number of resources, NB_RESOURCES global variable
+- Number of CS entries, NB_ITERATIONS global variable
+- Workload: the resources of each request, the think and CS durations, see
+  the Workload package
+- Seed of the random choices of the nodes
+
+Protocol
+A request goes through the managers of its resources in decreasing order of
+resource id, a manager keeps its resource for the request when it is free
+and forwards the request to the manager of the next resource, it queues the
+request otherwise. The manager of the lowest resource sends REP to the
+requester, that enters its CS then sends FREE to all the managers. Resources
+are always acquired in the same order, there is no deadlock.
+A node checks when it enters its CS that no other node uses its resources,
+the run ends on a Fatal otherwise.
+*/
+
+/*
+    Go implementation of Dijkstra dining philosophers algorithm
+    Algorithm by Dijkstra 1971
+
+References :
+*/
+
+package Dijkstra
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"drinking/Stats"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+/* global variable declaration */
+var NB_NODES          int = 4
+var NB_RESOURCES      int = 4
+var NB_ITERATIONS     int = 10
+var CURRENT_ITERATION int = 0
+var NB_MSG            int = 0
+
+var NO_NEXT    int = -1
+
+var REQ_TYPE   int = 0
+var REP_TYPE   int = 1
+var FREE_TYPE  int = 2
+
+// globalMutex protects CURRENT_ITERATION, NB_MSG and resourceUser
+var globalMutex sync.Mutex
+// resourceUser is the node in CS using each resource, to check mutual exclusion
+var resourceUser = make(map[int]int)
+
+var Nodes []Node
+
+// Recorder records the statistics of the run when set, see the Stats package
+var Recorder *Stats.Recorder
+
+// Tracer records the CS entries and exits when set, see the Trace package,
+// the messages are not traced
+var Tracer *Trace.Tracer
+
+/*
+// Debug function
+func displayNodes() {
+	for i := 0; i < len(nodes); i++ {
+		log.Print("Node #", nodes[i].id, ", last=", nodes[i].last, ", next=", nodes[i].next)
+	}
+}
+*/
+type Request struct {
+	requesterNodeId int
+	requestId       int
+	messageType     int
+	resource        int // the resource whose manager receives the message
+	resourceId      []int
+}
+
+// Manager of a resource
+type Manager struct {
+	resourcePresent bool
+	pendingRequests []Request
+}
+
+type Node struct {
+	// From the algorithm
+	id              int
+	managers        map[int]*Manager // the managers of the resources of the node
+	// Implementation specific
+	NbCS          int // the number of time the node entered its Critical Section
+	nbRequests    int
+	Messages      []chan []byte
+	workload      *Workload.Generator
+	csDuration    time.Duration // of the current request
+	stop          chan bool
+}
+
+
+func (node *Node) String() string {
+	var val string
+	val = fmt.Sprintf("Node #%d\n",
+		node.id)
+	return val
+}
+
+func managerNode(resource int) int {
+	return resource % NB_NODES
+}
+
+func iterationsDone() bool {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return CURRENT_ITERATION >= NB_ITERATIONS
+}
+
+func (node *Node) enterCS(r Request) {
+	log.Print("Node #", node.id, " ######################### enterCS")
+	globalMutex.Lock()
+	for _, res := range r.resourceId {
+		if user, ok := resourceUser[res]; ok {
+			log.Fatal("Node #", node.id, " enters CS with resource #", res, " used by Node #", user)
+		}
+		resourceUser[res] = node.id
+	}
+	CURRENT_ITERATION ++
+	globalMutex.Unlock()
+	node.NbCS ++
+	Recorder.EnterCS(node.id)
+	Tracer.EnterCS(node.id)
+	// log.Print(n)
+}
+
+func (node *Node) executeCSCode() {
+	log.Print("Node #", node.id, " ######################### executeCSCode")
+	// log.Print(n)
+	time.Sleep(node.csDuration)
+}
+
+func (node *Node) releaseCS(r Request) {
+	log.Print("Node #", node.id," releaseCS #########################")
+	Tracer.ReleaseCS(node.id)
+	globalMutex.Lock()
+	for _, res := range r.resourceId {
+		delete(resourceUser, res)
+	}
+	globalMutex.Unlock()
+	// log.Print(n)
+}
+
+// A request is encoded as unsigned varints: requester, request id, message
+// type, resource of the manager, number of resources then the resources
+func UnmarshalRequest(text []byte, request *Request) error {
+	var fields []int
+	for len(text) > 0 {
+		v, k := binary.Uvarint(text)
+		if k <= 0 {
+			return fmt.Errorf("malformed request")
+		}
+		fields = append(fields, int(v))
+		text = text[k:]
+	}
+	if len(fields) < 5 || len(fields) != 5 + fields[4] {
+		return fmt.Errorf("malformed request of %d fields", len(fields))
+	}
+	request.requesterNodeId = fields[0]
+	request.requestId      = fields[1]
+	request.messageType    = fields[2]
+	request.resource       = fields[3]
+	request.resourceId = make ([]int, fields[4])
+	copy(request.resourceId, fields[5:])
+	return nil
+}
+
+func MarshalRequest(request Request) ([]byte, error) {
+	var ret []byte
+	var fields = []int{request.requesterNodeId, request.requestId, request.messageType, request.resource, len(request.resourceId)}
+	fields = append(fields, request.resourceId...)
+	for _, v := range fields {
+		if v < 0 {
+			return nil, fmt.Errorf("request #%d: negative field %d", request.requestId, v)
+		}
+		ret = binary.AppendUvarint(ret, uint64(v))
+	}
+	return ret, nil
+}
+
+// getNextResourceForReq returns the highest resource of request lower than current, NO_NEXT if none
+func getNextResourceForReq(request Request, current int) int {
+	var next int = NO_NEXT
+
+	for i := 0; i < len(request.resourceId); i++ {
+		if request.resourceId[i] < current && request.resourceId[i] > next {
+			next = request.resourceId[i]
+		}
+	}
+	return next
+}
+
+// grant gives the resource of its manager to the request, and forwards it
+// to the manager of its next resource, or replies to the requester
+func (node *Node) grant(m *Manager, request Request) {
+	m.resourcePresent = false
+	var next_res = getNextResourceForReq(request, request.resource)
+	if next_res == NO_NEXT {
+		log.Print("Node #", node.id, "                               with an ack")
+		var ack Request = request
+		ack.messageType = REP_TYPE
+		// Messages are sent in a different subroutine
+		go node.send(ack, ack.requesterNodeId)
+	} else {
+		log.Print("Node #", node.id, "                               forwarding to next res #", next_res)
+		var forward Request = request
+		forward.resource = next_res
+		// Messages are sent in a different subroutine
+		go node.send(forward, managerNode(next_res))
+	}
+}
+
+func (node *Node) rcv() {
+	// log.Print("Node #", node.id," rcv")
+	for {
+		select {
+		case <-node.stop:
+			return
+		case msg := <-node.Messages[node.id]:
+			var request Request
+			err := UnmarshalRequest(msg, &request)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if (request.messageType == REQ_TYPE) {
+				var requester = request.requesterNodeId
+				var res = request.resourceId
+				log.Print("Node #", node.id, "<-REQ#", request.requestId, ", Requester #", requester, ", resource #", request.resource, ", nb of res:", len(res))
+				var m *Manager = node.managers[request.resource]
+				if m.resourcePresent == true {
+					node.grant(m, request)
+				} else {
+					log.Print("Node #", node.id, ", Resource #", request.resource, " NOT available, appending request to pendinglist")
+					m.pendingRequests = append(m.pendingRequests, request)
+				}
+			}  else if (request.messageType == FREE_TYPE) {
+				var requester = request.requesterNodeId
+				log.Print("Node #", node.id, "<- FREE of resource #", request.resource, " for REQ#", request.requestId, ", requester =", requester)
+				var m *Manager = node.managers[request.resource]
+				m.resourcePresent = true
+				// log.Print(len(m.pendingRequests), " pending requests on node#", node.id)
+
+				if len(m.pendingRequests) > 0 {
+					var r Request = m.pendingRequests[0]
+					m.pendingRequests = m.pendingRequests[1:]
+
+					log.Print("Node #", node.id, ", Continuing REQ#", r.requestId)
+					node.grant(m, r)
+				}
+			}  else if (request.messageType == REP_TYPE) {
+				var requester = request.requesterNodeId
+				log.Print("Node #", node.id, "<- REPLY for REQ#", request.requestId, ", requester =", requester)
+				go node.executeCS(request)
+			} else {
+				log.Fatal("Fatal Error")
+			}
+		}
+	}
+	// log.Print(n)
+	// log.Print("Node #", node.id, " end rcv")
+}
+
+// executeCS runs the CS outside of the routine receiving the messages, then
+// requests the next CS
+func (node *Node) executeCS(r Request) {
+	node.enterCS(r)
+	node.executeCSCode()
+	node.releaseCS(r)
+	node.freeResources(r)
+	node.requestCS()
+}
+
+func (node *Node) freeResources(r Request) {
+	for i := 0; i < len(r.resourceId); i++ {
+		var freeRequest Request = r
+		freeRequest.messageType = FREE_TYPE
+		freeRequest.resource = r.resourceId[i]
+		go node.send(freeRequest, managerNode(r.resourceId[i]))
+	}
+}
+
+func (node *Node) send(request Request, destination int) {
+	content, err := MarshalRequest(request)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Node #", node.id, ", type ", request.messageType, " #", request.requestId, " for resource #", request.resource, " of ", request.resourceId, " to Node #", destination)
+	select {
+	case node.Messages[destination] <- content:
+		globalMutex.Lock()
+		NB_MSG ++
+		globalMutex.Unlock()
+	case <-node.stop:
+	}
+}
+
+func (node *Node) requestCS() {
+	// log.Print("Node #", node.id, " requestCS")
+	if iterationsDone() {
+		return
+	}
+	var next Workload.Request = node.workload.Next()
+	time.Sleep(next.Think)
+	node.csDuration = next.CS
+
+	var request Request
+	request.messageType = REQ_TYPE
+	request.requesterNodeId = node.id
+	// request ids are unique without a shared counter
+	request.requestId = node.nbRequests * NB_NODES + node.id
+	node.nbRequests ++
+
+	request.resourceId = next.Resources
+	// the request goes through the resources in decreasing order
+	request.resource = getNextResourceForReq(request, NB_RESOURCES)
+	Recorder.Request(node.id)
+	go node.send(request, managerNode(request.resource))
+
+	// log.Print("Node #", node.id," END")
+}
+
+func (node *Node) Dijkstra(wg *sync.WaitGroup) {
+	// log.Print("Node #", node.id)
+
+	go node.requestCS()
+	go node.rcv()
+	for {
+
+		time.Sleep(100 * time.Millisecond)
+		if iterationsDone() {
+			break
+		}
+	}
+
+	log.Print("Node #", node.id," END after ", NB_ITERATIONS," CS entries")
+	wg.Done()
+}
+
+// Stop ends the routines of all the nodes once the run is over
+func Stop() {
+	for i := 0; i < len(Nodes); i++ {
+		close(Nodes[i].stop)
+	}
+}
+
+// Init creates nbNodes nodes sharing nbResources resources, resource #r is
+// managed by node #r modulo nbNodes
+func Init(nbNodes int, nbResources int, nbIterations int, workload *Workload.Config, seed int64) {
+	NB_NODES = nbNodes
+	NB_RESOURCES = nbResources
+	NB_ITERATIONS = nbIterations
+	CURRENT_ITERATION = 0
+	NB_MSG = 0
+	resourceUser = make(map[int]int)
+
+	Nodes = make([]Node, NB_NODES)
+	var messages = make([]chan []byte, NB_NODES)
+
+	log.Print("nb_process #", NB_NODES, ", nb_resources #", NB_RESOURCES, ", seed ", seed, ", workload ", workload)
+
+	// Initialization
+	for i := 0; i < NB_NODES; i++ {
+		Nodes[i].id = i
+		Nodes[i].managers = make(map[int]*Manager)
+		Nodes[i].NbCS = 0
+		Nodes[i].workload = workload.Generator(i, seed + int64(i))
+		Nodes[i].stop = make(chan bool)
+
+		messages[i] = make(chan []byte)
+	}
+	for r := 0; r < NB_RESOURCES; r++ {
+		Nodes[managerNode(r)].managers[r] = &Manager{resourcePresent: true}
+	}
+	for i := 0; i < NB_NODES; i++ {
+		Nodes[i].Messages = messages
+	}
+}
@@ -0,0 +1,123 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Dining philosophers as a subroutine: a drinking philosophers algorithm, such
+as Rhee's, solves the conflicts between neighbors with a dining philosophers
+layer, whatever its algorithm. Each node has its Subroutine, created with
+the conflict graph and two callbacks of the node:
+* send, to send a Message of the subroutine to a node with the transport of
+  the node, which gives the messages it receives back to Receive
+* granted, called when the node enters the CS of the subroutine, i.e. no
+  neighbor is in it, until Release
+
+Usage:
+  newSubroutine, err := Dining.Parse("chandymisra")
+  n.dining = newSubroutine(n.Id, graph, func(dst int, m Dining.Message) {
+  	go n.sendDining(dst, m)
+  }, func() {
+  	n.EnterDiningCS(n.currentRequest)
+  })
+  n.dining.Request()
+  ... on reception of a message of the subroutine: n.dining.Receive(m)
+  n.dining.Release()
+
+The methods of a Subroutine are called with the lock of the node held, they
+call send and granted with it held too: send must not wait for the
+reception of the message.
+
+Subroutines:
+* chandymisra: Chandy-Misra hygienic forks, a fork shared with each
+  neighbor, clean forks are kept by hungry philosophers, dirty ones given
+  on request, see hygienic.go. It implements Suspecter
+* ordered: Dijkstra's resource ordering, the forks are acquired one by one
+  in a global order, which prevents cycles of waiting philosophers, see
+  ordered.go
+* arbiter: a centralized arbiter, node #ARBITER_NODE, grants the CS to a
+  philosopher when none of its neighbors is in it, see arbiter.go
+*/
+
+package Dining
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"drinking/Topology"
+)
+
+// Subroutine names
+var CHANDY_MISRA string = "chandymisra"
+var ORDERED      string = "ordered"
+var ARBITER      string = "arbiter"
+
+var SUBROUTINES = []string{CHANDY_MISRA, ORDERED, ARBITER}
+
+// Message types
+var REQUEST_FORK int = 0
+var SEND_FORK    int = 1
+var REQUEST      int = 2 // to the arbiter
+var GRANT        int = 3 // from the arbiter
+var RELEASE      int = 4 // to the arbiter
+
+var MESSAGE_NAMES = []string{"REQUEST_FORK", "SEND_FORK", "REQUEST", "GRANT", "RELEASE"}
+
+// Message of a subroutine, carried by the messages of the node
+type Message struct {
+	Type   int
+	Sender int
+}
+
+// Subroutine is the dining philosophers layer of a node
+type Subroutine interface {
+	// Request makes the node hungry, granted is called once no neighbor is in CS
+	Request()
+	// Release leaves the CS of the subroutine
+	Release()
+	// Receive handles a message of the subroutine sent to the node
+	Receive(message Message)
+}
+
+// Suspecter is implemented by the subroutines that can do without a crashed
+// neighbor, told by the failure detector of the node
+type Suspecter interface {
+	// Suspect makes the subroutine stop waiting for neighbor
+	Suspect(neighbor int)
+	// Trust makes the subroutine wait for neighbor again
+	Trust(neighbor int)
+}
+
+// New creates the subroutine of node id in the conflict graph
+type New func(id int, graph *Topology.Graph, send func(dst int, message Message), granted func()) Subroutine
+
+func MessageName(messageType int) string {
+	if messageType >= 0 && messageType < len(MESSAGE_NAMES) {
+		return MESSAGE_NAMES[messageType]
+	}
+	return strconv.Itoa(messageType)
+}
+
+// Parse returns the constructor of the subroutine name, whatever its case
+func Parse(name string) (New, error) {
+	switch strings.ToLower(name) {
+	case CHANDY_MISRA:
+		return NewChandyMisra, nil
+	case ORDERED:
+		return NewOrdered, nil
+	case ARBITER:
+		return NewArbiter, nil
+	}
+	return nil, fmt.Errorf("unknown dining philosophers subroutine %q, must be one of %v", name, SUBROUTINES)
+}
+
+// forkIndex returns the index of the fork of node id shared with neighbor
+func forkIndex(id int, neighbors []int, neighbor int) int {
+	for j := 0; j < len(neighbors); j++ {
+		if neighbors[j] == neighbor {
+			return j
+		}
+	}
+	log.Fatal("Node #", id, " shares no fork with Node #", neighbor)
+	return -1
+}
@@ -0,0 +1,159 @@
+package Dining
+
+import (
+	"math/rand"
+	"testing"
+
+	"drinking/Topology"
+)
+
+type envelope struct {
+	dst     int
+	message Message
+}
+
+// simulation runs the subroutines of the nodes of a graph, the messages are
+// delivered in a random order
+type simulation struct {
+	t        *testing.T
+	name     string
+	graph    *Topology.Graph
+	nodes    []Subroutine
+	eating   []bool
+	hungry   []bool
+	nbCS     []int
+	messages []envelope
+	rand     *rand.Rand
+}
+
+func newSimulation(t *testing.T, name string, graph *Topology.Graph, seed int64) *simulation {
+	newSubroutine, err := Parse(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s = &simulation{t: t, name: name, graph: graph, rand: rand.New(rand.NewSource(seed))}
+	s.eating = make([]bool, graph.NbNodes)
+	s.hungry = make([]bool, graph.NbNodes)
+	s.nbCS = make([]int, graph.NbNodes)
+	for i := 0; i < graph.NbNodes; i++ {
+		var id int = i
+		s.nodes = append(s.nodes, newSubroutine(id, graph, func(dst int, message Message) {
+			s.messages = append(s.messages, envelope{dst, message})
+		}, func() {
+			s.enter(id)
+		}))
+	}
+	return s
+}
+
+// enter checks that no neighbor of node id is in CS
+func (s *simulation) enter(id int) {
+	if !s.hungry[id] || s.eating[id] {
+		s.t.Fatal(s.name, ", ", s.graph, ": node #", id, " granted while not hungry")
+	}
+	for _, neighbor := range s.graph.Neighbors[id] {
+		if s.eating[neighbor] {
+			s.t.Fatal(s.name, ", ", s.graph, ": node #", id, " enters its CS while its neighbor #", neighbor, " is in it")
+		}
+	}
+	s.hungry[id] = false
+	s.eating[id] = true
+	s.nbCS[id] ++
+}
+
+// step requests, releases or delivers a message at random, it returns false
+// when there is nothing to do
+func (s *simulation) step() bool {
+	var id int = s.rand.Intn(s.graph.NbNodes)
+	switch s.rand.Intn(3) {
+	case 0:
+		if !s.hungry[id] && !s.eating[id] {
+			s.hungry[id] = true
+			s.nodes[id].Request()
+			return true
+		}
+	case 1:
+		if s.eating[id] {
+			s.eating[id] = false
+			s.nodes[id].Release()
+			return true
+		}
+	}
+	if len(s.messages) == 0 {
+		return false
+	}
+	var k int = s.rand.Intn(len(s.messages))
+	var e envelope = s.messages[k]
+	s.messages = append(s.messages[:k], s.messages[k + 1:]...)
+	s.nodes[e.dst].Receive(e.message)
+	return true
+}
+
+// Each node enters its CS nbCS times, no neighbor being in it at the same time
+func TestSubroutines(t *testing.T) {
+	var nbCS int = 20
+	for _, name := range SUBROUTINES {
+		for _, spec := range []string{"complete", "ring", "grid", "random"} {
+			for seed := int64(1); seed <= 5; seed++ {
+				graph, err := Topology.Parse(spec, 7, seed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var s *simulation = newSimulation(t, name, graph, seed)
+				var done = func() bool {
+					for i := 0; i < graph.NbNodes; i++ {
+						if s.nbCS[i] < nbCS {
+							return false
+						}
+					}
+					return true
+				}
+				for k := 0; k < 1000000 && !done(); k++ {
+					s.step()
+				}
+				if !done() {
+					t.Error(name, ", ", graph, ", seed ", seed, ": CS entries ", s.nbCS, ", hungry ", s.hungry, ", ", len(s.messages), " messages in transit")
+				}
+			}
+		}
+	}
+}
+
+// A philosopher eats without the fork of a suspected neighbor, and requests it
+// again once the neighbor is trusted
+func TestSuspect(t *testing.T) {
+	var sent []Message
+	var nbGranted int = 0
+	var p Subroutine = NewChandyMisra(1, Topology.Complete(2), func(dst int, message Message) {
+		sent = append(sent, message)
+	}, func() {
+		nbGranted ++
+	})
+	var suspecter Suspecter = p.(Suspecter)
+	p.Request()
+	if len(sent) != 1 || sent[0].Type != REQUEST_FORK || nbGranted != 0 {
+		t.Fatal(sent, nbGranted)
+	}
+	suspecter.Suspect(0)
+	if nbGranted != 1 {
+		t.Fatal("not granted with the fork of a suspected neighbor")
+	}
+	p.Release()
+	p.Receive(Message{REQUEST_FORK, 0})
+	suspecter.Trust(0)
+	p.Request()
+	if len(sent) != 2 || sent[1].Type != REQUEST_FORK || nbGranted != 1 {
+		t.Error(sent, nbGranted)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, name := range []string{"ChandyMisra", "ORDERED", "arbiter"} {
+		if _, err := Parse(name); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := Parse("foo"); err == nil {
+		t.Error("foo parsed")
+	}
+}
@@ -0,0 +1,94 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Centralized arbiter as a dining philosophers subroutine: a hungry
+philosopher sends REQUEST to node #ARBITER_NODE, that answers GRANT when
+none of its neighbors is in CS, and sends RELEASE when it leaves its CS.
+The arbiter queues the requests in FIFO order: a request is granted when no
+neighbor is in CS nor queued before it, so that a philosopher does not
+starve.
+*/
+
+package Dining
+
+import (
+	"drinking/Topology"
+)
+
+// ARBITER_NODE is the node granting the CS
+var ARBITER_NODE int = 0
+
+type Arbiter struct {
+	Id      int
+	Hungry  bool
+	graph   *Topology.Graph
+	send    func(dst int, message Message)
+	granted func()
+	// state of the arbiter, on node #ARBITER_NODE only
+	eating  map[int]bool
+	queue   []int // philosophers waiting for GRANT, in order of arrival
+}
+
+func NewArbiter(id int, graph *Topology.Graph, send func(dst int, message Message), granted func()) Subroutine {
+	var p Arbiter
+	p.Id = id
+	p.graph = graph
+	p.send = send
+	p.granted = granted
+	if id == ARBITER_NODE {
+		p.eating = make(map[int]bool)
+	}
+	return &p
+}
+
+func (p *Arbiter) Request() {
+	if p.Hungry {
+		return
+	}
+	p.Hungry = true
+	p.send(ARBITER_NODE, Message{REQUEST, p.Id})
+}
+
+func (p *Arbiter) Release() {
+	p.Hungry = false
+	p.send(ARBITER_NODE, Message{RELEASE, p.Id})
+}
+
+func (p *Arbiter) Receive(message Message) {
+	switch message.Type {
+	case REQUEST:
+		p.queue = append(p.queue, message.Sender)
+		p.grant()
+	case RELEASE:
+		delete(p.eating, message.Sender)
+		p.grant()
+	case GRANT:
+		p.granted()
+	}
+}
+
+// grant sends GRANT to the queued philosophers whose neighbors are neither in
+// CS nor queued before them
+func (p *Arbiter) grant() {
+	var blocked = make(map[int]bool)
+	var queue []int
+	for _, philosopher := range p.queue {
+		// a REQUEST may arrive before the RELEASE of the previous CS
+		var free bool = !blocked[philosopher] && !p.eating[philosopher]
+		for _, neighbor := range p.graph.Neighbors[philosopher] {
+			if p.eating[neighbor] {
+				free = false
+			}
+		}
+		if free {
+			p.eating[philosopher] = true
+			p.send(philosopher, Message{GRANT, ARBITER_NODE})
+		} else {
+			queue = append(queue, philosopher)
+		}
+		for _, neighbor := range p.graph.Neighbors[philosopher] {
+			blocked[neighbor] = true
+		}
+	}
+	p.queue = queue
+}
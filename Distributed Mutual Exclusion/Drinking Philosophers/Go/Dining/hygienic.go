@@ -0,0 +1,158 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Chandy-Misra hygienic dining philosophers as a subroutine, the ChandyMisra
+package runs it with its transport and its failure detector:
+* a fork is shared with each neighbor, initially with the one with the
+  lowest id, and dirty
+* a hungry philosopher requests the forks it does not hold, it enters its CS
+  with all of them, the forks are then dirty
+* a philosopher holding a fork gives it on request when it is thinking, or
+  hungry and the fork is dirty, and then requests it again if hungry. It
+  keeps a clean fork while hungry, and all its forks while in CS, and gives
+  them on release
+* the fork shared with a suspected neighbor is used as if it was held, it is
+  neither requested nor sent. When the neighbor is trusted again, the fork
+  is requested and sent as usual
+*/
+
+package Dining
+
+import (
+	"drinking/Topology"
+)
+
+var STATE_THINKING int = 0
+var STATE_HUNGRY   int = 1
+var STATE_EATING   int = 2
+
+type ChandyMisra struct {
+	Id            int
+	State         int
+	ForkId        []int // the neighbors, one fork is shared with each of them
+	ForkStatus    []bool // true when the fork is held
+	ForkClean     []bool
+	forkRequested []bool // true when the fork was requested and not received yet
+	deferred      []bool // true when the neighbor requested the fork while it was kept
+	suspected     []bool
+	send          func(dst int, message Message)
+	granted       func()
+}
+
+func NewChandyMisra(id int, graph *Topology.Graph, send func(dst int, message Message), granted func()) Subroutine {
+	var p ChandyMisra
+	p.Id = id
+	p.State = STATE_THINKING
+	p.ForkId = append([]int(nil), graph.Neighbors[id]...)
+	p.ForkStatus = make([]bool, len(p.ForkId))
+	p.ForkClean = make([]bool, len(p.ForkId))
+	p.forkRequested = make([]bool, len(p.ForkId))
+	p.deferred = make([]bool, len(p.ForkId))
+	p.suspected = make([]bool, len(p.ForkId))
+	for j := 0; j < len(p.ForkId); j++ {
+		p.ForkStatus[j] = Topology.InitialHolder(id, p.ForkId[j]) == id
+	}
+	p.send = send
+	p.granted = granted
+	return &p
+}
+
+func (p *ChandyMisra) Request() {
+	if p.State != STATE_THINKING {
+		return
+	}
+	p.State = STATE_HUNGRY
+	for j := 0; j < len(p.ForkId); j++ {
+		p.requestFork(j)
+	}
+	p.enterIfICan()
+}
+
+// requestFork requests the fork j if it is missing and not requested yet
+func (p *ChandyMisra) requestFork(j int) {
+	if p.ForkStatus[j] == false && p.forkRequested[j] == false && p.suspected[j] == false {
+		p.forkRequested[j] = true
+		p.send(p.ForkId[j], Message{REQUEST_FORK, p.Id})
+	}
+}
+
+func (p *ChandyMisra) enterIfICan() {
+	if p.State != STATE_HUNGRY {
+		return
+	}
+	for j := 0; j < len(p.ForkId); j++ {
+		if p.ForkStatus[j] == false && p.suspected[j] == false {
+			return
+		}
+	}
+	p.State = STATE_EATING
+	p.granted()
+}
+
+// Release makes the forks dirty, and sends them to the neighbors that
+// requested them while the philosopher was hungry or in CS
+func (p *ChandyMisra) Release() {
+	p.State = STATE_THINKING
+	for j := 0; j < len(p.ForkId); j++ {
+		p.ForkClean[j] = false
+		if p.deferred[j] && p.ForkStatus[j] && p.suspected[j] == false {
+			p.deferred[j] = false
+			p.ForkStatus[j] = false
+			p.send(p.ForkId[j], Message{SEND_FORK, p.Id})
+		}
+	}
+}
+
+func (p *ChandyMisra) Receive(message Message) {
+	var j int = forkIndex(p.Id, p.ForkId, message.Sender)
+	switch message.Type {
+	case REQUEST_FORK:
+		p.deferred[j] = true
+		p.answer(j)
+	case SEND_FORK:
+		p.ForkStatus[j] = true
+		p.ForkClean[j] = true
+		p.forkRequested[j] = false
+		p.enterIfICan()
+	}
+}
+
+// answer sends the fork j requested by the neighbor when it is held and the
+// philosopher is thinking, or hungry and the fork is dirty. The fork is kept
+// while it is clean and needed, and from a suspected neighbor. A request for
+// a fork still on its way is answered on release
+func (p *ChandyMisra) answer(j int) {
+	if p.deferred[j] == false || p.ForkStatus[j] == false || p.suspected[j] {
+		return
+	}
+	if p.State == STATE_THINKING || (p.State == STATE_HUNGRY && p.ForkClean[j] == false) {
+		p.deferred[j] = false
+		p.ForkStatus[j] = false
+		p.send(p.ForkId[j], Message{SEND_FORK, p.Id})
+		if p.State == STATE_HUNGRY {
+			// the fork is needed back
+			p.forkRequested[j] = true
+			p.send(p.ForkId[j], Message{REQUEST_FORK, p.Id})
+		}
+	}
+}
+
+// Suspect lets the philosopher eat without the fork shared with neighbor
+func (p *ChandyMisra) Suspect(neighbor int) {
+	var j int = forkIndex(p.Id, p.ForkId, neighbor)
+	p.suspected[j] = true
+	// the request may be lost, it is sent again once the neighbor is trusted
+	p.forkRequested[j] = false
+	p.enterIfICan()
+}
+
+// Trust requests the fork shared with neighbor again if the philosopher is
+// hungry, and answers its pending request
+func (p *ChandyMisra) Trust(neighbor int) {
+	var j int = forkIndex(p.Id, p.ForkId, neighbor)
+	p.suspected[j] = false
+	if p.State == STATE_HUNGRY {
+		p.requestFork(j)
+	}
+	p.answer(j)
+}
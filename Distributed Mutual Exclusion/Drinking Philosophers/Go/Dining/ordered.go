@@ -0,0 +1,106 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Dijkstra's resource ordering as a dining philosophers subroutine: the fork
+of the edge i-j, i < j, is ranked by (i, j). A hungry philosopher acquires
+its forks one by one by increasing rank, and keeps the ones acquired until
+it leaves its CS. A philosopher waiting for a fork only holds forks of lower
+rank, so the waiting philosophers cannot form a cycle.
+
+A fork that is not acquired, because its holder is thinking or has not
+reached its rank yet, is given on request. The requests for an acquired
+fork are answered on release.
+*/
+
+package Dining
+
+import (
+	"sort"
+
+	"drinking/Topology"
+)
+
+type Ordered struct {
+	Id         int
+	Hungry     bool
+	ForkId     []int // the neighbors, sorted by increasing rank of the fork shared with them
+	ForkStatus []bool // true when the fork is held
+	acquired   int // the forks of index lower than acquired are kept until release
+	deferred   []bool // true when the neighbor requested an acquired fork
+	send       func(dst int, message Message)
+	granted    func()
+}
+
+// rankBefore returns true if the fork of the edge id-a is ranked before the one of id-b
+func rankBefore(id int, a int, b int) bool {
+	var a1, a2 int = min(id, a), max(id, a)
+	var b1, b2 int = min(id, b), max(id, b)
+	return a1 < b1 || (a1 == b1 && a2 < b2)
+}
+
+func NewOrdered(id int, graph *Topology.Graph, send func(dst int, message Message), granted func()) Subroutine {
+	var p Ordered
+	p.Id = id
+	p.ForkId = append([]int(nil), graph.Neighbors[id]...)
+	sort.Slice(p.ForkId, func(a int, b int) bool { return rankBefore(id, p.ForkId[a], p.ForkId[b]) })
+	p.ForkStatus = make([]bool, len(p.ForkId))
+	p.deferred = make([]bool, len(p.ForkId))
+	for j := 0; j < len(p.ForkId); j++ {
+		p.ForkStatus[j] = Topology.InitialHolder(id, p.ForkId[j]) == id
+	}
+	p.send = send
+	p.granted = granted
+	return &p
+}
+
+func (p *Ordered) Request() {
+	if p.Hungry {
+		return
+	}
+	p.Hungry = true
+	p.acquired = 0
+	p.acquire()
+}
+
+// acquire keeps the forks held in rank order, and requests the first missing one
+func (p *Ordered) acquire() {
+	for p.acquired < len(p.ForkId) && p.ForkStatus[p.acquired] {
+		p.acquired ++
+	}
+	if p.acquired < len(p.ForkId) {
+		p.send(p.ForkId[p.acquired], Message{REQUEST_FORK, p.Id})
+		return
+	}
+	p.granted()
+}
+
+func (p *Ordered) Release() {
+	p.Hungry = false
+	p.acquired = 0
+	for j := 0; j < len(p.ForkId); j++ {
+		if p.deferred[j] && p.ForkStatus[j] {
+			p.deferred[j] = false
+			p.ForkStatus[j] = false
+			p.send(p.ForkId[j], Message{SEND_FORK, p.Id})
+		}
+	}
+}
+
+func (p *Ordered) Receive(message Message) {
+	var j int = forkIndex(p.Id, p.ForkId, message.Sender)
+	switch message.Type {
+	case REQUEST_FORK:
+		if p.ForkStatus[j] && !(p.Hungry && j < p.acquired) {
+			p.ForkStatus[j] = false
+			p.send(message.Sender, Message{SEND_FORK, p.Id})
+		} else {
+			// the fork is acquired, or still on its way
+			p.deferred[j] = true
+		}
+	case SEND_FORK:
+		p.ForkStatus[j] = true
+		if p.Hungry && j == p.acquired {
+			p.acquire()
+		}
+	}
+}
@@ -0,0 +1,397 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Common driver of the resource allocation algorithms: each algorithm
+implements Algorithm and is registered under its name, cmd/drinking runs any of
+them the same way:
+  var algorithm Driver.Algorithm = Driver.Lookup("Lynch")
+  var config *Driver.Config = Driver.NewConfig()
+  config.NbNodes = 8
+  if err := Driver.Setup(algorithm, config, workloadFlags, Topology.COMPLETE); err != nil {
+  	log.Fatal(err)
+  }
+  Driver.Run(algorithm, config)
+
+A run
+1. Setup checks the flags against the Info of the algorithm, e.g. a request
+   cannot be larger than the number of resources, and builds the workload and
+   the conflict graph
+2. Init creates the nodes, and wraps their inboxes with WrapNetwork when
+   faults are injected
+3. StartNode runs each node until the CS entries are done
+4. Stop ends the routines of the nodes, then Stats returns the statistics,
+   logged in the format of the Stats package
+An algorithm crashing a node during the run implements Crasher, one that
+detects an inconsistent state implements Failer.
+
+The algorithms are registered in algorithms.go.
+*/
+
+package Driver
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"drinking/Coordinator"
+	"drinking/Dining"
+	"drinking/Network"
+	"drinking/Stats"
+	"drinking/Topology"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+// Info describes an algorithm and the flags it supports
+type Info struct {
+	Name            string
+	Description     string
+	Workload        bool // the workload flags set the requests or the durations of the nodes
+	Resources       bool // there can be more resources than nodes, otherwise there are as many
+	Topology        bool // the nodes are the philosophers of a conflict graph
+	FailureDetector bool
+	Dining          bool // the dining philosophers subroutine can be chosen
+	Queue           bool // the queue policy of the coordinator can be chosen
+	Trace           bool
+}
+
+// Algorithm is implemented by each algorithm run by the driver
+type Algorithm interface {
+	Info() Info
+	// Init creates the nodes of the run
+	Init(config *Config) error
+	// StartNode runs node id until the CS entries are done, then calls wg.Done
+	StartNode(id int, wg *sync.WaitGroup)
+	// Stop ends the routines of the nodes once the run is over
+	Stop()
+	// Stats returns the statistics of the run, once stopped
+	Stats() Result
+}
+
+// Crasher is implemented by the algorithms that can crash a node during the run
+type Crasher interface {
+	// Crash crashes node config.Crash, it returns when the nodes can be stopped
+	Crash(config *Config, wg *sync.WaitGroup)
+}
+
+// Failer is implemented by the algorithms that detect an inconsistent state
+type Failer interface {
+	// Failure receives the first error of the nodes, after which the run is stopped
+	Failure() <-chan error
+}
+
+// Result are the statistics of a run
+type Result struct {
+	NbCS    []int // CS entries of each node
+	NbMsg   int
+	Details string // specific to the algorithm, e.g. its number of colors
+}
+
+// Config of a run, set from the command line
+type Config struct {
+	NbNodes         int
+	NbResources     int // the number of nodes if 0
+	NbIterations    int
+	Seed            int64
+	Workload        *Workload.Config // built by Setup
+	Graph           *Topology.Graph  // built by Setup
+	FailureDetector string // heartbeat or phi, none when empty
+	Dining          string // dining philosophers subroutine, the default one of the algorithm when empty
+	Queue           string // queue policy of the coordinator, fifo when empty
+	Crash           int // node crashed during the run, -1 for none
+	CrashAfter      int // CS entries before the crash
+	CrashObserve    time.Duration // duration of the run after the crash
+	Faults          Network.Faults
+	NetScript       string
+	NetEnabled      bool
+	Timeout         time.Duration // the run is stopped after Timeout, no limit if 0
+	Tracer          *Trace.Tracer
+	Recorder        *Stats.Recorder // created by Run
+	network         networkControl
+}
+
+// networkControl is the part of a Network used by the driver, whatever the type of the messages
+type networkControl interface {
+	Start()
+	Stop()
+	Stats() string
+}
+
+func NewConfig() *Config {
+	var c Config
+	c.NbNodes = 4
+	c.NbIterations = 10
+	c.Crash = -1
+	c.CrashAfter = 3
+	c.CrashObserve = 5 * time.Second
+	return &c
+}
+
+////////////////////////////////////////////////////////////
+// Registry
+////////////////////////////////////////////////////////////
+
+var registry = make(map[string]Algorithm)
+
+// Register makes algorithm available under its name
+func Register(algorithm Algorithm) {
+	var name string = strings.ToLower(algorithm.Info().Name)
+	if _, ok := registry[name]; ok {
+		log.Fatal("algorithm ", algorithm.Info().Name, " registered twice")
+	}
+	registry[name] = algorithm
+}
+
+// Lookup returns the algorithm registered under name, whatever its case, nil if none
+func Lookup(name string) Algorithm {
+	return registry[strings.ToLower(name)]
+}
+
+// Names returns the names of the registered algorithms, sorted
+func Names() []string {
+	var names []string
+	for _, algorithm := range registry {
+		names = append(names, algorithm.Info().Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List returns one line per registered algorithm with its description and the flags it supports
+func List() string {
+	var lines []string
+	for _, name := range Names() {
+		var info Info = Lookup(name).Info()
+		var supports []string
+		if info.Workload {
+			supports = append(supports, "workload")
+		}
+		if info.Resources {
+			supports = append(supports, "-resources")
+		}
+		if info.Topology {
+			supports = append(supports, "-topology")
+		}
+		if _, ok := Lookup(name).(Crasher); ok {
+			supports = append(supports, "-crash")
+		}
+		if info.FailureDetector {
+			supports = append(supports, "-fd")
+		}
+		if info.Dining {
+			supports = append(supports, "-dining")
+		}
+		if info.Queue {
+			supports = append(supports, "-queue")
+		}
+		if info.Trace {
+			supports = append(supports, "-trace")
+		}
+		lines = append(lines, fmt.Sprintf("%-20s %s (%s)", info.Name, info.Description, strings.Join(supports, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+////////////////////////////////////////////////////////////
+// Run
+////////////////////////////////////////////////////////////
+
+// Setup checks config against the flags supported by algorithm, then builds
+// the workload and the conflict graph of the run
+func Setup(algorithm Algorithm, config *Config, workloadFlags *Workload.Flags, topology string) error {
+	var info Info = algorithm.Info()
+	if config.NbNodes < 1 {
+		return fmt.Errorf("-nodes must be at least 1, got %d", config.NbNodes)
+	}
+	if config.NbIterations < 1 {
+		return fmt.Errorf("-nbIterations must be at least 1, got %d", config.NbIterations)
+	}
+	if config.NbResources == 0 {
+		config.NbResources = config.NbNodes
+	} else if config.NbResources < 0 {
+		return fmt.Errorf("-resources must be positive, got %d", config.NbResources)
+	} else if config.NbResources != config.NbNodes && info.Resources == false {
+		return fmt.Errorf("%s has one resource per node, -resources must be %d", info.Name, config.NbNodes)
+	}
+	if topology != "" && !strings.EqualFold(topology, Topology.COMPLETE) && info.Topology == false {
+		return fmt.Errorf("%s does not run on a conflict graph, -topology must be %s", info.Name, Topology.COMPLETE)
+	}
+	if config.Crash >= 0 {
+		if _, ok := algorithm.(Crasher); ok == false {
+			return fmt.Errorf("%s cannot crash a node, -crash is not supported", info.Name)
+		}
+		if config.Crash >= config.NbNodes {
+			return fmt.Errorf("-crash must be lower than -nodes %d, got %d", config.NbNodes, config.Crash)
+		}
+	}
+	if config.FailureDetector != "" {
+		if info.FailureDetector == false {
+			return fmt.Errorf("%s has no failure detector, -fd is not supported", info.Name)
+		}
+		if !strings.EqualFold(config.FailureDetector, "heartbeat") && !strings.EqualFold(config.FailureDetector, "phi") {
+			return fmt.Errorf("unknown failure detector %q, must be heartbeat or phi", config.FailureDetector)
+		}
+	}
+	if config.Dining != "" {
+		if info.Dining == false {
+			return fmt.Errorf("%s has no dining philosophers subroutine, -dining is not supported", info.Name)
+		}
+		if _, err := Dining.Parse(config.Dining); err != nil {
+			return err
+		}
+	}
+	if config.Queue != "" {
+		if info.Queue == false {
+			return fmt.Errorf("%s has no coordinator, -queue is not supported", info.Name)
+		}
+		if _, err := Coordinator.ParsePolicy(config.Queue); err != nil {
+			return err
+		}
+	}
+	if config.Tracer != nil && info.Trace == false {
+		return fmt.Errorf("%s does not write execution traces, -trace is not supported", info.Name)
+	}
+
+	var err error
+	if info.Workload {
+		config.Workload, err = workloadFlags.Config(config.NbNodes, config.NbResources)
+		if err != nil {
+			return fmt.Errorf("%s with %d nodes and %d resources: %v", info.Name, config.NbNodes, config.NbResources, err)
+		}
+	}
+	if info.Topology {
+		if topology == "" {
+			topology = Topology.COMPLETE
+		}
+		config.Graph, err = Topology.Parse(topology, config.NbNodes, config.Seed)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WrapNetwork returns the fault injection layer for the inboxes of the nodes,
+// or nil if it is not enabled. Called by Init, the driver starts and stops it
+func WrapNetwork[T any](config *Config, inboxes []chan T) (*Network.Network[T], error) {
+	if config.NetEnabled == false {
+		return nil, nil
+	}
+	var network = Network.New(inboxes, config.Faults)
+	if config.NetScript != "" {
+		file, err := os.Open(config.NetScript)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		schedule, err := Network.ParseSchedule(file, config.Faults)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", config.NetScript, err)
+		}
+		network.SetSchedule(schedule)
+	}
+	log.Print("Network, faults: ", config.Faults.String())
+	config.network = network
+	return network, nil
+}
+
+// deadline receives when the run did not end after config.Timeout, which
+// happens when an algorithm does not tolerate the faults of the network. It
+// never receives if there is no timeout
+func deadline(config *Config) <-chan time.Time {
+	if config.Timeout <= 0 {
+		return nil
+	}
+	return time.After(config.Timeout)
+}
+
+// finished is closed when the nodes are done
+func finished(wg *sync.WaitGroup) <-chan bool {
+	var done = make(chan bool)
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// wait returns when the nodes are done, or with the first error of algorithm,
+// or with an error when timeout receives
+func wait(algorithm Algorithm, config *Config, wg *sync.WaitGroup, timeout <-chan time.Time) error {
+	var failure <-chan error
+	if failer, ok := algorithm.(Failer); ok {
+		failure = failer.Failure()
+	}
+	select {
+	case <-finished(wg):
+		return nil
+	case err := <-failure:
+		return err
+	case <-timeout:
+		return fmt.Errorf("run did not end after %v", config.Timeout)
+	}
+}
+
+// Run runs algorithm once Setup, then logs its statistics
+func Run(algorithm Algorithm, config *Config) error {
+	var info Info = algorithm.Info()
+	log.Print(info.Name, " with ", config.NbNodes, " nodes, seed ", config.Seed)
+	config.Recorder = Stats.New()
+	config.network = nil
+	if err := algorithm.Init(config); err != nil {
+		return err
+	}
+	if config.network != nil {
+		config.network.Start()
+	}
+	var timeout <-chan time.Time = deadline(config)
+
+	var wg sync.WaitGroup
+	for i := 0; i < config.NbNodes; i++ {
+		wg.Add(1)
+		go algorithm.StartNode(i, &wg)
+	}
+	var err error
+	if crasher, ok := algorithm.(Crasher); ok && config.Crash >= 0 {
+		var crashed = make(chan bool)
+		go func() {
+			crasher.Crash(config, &wg)
+			close(crashed)
+		}()
+		select {
+		case <-crashed:
+			algorithm.Stop()
+			err = wait(algorithm, config, &wg, timeout)
+		case <-timeout:
+			algorithm.Stop()
+			err = fmt.Errorf("run did not end after %v", config.Timeout)
+		}
+	} else {
+		err = wait(algorithm, config, &wg, timeout)
+		algorithm.Stop()
+	}
+
+	var result Result = algorithm.Stats()
+	for i := 0; i < len(result.NbCS); i++ {
+		log.Print("Node #", i, " entered CS ", result.NbCS[i], " time")
+	}
+	if result.Details != "" {
+		log.Print(result.NbMsg, " messages sent, ", result.Details)
+	} else {
+		log.Print(result.NbMsg, " messages sent")
+	}
+	log.Print(config.Recorder.Summary(result.NbMsg))
+	if config.network != nil {
+		config.network.Stop()
+		log.Print(config.network.Stats())
+	}
+	if err != nil {
+		return fmt.Errorf("%s run failed: %v", info.Name, err)
+	}
+	return nil
+}
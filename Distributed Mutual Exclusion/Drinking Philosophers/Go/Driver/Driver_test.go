@@ -0,0 +1,192 @@
+package Driver
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"drinking/internal/testutil"
+	"drinking/Topology"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+// workloadFlags returns the workload flags parsed from args
+func workloadFlags(t *testing.T, args ...string) *Workload.Flags {
+	var fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	var flags *Workload.Flags = Workload.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestRegistry(t *testing.T) {
+	var names []string = Names()
+	for _, name := range []string{"AwerbuchSaks", "BouabdallahLaforest", "ChandyMisra", "ChandyMisraDrinking", "ChoySingh", "Dijkstra", "Lynch", "Rhee"} {
+		if Lookup(strings.ToLower(name)) == nil {
+			t.Error(name, " not registered: ", names)
+		}
+	}
+	if Lookup("Foo") != nil {
+		t.Error("Foo registered")
+	}
+	if len(strings.Split(List(), "\n")) != len(names) {
+		t.Error("List does not have one line per algorithm:\n", List())
+	}
+}
+
+func TestSetup(t *testing.T) {
+	var tracer *Trace.Tracer = &Trace.Tracer{}
+	var tests = []struct {
+		algo     string
+		args     []string
+		topology string
+		set      func(c *Config)
+		err      string // expected in the error, no error if empty
+	}{
+		{"Rhee", nil, "", func(c *Config) {}, ""},
+		{"Rhee", []string{"-requestSize=5"}, "", func(c *Config) {}, "size of requests"},
+		{"Rhee", nil, "", func(c *Config) { c.NbResources = 8 }, "one resource per node"},
+		{"Dijkstra", []string{"-requestSize=8"}, "", func(c *Config) { c.NbResources = 8 }, ""},
+		{"Dijkstra", []string{"-requestSize=9"}, "", func(c *Config) { c.NbResources = 8 }, "size of requests"},
+		{"Lynch", nil, "", func(c *Config) { c.NbNodes = 0 }, "-nodes"},
+		{"Lynch", nil, "", func(c *Config) { c.NbIterations = 0 }, "-nbIterations"},
+		{"Lynch", nil, "ring", func(c *Config) {}, "-topology"},
+		{"Lynch", nil, "", func(c *Config) { c.Crash = 1 }, "-crash"},
+		{"ChoySingh", nil, "ring", func(c *Config) { c.Crash = 1 }, ""},
+		{"ChoySingh", nil, "", func(c *Config) { c.Crash = 4 }, "lower than -nodes"},
+		{"ChandyMisra", nil, "", func(c *Config) { c.FailureDetector = "phi" }, ""},
+		{"ChandyMisra", nil, "", func(c *Config) { c.FailureDetector = "foo" }, "unknown failure detector"},
+		{"Rhee", nil, "", func(c *Config) { c.FailureDetector = "phi" }, "-fd"},
+		{"Rhee", nil, "", func(c *Config) { c.Dining = "Arbiter" }, ""},
+		{"Rhee", nil, "", func(c *Config) { c.Dining = "foo" }, "unknown dining philosophers subroutine"},
+		{"Lynch", nil, "", func(c *Config) { c.Dining = "ordered" }, "-dining"},
+		{"Coordinator", nil, "", func(c *Config) { c.Queue = "Smallest" }, ""},
+		{"Coordinator", nil, "", func(c *Config) { c.Queue = "lifo" }, "unknown queue policy"},
+		{"Rhee", nil, "", func(c *Config) { c.Queue = "fifo" }, "-queue"},
+		{"Lynch", nil, "", func(c *Config) { c.Tracer = tracer }, ""},
+		{"AwerbuchSaks", nil, "", func(c *Config) { c.Tracer = tracer }, "-trace"},
+	}
+	for _, test := range tests {
+		var config *Config = NewConfig()
+		test.set(config)
+		var err error = Setup(Lookup(test.algo), config, workloadFlags(t, test.args...), test.topology)
+		if test.err == "" && err != nil {
+			t.Error(test.algo, " ", test.args, ": ", err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Error(test.algo, " ", test.args, ": error ", err, " instead of ", test.err)
+		}
+	}
+}
+
+// Every registered algorithm runs through the driver: the nodes enter their
+// CS nbIterations times in total, and never with a resource used by another
+// node in the trace of the run. Some runs are over a network delaying the
+// messages
+func TestRun(t *testing.T) {
+	var delayed = func(c *Config) {
+		c.NetEnabled = true
+		c.Faults.Seed = 1
+		c.Faults.MaxDelay = 5 * time.Millisecond
+	}
+	var tests = []struct {
+		algo     string
+		args     []string
+		topology string
+		set      func(c *Config)
+	}{
+		{"AwerbuchSaks", []string{"-requestSize=3"}, "", func(c *Config) {}},
+		{"AwerbuchSaks", []string{"-requestSize=4"}, "", func(c *Config) { c.NbResources = 12 }},
+		{"BouabdallahLaforest", []string{"-requestSize=3"}, "", func(c *Config) {}},
+		{"BouabdallahLaforest", []string{"-requestSize=2"}, "", delayed},
+		// the CS of ChandyMisra last 500ms
+		{"ChandyMisra", nil, Topology.RING, func(c *Config) { c.NbIterations = 8 }},
+		{"ChandyMisra", nil, Topology.COMPLETE, func(c *Config) { c.NbIterations = 8 }},
+		{"ChandyMisraDrinking", []string{"-requestSize=3"}, "", func(c *Config) { c.NbNodes = 2; c.NbResources = 4 }},
+		{"ChandyMisraDrinking", []string{"-requestSize=3"}, "", func(c *Config) { c.NbNodes = 7; c.NbResources = 14 }},
+		{"ChandyMisraDrinking", []string{"-requestSize=2"}, "", delayed},
+		{"ChoySingh", nil, Topology.RING, func(c *Config) { c.NbNodes = 6 }},
+		{"ChoySingh", nil, Topology.GRID, func(c *Config) { c.NbNodes = 6 }},
+		{"ChoySingh", nil, Topology.COMPLETE, func(c *Config) { c.NbNodes = 6 }},
+		{"ChoySingh", nil, Topology.RING, delayed},
+		{"Coordinator", []string{"-requestSize=1"}, "", func(c *Config) { c.NbResources = 1 }},
+		{"Coordinator", []string{"-requestSize=4"}, "", func(c *Config) { c.NbResources = 12 }},
+		{"Coordinator", []string{"-requestSize=4"}, "", func(c *Config) { c.NbResources = 12; c.Queue = "smallest" }},
+		{"Coordinator", []string{"-requestSize=2"}, "", delayed},
+		{"Dijkstra", []string{"-requestSize=4"}, "", func(c *Config) { c.NbResources = 12 }},
+		{"Dijkstra", []string{"-requestSize=2"}, "", delayed},
+		{"Lynch", []string{"-requestSize=3"}, "", func(c *Config) { c.NbResources = 8 }},
+		{"Lynch", []string{"-requestSize=2"}, "", delayed},
+		{"Rhee", []string{"-requestSize=3"}, "", func(c *Config) {}},
+		{"Rhee", []string{"-requestSize=3"}, "", func(c *Config) { c.Dining = "arbiter" }},
+		{"Rhee", []string{"-requestSize=3"}, "", func(c *Config) { c.Dining = "ordered" }},
+		{"Rhee", []string{"-requestSize=2"}, "", delayed},
+	}
+	var tested = make(map[string]bool)
+	for _, test := range tests {
+		tested[test.algo] = true
+		var algorithm Algorithm = Lookup(test.algo)
+		for seed := int64(1); seed <= 2; seed++ {
+			var config *Config = NewConfig()
+			config.NbNodes = 5
+			config.NbIterations = 20
+			config.Seed = seed
+			config.Timeout = time.Minute
+			test.set(config)
+			var flags *Workload.Flags = workloadFlags(t, append([]string{"-think=0-20ms", "-cs=0-20ms"}, test.args...)...)
+			if err := Setup(algorithm, config, flags, test.topology); err != nil {
+				t.Fatal(test.algo, " ", test.args, ": ", err)
+			}
+			// set after Setup, which rejects -trace when the messages are not traced
+			var tracer *testutil.Tracer = testutil.NewTracer()
+			config.Tracer = tracer.Tracer
+			var name string = fmt.Sprint(test.algo, " ", test.args, " ", test.topology, ", seed ", seed)
+			if err := Run(algorithm, config); err != nil {
+				t.Fatal(name, ": ", err)
+			}
+			if algorithm.Info().Topology {
+				testutil.CheckExclusion(t, tracer, testutil.Edges(config.Graph))
+			} else {
+				testutil.CheckExclusion(t, tracer, testutil.Requests(config.Workload, config.Seed))
+			}
+			var result Result = algorithm.Stats()
+			var nbCS int = 0
+			for _, n := range result.NbCS {
+				nbCS += n
+			}
+			if nbCS < config.NbIterations || result.NbMsg == 0 {
+				t.Error(name, ": ", nbCS, " CS entries and ", result.NbMsg, " messages")
+			}
+		}
+	}
+	for _, name := range Names() {
+		if !tested[name] {
+			t.Error(name, " is not tested")
+		}
+	}
+}
+
+// A bad fault script, or a run that does not end in time, is an error of Run
+func TestRunErrors(t *testing.T) {
+	var algorithm Algorithm = Lookup("BouabdallahLaforest")
+	var config *Config = NewConfig()
+	config.Seed = 1
+	config.NetEnabled = true
+	config.NetScript = "/nonexistent/faults.txt"
+	if err := Setup(algorithm, config, workloadFlags(t), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(algorithm, config); err == nil || !strings.Contains(err.Error(), "faults.txt") {
+		t.Error("bad script: ", err)
+	}
+	// all the messages are dropped, the nodes wait forever
+	config.NetScript = ""
+	config.Faults.DropRate = 1
+	config.Timeout = 200 * time.Millisecond
+	if err := Run(algorithm, config); err == nil || !strings.Contains(err.Error(), "did not end") {
+		t.Error("timeout: ", err)
+	}
+}
@@ -0,0 +1,514 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Adapters of the algorithms to the Algorithm interface of the driver, each one
+is registered in init.
+*/
+
+package Driver
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"drinking/AwerbuchSaks"
+	"drinking/BouabdallahLaforest"
+	"drinking/ChandyMisra"
+	"drinking/ChandyMisraDrinking"
+	"drinking/ChoySingh"
+	"drinking/Coordinator"
+	"drinking/Dijkstra"
+	"drinking/Dining"
+	"drinking/FailureDetector"
+	"drinking/Lynch"
+	"drinking/Network"
+	"drinking/Rhee"
+)
+
+func init() {
+	Register(rhee{})
+	Register(chandyMisra{})
+	Register(chandyMisraDrinking{})
+	Register(lynch{})
+	Register(choySingh{})
+	Register(&bouabdallahLaforest{})
+	Register(dijkstra{})
+	Register(awerbuchSaks{})
+	Register(coordinator{})
+}
+
+////////////////////////////////////////////////////////////
+// Rhee
+////////////////////////////////////////////////////////////
+
+type rhee struct{}
+
+func (rhee) Info() Info {
+	return Info{Name: "Rhee", Description: "Rhee's drinking philosophers with resource managers", Workload: true, Dining: true, Trace: true}
+}
+
+func (rhee) Init(config *Config) error {
+	Rhee.Subroutine = Dining.NewChandyMisra
+	if config.Dining != "" {
+		var err error
+		Rhee.Subroutine, err = Dining.Parse(config.Dining)
+		if err != nil {
+			return err
+		}
+	}
+	Rhee.Init(config.NbNodes, config.NbIterations, config.Workload, config.Seed)
+	Rhee.Tracer = config.Tracer
+	Rhee.Recorder = config.Recorder
+	network, err := WrapNetwork(config, Rhee.Nodes[0].Messages)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		for i := 0; i < config.NbNodes; i++ {
+			Rhee.Nodes[i].Messages = network.Links(i)
+		}
+	}
+	return nil
+}
+
+func (rhee) StartNode(id int, wg *sync.WaitGroup) {
+	Rhee.Nodes[id].Rhee(wg)
+}
+
+func (rhee) Stop() {
+	Rhee.Stop()
+}
+
+func (rhee) Stats() Result {
+	var result Result
+	for i := 0; i < len(Rhee.Nodes); i++ {
+		result.NbCS = append(result.NbCS, Rhee.Nodes[i].NbRheeCS)
+	}
+	result.NbMsg = Rhee.NB_MSG
+	return result
+}
+
+////////////////////////////////////////////////////////////
+// Chandy-Misra dining philosophers
+////////////////////////////////////////////////////////////
+
+type chandyMisra struct{}
+
+func (chandyMisra) Info() Info {
+	return Info{Name: "ChandyMisra", Description: "Chandy-Misra dining philosophers, fault-tolerant with -fd", Topology: true, FailureDetector: true, Trace: true}
+}
+
+func failureDetectorStrategy(name string) func() FailureDetector.Strategy {
+	if strings.EqualFold(name, "heartbeat") == true {
+		return FailureDetector.NewHeartbeat(5 * FailureDetector.HEARTBEAT_INTERVAL)
+	} else if strings.EqualFold(name, "phi") == true {
+		return FailureDetector.NewPhiAccrual(8.0)
+	}
+	return nil
+}
+
+func (chandyMisra) Init(config *Config) error {
+	ChandyMisra.Init(config.Graph, config.NbIterations)
+	ChandyMisra.Tracer = config.Tracer
+	ChandyMisra.Recorder = config.Recorder
+	if config.FailureDetector != "" {
+		ChandyMisra.EnableFailureDetector(failureDetectorStrategy(config.FailureDetector))
+	}
+	network, err := WrapNetwork(config, ChandyMisra.Philosophers[0].Messages)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		// the heartbeats share the faults of the messages, a partition makes
+		// the other side suspected
+		var heartbeats *Network.Network[int]
+		if config.FailureDetector != "" {
+			heartbeats = Network.Attach(network, ChandyMisra.Philosophers[0].Detector.Heartbeats)
+		}
+		for i := 0; i < config.NbNodes; i++ {
+			ChandyMisra.Philosophers[i].Messages = network.Links(i)
+			if heartbeats != nil {
+				ChandyMisra.Philosophers[i].Detector.Heartbeats = heartbeats.Links(i)
+			}
+		}
+	}
+	return nil
+}
+
+func (chandyMisra) StartNode(id int, wg *sync.WaitGroup) {
+	ChandyMisra.Philosophers[id].ChandyMisra(wg)
+}
+
+// Crash crashes the philosopher after config.CrashAfter CS entries in total,
+// the others go on until their CS entries are done
+func (chandyMisra) Crash(config *Config, wg *sync.WaitGroup) {
+	var done <-chan bool = finished(wg)
+	for ChandyMisra.CurrentIteration() < config.CrashAfter {
+		select {
+		case <-done:
+			log.Print("run finished before the crash of philosopher #", config.Crash)
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	ChandyMisra.Philosophers[config.Crash].Crash()
+	<-done
+}
+
+func (chandyMisra) Stop() {
+	ChandyMisra.Stop()
+}
+
+func (chandyMisra) Stats() Result {
+	var result Result
+	for i := 0; i < len(ChandyMisra.Philosophers); i++ {
+		result.NbCS = append(result.NbCS, ChandyMisra.Philosophers[i].NbCS)
+	}
+	result.NbMsg = ChandyMisra.NB_MSG
+	return result
+}
+
+////////////////////////////////////////////////////////////
+// Chandy-Misra drinking philosophers
+////////////////////////////////////////////////////////////
+
+type chandyMisraDrinking struct{}
+
+func (chandyMisraDrinking) Info() Info {
+	return Info{Name: "ChandyMisraDrinking", Description: "Chandy-Misra drinking philosophers, the dining philosophers solve the conflicts", Workload: true, Resources: true, Trace: true}
+}
+
+func (chandyMisraDrinking) Init(config *Config) error {
+	ChandyMisraDrinking.Init(config.NbNodes, config.NbIterations, config.Workload, config.Seed)
+	ChandyMisraDrinking.Tracer = config.Tracer
+	ChandyMisraDrinking.Recorder = config.Recorder
+	network, err := WrapNetwork(config, ChandyMisraDrinking.Nodes[0].Messages)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		for i := 0; i < config.NbNodes; i++ {
+			ChandyMisraDrinking.Nodes[i].Messages = network.Links(i)
+		}
+	}
+	return nil
+}
+
+func (chandyMisraDrinking) StartNode(id int, wg *sync.WaitGroup) {
+	ChandyMisraDrinking.Nodes[id].ChandyMisraDrinking(wg)
+}
+
+func (chandyMisraDrinking) Stop() {
+	ChandyMisraDrinking.Stop()
+}
+
+func (chandyMisraDrinking) Stats() Result {
+	var result Result
+	for i := 0; i < len(ChandyMisraDrinking.Nodes); i++ {
+		result.NbCS = append(result.NbCS, ChandyMisraDrinking.Nodes[i].NbCS)
+	}
+	result.NbMsg = ChandyMisraDrinking.NB_MSG
+	return result
+}
+
+////////////////////////////////////////////////////////////
+// Lynch
+////////////////////////////////////////////////////////////
+
+type lynch struct{}
+
+func (lynch) Info() Info {
+	return Info{Name: "Lynch", Description: "Lynch's coloring algorithm, the resources are acquired by increasing color", Workload: true, Resources: true, Trace: true}
+}
+
+func (lynch) Init(config *Config) error {
+	Lynch.Init(config.NbNodes, config.NbIterations, config.Workload, config.Seed)
+	Lynch.Tracer = config.Tracer
+	Lynch.Recorder = config.Recorder
+	network, err := WrapNetwork(config, Lynch.Nodes[0].Messages)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		for i := 0; i < config.NbNodes; i++ {
+			Lynch.Nodes[i].Messages = network.Links(i)
+		}
+	}
+	return nil
+}
+
+func (lynch) StartNode(id int, wg *sync.WaitGroup) {
+	Lynch.Nodes[id].Lynch(wg)
+}
+
+func (lynch) Stop() {
+	Lynch.Stop()
+}
+
+func (lynch) Stats() Result {
+	var result Result
+	for i := 0; i < len(Lynch.Nodes); i++ {
+		result.NbCS = append(result.NbCS, Lynch.Nodes[i].NbCS)
+	}
+	result.NbMsg = Lynch.NB_MSG
+	result.Details = fmt.Sprint(Lynch.NbColors, " colors")
+	return result
+}
+
+////////////////////////////////////////////////////////////
+// Choy-Singh
+////////////////////////////////////////////////////////////
+
+type choySingh struct{}
+
+func (choySingh) Info() Info {
+	return Info{Name: "ChoySingh", Description: "Choy-Singh double doorway, a crash in the CS only blocks the nearby philosophers", Workload: true, Topology: true, Trace: true}
+}
+
+func (choySingh) Init(config *Config) error {
+	ChoySingh.Init(config.Graph, config.NbIterations, config.Workload, config.Seed)
+	ChoySingh.Tracer = config.Tracer
+	ChoySingh.Recorder = config.Recorder
+	if config.Crash >= 0 {
+		ChoySingh.CrashInCS(config.Crash, config.CrashAfter)
+	}
+	network, err := WrapNetwork(config, ChoySingh.Nodes[0].Messages)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		for i := 0; i < config.NbNodes; i++ {
+			ChoySingh.Nodes[i].Messages = network.Links(i)
+		}
+	}
+	return nil
+}
+
+func (choySingh) StartNode(id int, wg *sync.WaitGroup) {
+	ChoySingh.Nodes[id].ChoySingh(wg)
+}
+
+// Crash observes the blocked philosophers for config.CrashObserve after the
+// crash of the philosopher in its CS
+func (choySingh) Crash(config *Config, wg *sync.WaitGroup) {
+	select {
+	case <-finished(wg):
+		log.Print("run finished before the crash of philosopher #", config.Crash)
+		return
+	case <-ChoySingh.Crashed():
+	}
+	time.Sleep(config.CrashObserve)
+	blocked, radius := ChoySingh.Blocked(config.Crash, config.CrashObserve / 2)
+	log.Print("Philosopher #", config.Crash, " crashed, ", len(blocked), " philosophers blocked, blocked radius ", radius)
+}
+
+func (choySingh) Stop() {
+	ChoySingh.Stop()
+}
+
+func (choySingh) Stats() Result {
+	var result Result
+	for i := 0; i < len(ChoySingh.Nodes); i++ {
+		result.NbCS = append(result.NbCS, ChoySingh.Nodes[i].NbCS)
+	}
+	result.NbMsg = ChoySingh.NB_MSG
+	result.Details = fmt.Sprint(ChoySingh.NbColors, " colors")
+	return result
+}
+
+////////////////////////////////////////////////////////////
+// Bouabdallah-Laforest
+////////////////////////////////////////////////////////////
+
+// bouabdallahLaforest keeps the system of the run
+type bouabdallahLaforest struct {
+	system *BouabdallahLaforest.System
+}
+
+func (*bouabdallahLaforest) Info() Info {
+	return Info{Name: "BouabdallahLaforest", Description: "Bouabdallah-Laforest, one token per resource and a Control Token", Workload: true, Trace: true}
+}
+
+func (b *bouabdallahLaforest) Init(config *Config) error {
+	system, err := BouabdallahLaforest.New(config.NbNodes, config.NbIterations, config.Workload, config.Seed)
+	if err != nil {
+		return err
+	}
+	system.Tracer = config.Tracer
+	system.Recorder = config.Recorder
+	network, err := WrapNetwork(config, system.Nodes[0].Messages)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		for i := 0; i < config.NbNodes; i++ {
+			system.Nodes[i].Messages = network.Links(i)
+		}
+	}
+	system.Start()
+	b.system = system
+	return nil
+}
+
+func (b *bouabdallahLaforest) StartNode(id int, wg *sync.WaitGroup) {
+	b.system.Nodes[id].BouabdallahLaforest(wg)
+}
+
+func (b *bouabdallahLaforest) Failure() <-chan error {
+	return b.system.Failure()
+}
+
+func (b *bouabdallahLaforest) Stop() {
+	b.system.Stop()
+}
+
+func (b *bouabdallahLaforest) Stats() Result {
+	var result Result
+	for i := 0; i < len(b.system.Nodes); i++ {
+		result.NbCS = append(result.NbCS, b.system.Nodes[i].NbCS)
+	}
+	result.NbMsg = b.system.NbMsg()
+	return result
+}
+
+////////////////////////////////////////////////////////////
+// Dijkstra
+////////////////////////////////////////////////////////////
+
+type dijkstra struct{}
+
+func (dijkstra) Info() Info {
+	return Info{Name: "Dijkstra", Description: "Dijkstra's resource managers, the resources are acquired in decreasing order", Workload: true, Resources: true}
+}
+
+func (dijkstra) Init(config *Config) error {
+	Dijkstra.Init(config.NbNodes, config.NbResources, config.NbIterations, config.Workload, config.Seed)
+	Dijkstra.Recorder = config.Recorder
+	// the CS are traced for the tests, not the messages: Setup rejects -trace
+	Dijkstra.Tracer = config.Tracer
+	network, err := WrapNetwork(config, Dijkstra.Nodes[0].Messages)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		for i := 0; i < config.NbNodes; i++ {
+			Dijkstra.Nodes[i].Messages = network.Links(i)
+		}
+	}
+	return nil
+}
+
+func (dijkstra) StartNode(id int, wg *sync.WaitGroup) {
+	Dijkstra.Nodes[id].Dijkstra(wg)
+}
+
+func (dijkstra) Stop() {
+	Dijkstra.Stop()
+}
+
+func (dijkstra) Stats() Result {
+	var result Result
+	for i := 0; i < len(Dijkstra.Nodes); i++ {
+		result.NbCS = append(result.NbCS, Dijkstra.Nodes[i].NbCS)
+	}
+	result.NbMsg = Dijkstra.NB_MSG
+	return result
+}
+
+////////////////////////////////////////////////////////////
+// Awerbuch-Saks
+////////////////////////////////////////////////////////////
+
+type awerbuchSaks struct{}
+
+func (awerbuchSaks) Info() Info {
+	return Info{Name: "AwerbuchSaks", Description: "Awerbuch-Saks dynamic job scheduling, the response time is bounded", Workload: true, Resources: true}
+}
+
+func (awerbuchSaks) Init(config *Config) error {
+	AwerbuchSaks.Init(config.NbNodes, config.NbIterations, config.Workload, config.Seed)
+	AwerbuchSaks.Recorder = config.Recorder
+	AwerbuchSaks.Tracer = config.Tracer
+	network, err := WrapNetwork(config, AwerbuchSaks.Jobs[0].Messages)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		for i := 0; i < config.NbNodes; i++ {
+			AwerbuchSaks.Jobs[i].Messages = network.Links(i)
+		}
+	}
+	return nil
+}
+
+func (awerbuchSaks) StartNode(id int, wg *sync.WaitGroup) {
+	AwerbuchSaks.Jobs[id].AwerbuchSaks(wg)
+}
+
+func (awerbuchSaks) Stop() {
+	AwerbuchSaks.Stop()
+}
+
+func (awerbuchSaks) Stats() Result {
+	var result Result
+	for i := 0; i < len(AwerbuchSaks.Jobs); i++ {
+		result.NbCS = append(result.NbCS, AwerbuchSaks.Jobs[i].NbCS)
+	}
+	result.NbMsg = AwerbuchSaks.NB_MSG
+	result.Details = AwerbuchSaks.BoundSummary()
+	return result
+}
+
+////////////////////////////////////////////////////////////
+// Coordinator
+////////////////////////////////////////////////////////////
+
+type coordinator struct{}
+
+func (coordinator) Info() Info {
+	return Info{Name: "Coordinator", Description: "centralized coordinator granting the resources, the baseline of the distributed algorithms", Workload: true, Resources: true, Queue: true}
+}
+
+func (coordinator) Init(config *Config) error {
+	Coordinator.Policy = Coordinator.FIFO
+	if config.Queue != "" {
+		var err error
+		Coordinator.Policy, err = Coordinator.ParsePolicy(config.Queue)
+		if err != nil {
+			return err
+		}
+	}
+	Coordinator.Init(config.NbNodes, config.NbResources, config.NbIterations, config.Workload, config.Seed)
+	Coordinator.Recorder = config.Recorder
+	Coordinator.Tracer = config.Tracer
+	network, err := WrapNetwork(config, Coordinator.Nodes[0].Messages)
+	if err != nil {
+		return err
+	}
+	if network != nil {
+		for i := 0; i < config.NbNodes; i++ {
+			Coordinator.Nodes[i].Messages = network.Links(i)
+		}
+	}
+	return nil
+}
+
+func (coordinator) StartNode(id int, wg *sync.WaitGroup) {
+	Coordinator.Nodes[id].Coordinator(wg)
+}
+
+func (coordinator) Stop() {
+	Coordinator.Stop()
+}
+
+func (coordinator) Stats() Result {
+	var result Result
+	for i := 0; i < len(Coordinator.Nodes); i++ {
+		result.NbCS = append(result.NbCS, Coordinator.Nodes[i].NbCS)
+	}
+	result.NbMsg = Coordinator.NB_MSG
+	return result
+}
@@ -0,0 +1,278 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Failure detector shared by the algorithms, it runs next to the receive loop of a node.
+
+Usage:
+  var heartbeats = FailureDetector.NewHeartbeatChannels(nbNodes)
+  fd := FailureDetector.New(id, peers, heartbeats, FailureDetector.NewPhiAccrual(8.0))
+  fd.Start()
+  ...
+  select {
+  case msg := <-messages[id]:
+    ...
+  case e := <-fd.Events:
+    // e.Type is SUSPECT or TRUST for node e.NodeId
+  }
+
+Heartbeats use their own channels, so that the messages of the algorithms are
+left untouched, and they are sent without blocking: a heartbeat to a node that
+does not receive anymore is simply lost. Like the messages, the heartbeat
+channels can be wrapped by the fault injection layer, with Network.Attach: a
+partition then makes the nodes on the other side suspected.
+
+  var network = Network.New(messages, faults)
+  var heartbeatNetwork = Network.Attach(network, heartbeats)
+  fd.Heartbeats = heartbeatNetwork.Links(id)
+
+Two suspicion strategies are provided:
+* Heartbeat: a node is suspected when no heartbeat was received from it for a fixed timeout
+* PhiAccrual: a node is suspected when phi, the suspicion level computed from
+  the distribution of the inter-arrival times of its last heartbeats, goes
+  over a threshold. phi = 1 means a 10% chance that suspecting is a mistake,
+  phi = 2 a 1% chance, etc.
+
+References :
+ * https://doi.org/10.1145/226643.226647: Tushar Deepak Chandra and Sam Toueg. 1996. Unreliable failure detectors for reliable distributed systems. J. ACM 43, 2 (March 1996), 225–267.
+ * https://doi.org/10.1109/RELDIS.2004.1353004: N. Hayashibara, X. Defago, R. Yared and T. Katayama, "The φ accrual failure detector," Proceedings of the 23rd IEEE International Symposium on Reliable Distributed Systems, 2004, pp. 66-78.
+*/
+
+package FailureDetector
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+/* global variable declaration */
+var HEARTBEAT_INTERVAL time.Duration = 100 * time.Millisecond
+
+// Event types
+var SUSPECT int = 0
+var TRUST   int = 1
+
+type Event struct {
+	NodeId int
+	Type   int
+}
+
+// Strategy decides from the arrival times of its heartbeats whether a node is suspected
+type Strategy interface {
+	// Arrived records a heartbeat received at time now
+	Arrived(now time.Time)
+	// Suspect returns true if the node must be suspected at time now
+	Suspect(now time.Time) bool
+}
+
+type FailureDetector struct {
+	Id         int
+	Peers      []int
+	Events     chan Event
+	Heartbeats []chan int // Heartbeats[Id] is received on, the others are sent to
+	strategies map[int]Strategy
+	suspected  map[int]bool
+	mutex      sync.Mutex
+	stop       chan bool
+}
+
+// NewHeartbeatChannels returns the heartbeat channels of nbNodes nodes, shared by all their failure detectors
+func NewHeartbeatChannels(nbNodes int) []chan int {
+	var heartbeats = make([]chan int, nbNodes)
+	for i := 0; i < nbNodes; i++ {
+		heartbeats[i] = make(chan int, nbNodes)
+	}
+	return heartbeats
+}
+
+// New returns the failure detector of node id, monitoring peers with a copy of strategy for each of them
+func New(id int, peers []int, heartbeats []chan int, strategy func() Strategy) *FailureDetector {
+	var fd FailureDetector
+	fd.Id = id
+	fd.Peers = peers
+	fd.Events = make(chan Event, len(peers))
+	fd.Heartbeats = heartbeats
+	fd.strategies = make(map[int]Strategy)
+	fd.suspected = make(map[int]bool)
+	for i := 0; i < len(peers); i++ {
+		fd.strategies[peers[i]] = strategy()
+		fd.suspected[peers[i]] = false
+	}
+	fd.stop = make(chan bool)
+	return &fd
+}
+
+func (fd *FailureDetector) Start() {
+	var now time.Time = time.Now()
+	for i := 0; i < len(fd.Peers); i++ {
+		// Peers are assumed alive at startup
+		fd.strategies[fd.Peers[i]].Arrived(now)
+	}
+	go fd.sendHeartbeats()
+	go fd.receiveHeartbeats()
+}
+
+// Stop stops the failure detector, its node is then suspected by the others as if it had crashed
+func (fd *FailureDetector) Stop() {
+	close(fd.stop)
+}
+
+func (fd *FailureDetector) IsSuspected(id int) bool {
+	fd.mutex.Lock()
+	defer fd.mutex.Unlock()
+	return fd.suspected[id]
+}
+
+func (fd *FailureDetector) sendHeartbeats() {
+	var ticker = time.NewTicker(HEARTBEAT_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fd.stop:
+			return
+		case <-ticker.C:
+			for i := 0; i < len(fd.Peers); i++ {
+				select {
+				case fd.Heartbeats[fd.Peers[i]] <- fd.Id:
+				default:
+					// the peer does not receive its heartbeats anymore
+				}
+			}
+		}
+	}
+}
+
+func (fd *FailureDetector) receiveHeartbeats() {
+	var ticker = time.NewTicker(HEARTBEAT_INTERVAL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fd.stop:
+			return
+		case sender := <-fd.Heartbeats[fd.Id]:
+			fd.mutex.Lock()
+			if strategy, ok := fd.strategies[sender]; ok {
+				strategy.Arrived(time.Now())
+			}
+			fd.mutex.Unlock()
+		case now := <-ticker.C:
+			fd.check(now)
+		}
+	}
+}
+
+// check publishes an event for each peer whose status changed
+func (fd *FailureDetector) check(now time.Time) {
+	var events []Event
+	fd.mutex.Lock()
+	for i := 0; i < len(fd.Peers); i++ {
+		var peer int = fd.Peers[i]
+		var suspect bool = fd.strategies[peer].Suspect(now)
+		if suspect != fd.suspected[peer] {
+			fd.suspected[peer] = suspect
+			var e Event
+			e.NodeId = peer
+			if suspect {
+				e.Type = SUSPECT
+				log.Print("FailureDetector #", fd.Id, ", SUSPECT Node #", peer)
+			} else {
+				e.Type = TRUST
+				log.Print("FailureDetector #", fd.Id, ", TRUST Node #", peer)
+			}
+			events = append(events, e)
+		}
+	}
+	fd.mutex.Unlock()
+	for i := 0; i < len(events); i++ {
+		select {
+		case fd.Events <- events[i]:
+		case <-fd.stop:
+			return
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Heartbeat strategy
+////////////////////////////////////////////////////////////
+type Heartbeat struct {
+	Timeout time.Duration
+	last    time.Time
+}
+
+func NewHeartbeat(timeout time.Duration) func() Strategy {
+	return func() Strategy {
+		var h Heartbeat
+		h.Timeout = timeout
+		return &h
+	}
+}
+
+func (h *Heartbeat) Arrived(now time.Time) {
+	h.last = now
+}
+
+func (h *Heartbeat) Suspect(now time.Time) bool {
+	return now.Sub(h.last) > h.Timeout
+}
+
+////////////////////////////////////////////////////////////
+// Phi accrual strategy
+////////////////////////////////////////////////////////////
+var PHI_WINDOW_SIZE int = 100
+
+type PhiAccrual struct {
+	Threshold float64
+	last      time.Time
+	// inter-arrival times in milliseconds, at most PHI_WINDOW_SIZE
+	intervals []float64
+}
+
+func NewPhiAccrual(threshold float64) func() Strategy {
+	return func() Strategy {
+		var p PhiAccrual
+		p.Threshold = threshold
+		return &p
+	}
+}
+
+func (p *PhiAccrual) Arrived(now time.Time) {
+	if !p.last.IsZero() {
+		p.intervals = append(p.intervals, float64(now.Sub(p.last)) / float64(time.Millisecond))
+		if len(p.intervals) > PHI_WINDOW_SIZE {
+			p.intervals = p.intervals[1:]
+		}
+	}
+	p.last = now
+}
+
+// Phi returns the suspicion level at time now
+func (p *PhiAccrual) Phi(now time.Time) float64 {
+	var mean float64 = float64(HEARTBEAT_INTERVAL) / float64(time.Millisecond)
+	var variance float64 = 0
+	if len(p.intervals) > 0 {
+		mean = 0
+		for i := 0; i < len(p.intervals); i++ {
+			mean += p.intervals[i]
+		}
+		mean /= float64(len(p.intervals))
+		for i := 0; i < len(p.intervals); i++ {
+			variance += (p.intervals[i] - mean) * (p.intervals[i] - mean)
+		}
+		variance /= float64(len(p.intervals))
+	}
+	// keep a minimum deviation, heartbeats sent by a ticker are very regular
+	var stddev float64 = math.Max(math.Sqrt(variance), mean / 10)
+	var elapsed float64 = float64(now.Sub(p.last)) / float64(time.Millisecond)
+	// probability that the next heartbeat arrives later than elapsed, for a normal distribution
+	var pLater float64 = 0.5 * math.Erfc((elapsed - mean) / (stddev * math.Sqrt2))
+	if pLater < 1e-300 {
+		return 300
+	}
+	return -math.Log10(pLater)
+}
+
+func (p *PhiAccrual) Suspect(now time.Time) bool {
+	return p.Phi(now) > p.Threshold
+}
@@ -0,0 +1,158 @@
+package FailureDetector
+
+import (
+	"testing"
+	"time"
+
+	"drinking/Network"
+)
+
+func init() {
+	// set once, the routines of a stopped failure detector read it until they end
+	HEARTBEAT_INTERVAL = 20 * time.Millisecond
+}
+
+func TestHeartbeat(t *testing.T) {
+	var h Strategy = NewHeartbeat(100 * time.Millisecond)()
+	var start time.Time = time.Now()
+	h.Arrived(start)
+	if h.Suspect(start.Add(50 * time.Millisecond)) {
+		t.Error("suspected before the timeout")
+	}
+	if !h.Suspect(start.Add(150 * time.Millisecond)) {
+		t.Error("not suspected after the timeout")
+	}
+	h.Arrived(start.Add(150 * time.Millisecond))
+	if h.Suspect(start.Add(200 * time.Millisecond)) {
+		t.Error("suspected after a new heartbeat")
+	}
+}
+
+// phi grows with the time since the last heartbeat, and faster when the
+// heartbeats are regular
+func TestPhiAccrual(t *testing.T) {
+	var regular = NewPhiAccrual(8.0)().(*PhiAccrual)
+	var irregular = NewPhiAccrual(8.0)().(*PhiAccrual)
+	var start time.Time = time.Now()
+	var last time.Time = start
+	for i := 0; i < 20; i++ {
+		regular.Arrived(start.Add(time.Duration(i) * 100 * time.Millisecond))
+		last = last.Add(time.Duration(50 + 100 * (i % 2)) * time.Millisecond)
+		irregular.Arrived(last)
+	}
+	last = start.Add(19 * 100 * time.Millisecond)
+	var previous float64 = -1
+	for _, elapsed := range []time.Duration{50, 100, 150, 200, 400} {
+		var phi float64 = regular.Phi(last.Add(elapsed * time.Millisecond))
+		if phi < previous {
+			t.Error("phi decreases: ", previous, " then ", phi, " after ", elapsed, "ms")
+		}
+		previous = phi
+	}
+	if regular.Suspect(last.Add(100 * time.Millisecond)) {
+		t.Error("suspected on time, phi=", regular.Phi(last.Add(100 * time.Millisecond)))
+	}
+	if !regular.Suspect(last.Add(400 * time.Millisecond)) {
+		t.Error("not suspected after 4 intervals, phi=", regular.Phi(last.Add(400 * time.Millisecond)))
+	}
+	if irregular.Phi(last.Add(300 * time.Millisecond)) >= regular.Phi(last.Add(300 * time.Millisecond)) {
+		t.Error("irregular heartbeats are suspected sooner than regular ones")
+	}
+}
+
+// waitFor fails the test if fd does not reach suspected for each peer in time
+func waitFor(t *testing.T, fd *FailureDetector, suspected map[int]bool) {
+	var deadline time.Time = time.Now().Add(2 * time.Second)
+	for {
+		var ok bool = true
+		for peer, s := range suspected {
+			ok = ok && fd.IsSuspected(peer) == s
+		}
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			for peer, s := range suspected {
+				if fd.IsSuspected(peer) != s {
+					t.Error("FailureDetector #", fd.Id, ": Node #", peer, " suspected=", fd.IsSuspected(peer), ", expected ", s)
+				}
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// start returns the failure detectors of nbNodes nodes monitoring each
+// other, with their heartbeats through network when it is not nil
+func start(nbNodes int, network *Network.Network[int]) []*FailureDetector {
+	var heartbeats []chan int = NewHeartbeatChannels(nbNodes)
+	var links *Network.Network[int]
+	if network != nil {
+		links = Network.Attach(network, heartbeats)
+	}
+	var detectors []*FailureDetector
+	for i := 0; i < nbNodes; i++ {
+		var peers []int
+		for j := 0; j < nbNodes; j++ {
+			if j != i {
+				peers = append(peers, j)
+			}
+		}
+		var fd *FailureDetector = New(i, peers, heartbeats, NewHeartbeat(5 * HEARTBEAT_INTERVAL))
+		if links != nil {
+			fd.Heartbeats = links.Links(i)
+		}
+		detectors = append(detectors, fd)
+	}
+	if network != nil {
+		network.Start()
+	}
+	for _, fd := range detectors {
+		fd.Start()
+	}
+	return detectors
+}
+
+// A stopped node is suspected by the others, which keep trusting each other
+func TestStop(t *testing.T) {
+	var detectors []*FailureDetector = start(3, nil)
+	defer detectors[1].Stop()
+	defer detectors[2].Stop()
+	waitFor(t, detectors[1], map[int]bool{0: false, 2: false})
+	detectors[0].Stop()
+	waitFor(t, detectors[1], map[int]bool{0: true, 2: false})
+	waitFor(t, detectors[2], map[int]bool{0: true, 1: false})
+}
+
+// The heartbeats go through the network: a partition makes the other side
+// suspected, and it is trusted again once healed
+func TestPartition(t *testing.T) {
+	// the messages of the algorithm, the heartbeats are attached to them
+	var messages = make([]chan int, 3)
+	for i := 0; i < 3; i++ {
+		messages[i] = make(chan int)
+	}
+	var network = Network.New(messages, Network.Faults{Seed: 1})
+	var detectors []*FailureDetector = start(3, network)
+	defer network.Stop()
+	for _, fd := range detectors {
+		defer fd.Stop()
+	}
+	waitFor(t, detectors[0], map[int]bool{1: false, 2: false})
+	network.Partition([][]int{{0}, {1, 2}})
+	waitFor(t, detectors[0], map[int]bool{1: true, 2: true})
+	waitFor(t, detectors[1], map[int]bool{0: true, 2: false})
+	network.Heal()
+	waitFor(t, detectors[0], map[int]bool{1: false, 2: false})
+	waitFor(t, detectors[2], map[int]bool{0: false, 1: false})
+	// events were published for the changes
+	var events int = 0
+	for len(detectors[0].Events) > 0 {
+		<-detectors[0].Events
+		events ++
+	}
+	if events == 0 {
+		t.Error("no event published")
+	}
+}
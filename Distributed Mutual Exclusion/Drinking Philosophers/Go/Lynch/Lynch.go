@@ -0,0 +1,467 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+How-to run:
+  go run ./cmd/drinking --algo=Lynch 2>&1 |tee /tmp/tmp.log
+
+Parameters:
+- Number of nodes, each one manages the resources r such that r modulo the
+  number of nodes is its id
+- Number of iterations
+- Workload: the resources of each request, the think and CS durations, see
+  the Workload package
+- Seed of the random choices of the nodes (waiting times and requested resources)
+
+Coloring
+The conflict graph of the resources links two resources when a node may
+request them together, it is computed from the workload (Workload.ResourceGraph).
+The resources are colored so that neighbors have distinct colors
+(Topology.Color), hence all the resources of a request have distinct colors.
+With the uniform and zipf patterns any resources may be requested together,
+there are as many colors as resources and the algorithm is the ordering of
+Dijkstra. With the ring pattern and requests of at most k resources, there
+are about k colors whatever the number of resources.
+
+Protocol
+Each resource has a manager, with the node holding it and a FIFO queue of
+the nodes waiting for it.
+1. a node requests the resources of its request one at a time, in increasing
+   color order: it sends REQUEST for the next resource when it receives the
+   GRANT of the previous one
+2. a manager grants its resource to the requester if it is free, it queues
+   the request otherwise
+3. a node holding all its resources enters its CS, on exit it sends RELEASE
+   to the managers of its resources
+4. a manager receiving RELEASE grants its resource to the first node of its
+   queue
+A waiting node only holds resources of lower colors than the one it waits
+for, so the chains of waiting nodes follow increasing colors and there is no
+deadlock. The length of a chain is bounded by the number of colors instead of
+the number of resources, which bounds the response time.
+A node manages its own resources without messages.
+A node checks when it enters its CS that no other node uses its resources,
+the run ends on a Fatal otherwise.
+*/
+
+/*
+    Go implementation of Lynch's coloring resource allocation algorithm
+
+References :
+* https://doi.org/10.1145/800076.802487: Lynch, N. A. (1980). Fast allocation of nearby resources in a distributed system. Proceedings of the twelfth annual ACM symposium on Theory of computing.
+* https://doi.org/10.1016/0022-0000(81)90028-2: Lynch, N. A. (1981). Upper bounds for static resource allocation in a distributed system. Journal of Computer and System Sciences.
+*/
+
+package Lynch
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"drinking/Stats"
+	"drinking/Topology"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+/* global variable declaration */
+var NB_MSG            int = 0
+var CURRENT_ITERATION int = 0
+
+// States
+var STATE_IDLE    int = 0
+var STATE_WAITING int = 1
+var STATE_CS      int = 2
+
+// Message types
+var REQUEST int = 0
+var GRANT   int = 1
+var RELEASE int = 2
+
+var MESSAGE_NAMES = []string{"REQUEST", "GRANT", "RELEASE"}
+
+var Nodes []Node
+
+// Colors is the color of each resource, NbColors the number of colors
+var Colors   []int
+var NbColors int
+
+// Tracer records the execution when set, see the Trace package
+var Tracer *Trace.Tracer
+
+// Recorder records the response times when set, see the Stats package
+var Recorder *Stats.Recorder
+
+// globalMutex protects the variables shared by all the nodes: NB_MSG,
+// CURRENT_ITERATION and resourceUser
+var globalMutex sync.Mutex
+
+// resourceUser is the node in CS using each resource, to check mutual exclusion
+var resourceUser map[int]int
+
+type Message struct {
+	SenderId    int
+	MessageType int
+	ResourceId  int
+	TraceId     int
+}
+
+// Manager is the manager of a resource, hosted by a node
+type Manager struct {
+	holder int // -1 when the resource is free
+	queue  []int
+}
+
+type Node struct {
+	Id            int
+	State         int
+	NbCS          int
+	NbIterations  int
+	Messages      []chan bytes.Buffer
+	managers      map[int]*Manager // resource => manager, for the resources of the node
+	request       []int // resources of the current request, in color order
+	nbGranted     int // the resources request[:nbGranted] are held
+	workload      *Workload.Generator
+	csDuration    time.Duration // of the current request
+	mutex         sync.Mutex // held while a message is handled
+	stop          chan bool
+	stopped       bool
+}
+
+////////////////////////////////////////////////////////////
+// Utility functions
+////////////////////////////////////////////////////////////
+func UnmarshalMessage(b bytes.Buffer, message *Message) error {
+	dec := gob.NewDecoder(&b)
+	return dec.Decode(message)
+}
+
+func MarshalMessage(message Message) (bytes.Buffer, error) {
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	err := enc.Encode(message)
+	return buffer, err
+}
+
+func messageName(messageType int) string {
+	if messageType >= 0 && messageType < len(MESSAGE_NAMES) {
+		return MESSAGE_NAMES[messageType]
+	}
+	return strconv.Itoa(messageType)
+}
+
+// iterationsDone returns true when the nodes entered their CS nbIterations times in total
+func iterationsDone(nbIterations int) bool {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return CURRENT_ITERATION >= nbIterations
+}
+
+// host returns the node managing resource
+func host(resource int) int {
+	return resource % len(Nodes)
+}
+
+// colorOrder sorts resources by increasing color, then by id
+func colorOrder(resources []int) {
+	sort.Slice(resources, func(a, b int) bool {
+		if Colors[resources[a]] != Colors[resources[b]] {
+			return Colors[resources[a]] < Colors[resources[b]]
+		}
+		return resources[a] < resources[b]
+	})
+}
+
+func (n *Node) String() string {
+	return fmt.Sprintf("Node #%d, state=%d, request=%v, granted=%d",
+		n.Id,
+		n.State,
+		n.request,
+		n.nbGranted)
+}
+
+// traceState records the state of the node and the resources it holds
+func (n *Node) traceState() {
+	if Tracer == nil {
+		return
+	}
+	var state = map[string]interface{}{
+		"State": n.State,
+		"Request": n.request,
+		"Held": n.request[:n.nbGranted],
+	}
+	Tracer.State(n.Id, state)
+}
+
+////////////////////////////////////////////////////////////
+// Messages
+////////////////////////////////////////////////////////////
+
+// send sends a message to dst, or handles it at once if dst is the node
+// itself, called with the mutex held
+func (n *Node) send(dst int, messageType int, resource int) {
+	var message Message
+	message.SenderId = n.Id
+	message.MessageType = messageType
+	message.ResourceId = resource
+	if dst == n.Id {
+		n.handle(message)
+		return
+	}
+	message.TraceId = Tracer.NextId()
+	Tracer.Send(n.Id, dst, messageName(messageType), message.TraceId)
+	content, err := MarshalMessage(message)
+	if err != nil {
+		log.Fatal("send ", err)
+	}
+	// counted with the mutex held, so that NB_MSG is final once the nodes are stopped
+	globalMutex.Lock()
+	NB_MSG ++
+	globalMutex.Unlock()
+	go n.transmit(dst, content)
+}
+
+// transmit sends content to dst, unless the node is stopped before dst receives it
+func (n *Node) transmit(dst int, content bytes.Buffer) {
+	select {
+	case n.Messages[dst] <- content:
+	case <-n.stop:
+	}
+}
+
+func (n *Node) rcv() {
+	for {
+		select {
+		case <-n.stop:
+			return
+		case content := <-n.Messages[n.Id]:
+			var message Message
+			if err := UnmarshalMessage(content, &message); err != nil {
+				log.Fatal("rcv ", err)
+			}
+			n.mutex.Lock()
+			if n.stopped {
+				n.mutex.Unlock()
+				continue
+			}
+			Tracer.Receive(n.Id, message.SenderId, messageName(message.MessageType), message.TraceId)
+			n.handle(message)
+			n.mutex.Unlock()
+		}
+	}
+}
+
+// handle is called with the mutex held
+func (n *Node) handle(message Message) {
+	if message.MessageType == REQUEST {
+		n.manager(message.ResourceId).request(n, message.SenderId, message.ResourceId)
+	} else if message.MessageType == GRANT {
+		n.granted(message.ResourceId)
+	} else if message.MessageType == RELEASE {
+		n.manager(message.ResourceId).release(n, message.SenderId, message.ResourceId)
+	} else {
+		log.Fatal("Unknown message type=", message.MessageType)
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Managers, called with the mutex of their node held
+////////////////////////////////////////////////////////////
+func (n *Node) manager(resource int) *Manager {
+	var m *Manager = n.managers[resource]
+	if m == nil {
+		log.Fatal("Node #", n.Id, " does not manage resource #", resource)
+	}
+	return m
+}
+
+// request grants the resource to requester if it is free, queues it otherwise
+func (m *Manager) request(n *Node, requester int, resource int) {
+	if m.holder == -1 {
+		m.holder = requester
+		n.send(requester, GRANT, resource)
+	} else {
+		m.queue = append(m.queue, requester)
+	}
+}
+
+// release grants the resource to the first waiting node
+func (m *Manager) release(n *Node, sender int, resource int) {
+	if m.holder != sender {
+		log.Fatal("Node #", sender, " releases resource #", resource, " held by Node #", m.holder)
+	}
+	m.holder = -1
+	if len(m.queue) > 0 {
+		m.holder = m.queue[0]
+		m.queue = m.queue[1:]
+		n.send(m.holder, GRANT, resource)
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Requester, called with the mutex held
+////////////////////////////////////////////////////////////
+
+// requestNext asks for the next resource in color order
+func (n *Node) requestNext() {
+	var resource int = n.request[n.nbGranted]
+	n.send(host(resource), REQUEST, resource)
+}
+
+func (n *Node) granted(resource int) {
+	if n.State != STATE_WAITING || n.request[n.nbGranted] != resource {
+		log.Fatal("Node #", n.Id, " granted resource #", resource, " it did not ask for, ", n)
+	}
+	n.nbGranted ++
+	n.traceState()
+	if n.nbGranted < len(n.request) {
+		n.requestNext()
+		return
+	}
+	n.EnterCS()
+	go n.executeCS()
+}
+
+////////////////////////////////////////////////////////////
+// Critical section
+////////////////////////////////////////////////////////////
+
+// EnterCS is called with the mutex held
+func (n *Node) EnterCS() {
+	log.Print("Node #", n.Id, " ######################### EnterCS, resources ", n.request)
+	globalMutex.Lock()
+	for _, r := range n.request {
+		if user, ok := resourceUser[r]; ok {
+			log.Fatal("Node #", n.Id, " enters CS with resource #", r, " used by Node #", user)
+		}
+		resourceUser[r] = n.Id
+	}
+	CURRENT_ITERATION ++
+	globalMutex.Unlock()
+	n.State = STATE_CS
+	n.NbCS ++
+	Recorder.EnterCS(n.Id)
+	Tracer.EnterCS(n.Id)
+	n.traceState()
+}
+
+func (n *Node) ExecuteCSCode() {
+	time.Sleep(n.csDuration)
+}
+
+// ReleaseCS is called with the mutex held
+func (n *Node) ReleaseCS() {
+	log.Print("Node #", n.Id, " ReleaseCS #########################")
+	globalMutex.Lock()
+	for _, r := range n.request {
+		delete(resourceUser, r)
+	}
+	globalMutex.Unlock()
+	Tracer.ReleaseCS(n.Id)
+	var resources []int = n.request
+	n.State = STATE_IDLE
+	n.request = nil
+	n.nbGranted = 0
+	n.traceState()
+	for _, r := range resources {
+		n.send(host(r), RELEASE, r)
+	}
+}
+
+// executeCS runs the CS outside of the routine receiving the messages, then
+// starts the next request
+func (n *Node) executeCS() {
+	n.ExecuteCSCode()
+
+	n.mutex.Lock()
+	if n.stopped {
+		n.mutex.Unlock()
+		return
+	}
+	n.ReleaseCS()
+	n.mutex.Unlock()
+	n.requestCS()
+}
+
+// requestCS waits for the think time then requests the first resource
+func (n *Node) requestCS() {
+	if iterationsDone(n.NbIterations) {
+		return
+	}
+	var next Workload.Request = n.workload.Next()
+	time.Sleep(next.Think)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.stopped || iterationsDone(n.NbIterations) {
+		return
+	}
+	n.request = next.Resources
+	colorOrder(n.request)
+	n.csDuration = next.CS
+	n.nbGranted = 0
+	n.State = STATE_WAITING
+	log.Print("Node #", n.Id, " requests ", n.request)
+	Recorder.Request(n.Id)
+	n.traceState()
+	n.requestNext()
+}
+
+func (n *Node) Lynch(wg *sync.WaitGroup) {
+	go n.requestCS()
+	go n.rcv()
+	for {
+		time.Sleep(100 * time.Millisecond)
+		if iterationsDone(n.NbIterations) {
+			break
+		}
+	}
+
+	log.Print("Node #", n.Id, " END after ", n.NbIterations, " CS entries")
+	wg.Done()
+}
+
+// Stop ends the routines of all the nodes once the run is over
+func Stop() {
+	for i := 0; i < len(Nodes); i++ {
+		Nodes[i].mutex.Lock()
+		Nodes[i].stopped = true
+		close(Nodes[i].stop)
+		Nodes[i].mutex.Unlock()
+	}
+}
+
+func Init(nbNodes int, nbIterations int, workload *Workload.Config, seed int64) {
+	log.Print("Lynch.Init, seed ", seed, ", workload ", workload)
+	var graph *Topology.Graph = workload.ResourceGraph(nbNodes)
+	Colors, NbColors = graph.Color()
+	log.Print("Lynch.Init, ", NbColors, " colors for ", workload.NbResources, " resources, conflict graph ", graph)
+
+	NB_MSG = 0
+	CURRENT_ITERATION = 0
+	resourceUser = make(map[int]int)
+	Nodes = make([]Node, nbNodes)
+	var messages = make([]chan bytes.Buffer, nbNodes)
+
+	for i := 0; i < nbNodes; i++ {
+		var n *Node = &Nodes[i]
+		n.Id = i
+		n.State = STATE_IDLE
+		n.NbIterations = nbIterations
+		messages[i] = make(chan bytes.Buffer)
+		n.managers = make(map[int]*Manager)
+		for r := i; r < workload.NbResources; r += nbNodes {
+			n.managers[r] = &Manager{holder: -1}
+		}
+		n.workload = workload.Generator(i, seed + int64(i))
+		n.stop = make(chan bool)
+	}
+	for i := 0; i < nbNodes; i++ {
+		Nodes[i].Messages = messages
+	}
+}
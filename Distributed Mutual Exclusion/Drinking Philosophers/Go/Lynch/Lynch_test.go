@@ -0,0 +1,27 @@
+package Lynch
+
+import (
+	"testing"
+
+	"drinking/internal/testutil"
+	"drinking/Workload"
+)
+
+// The resources requested together have distinct colors, with the ring
+// pattern their number does not depend on the number of nodes
+func TestColors(t *testing.T) {
+	for _, nbNodes := range []int{6, 12, 30} {
+		var workload *Workload.Config = testutil.NewWorkload(t, nbNodes, 3)
+		workload.SetPattern(Workload.RING)
+		Init(nbNodes, 1, workload, 1)
+		var graph = workload.ResourceGraph(nbNodes)
+		for _, edge := range graph.Edges() {
+			if Colors[edge[0]] == Colors[edge[1]] {
+				t.Error(nbNodes, " nodes: resources #", edge[0], " and #", edge[1], " have the same color ", Colors[edge[0]])
+			}
+		}
+		if NbColors > 3 {
+			t.Error(nbNodes, " nodes: ", NbColors, " colors instead of 3")
+		}
+	}
+}
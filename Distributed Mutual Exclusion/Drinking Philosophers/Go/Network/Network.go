@@ -0,0 +1,469 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Fault injection layer for the in-process channels used between nodes.
+
+All the algorithms use a slice of channels, one per node: a node receives on
+messages[id] and sends to messages[dst]. Links(src) returns such a slice for
+node src where messages[src] is its own inbox and each messages[dst] is a link
+from src to dst. Messages sent on a link are delayed, reordered, duplicated or
+dropped before being delivered to the inbox of dst, and all messages between
+two partitioned nodes are dropped. Messages a node sends to itself are not
+affected.
+
+Usage:
+  var network = Network.New(messages, faults)
+  for i := 0; i < nbNodes; i++ {
+    nodes[i].messages = network.Links(i)
+  }
+  network.Start()
+  ...
+  network.Stop()
+  log.Print(network.Stats())
+
+Faults are drawn from a random generator per link, seeded from Faults.Seed, so
+that a run can be replayed with the same faults on each link. The faults can
+also change during the run following a script, see ParseSchedule.
+
+Messages of another type between the same nodes, e.g. the heartbeats of a
+failure detector, go through a network attached to the first one with Attach:
+they share its faults, partition and schedule, and are started and stopped
+with it.
+*/
+
+package Network
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Faults struct {
+	Seed          int64
+	DropRate      float64
+	DuplicateRate float64
+	// probability that a message is held back and delivered after the next one
+	// on the same link, or after MAX_HOLD if no other message comes
+	ReorderRate   float64
+	MinDelay      time.Duration
+	MaxDelay      time.Duration
+}
+
+// Step is a change of the faults applied At a given time after Start
+type Step struct {
+	At        time.Duration
+	Faults    *Faults // nil if the faults do not change
+	Partition [][]int // nil if the partition does not change
+	Heal      bool    // remove the partition
+}
+
+// MAX_HOLD is the longest time a message is held back to be reordered
+var MAX_HOLD time.Duration = 100 * time.Millisecond
+
+type LinkStats struct {
+	Sent       int
+	Delivered  int
+	Dropped    int
+	Duplicated int
+	Reordered  int
+	Lost       int // still on the link when the network stopped
+}
+
+// control is the state shared by a network and the networks attached to it
+type control struct {
+	faults    Faults
+	partition []int // set of each node, nil when there is no partition
+	schedule  []Step
+	mutex     sync.Mutex
+	stop      chan bool
+	attached  []func() // starts the forwarding of the attached networks
+	wg        sync.WaitGroup // the forwarding routines of all the networks
+}
+
+type Network[T any] struct {
+	*control
+	nbNodes   int
+	inboxes   []chan T
+	links     [][]chan T
+	stats     [][]LinkStats // protected by the mutex of control
+	seed      int64 // added to the seed of each link, so that attached networks draw other faults
+}
+
+// New wraps the inboxes of the nodes, inboxes[i] being the channel node #i receives on
+func New[T any](inboxes []chan T, faults Faults) *Network[T] {
+	var c = &control{faults: faults, stop: make(chan bool)}
+	return newNetwork(c, inboxes, 0)
+}
+
+// Attach wraps inboxes, messages of another type between the nodes of n,
+// with the faults and partition of n. The attached network is started and
+// stopped with n, Attach must be called before n.Start
+func Attach[T any, U any](n *Network[T], inboxes []chan U) *Network[U] {
+	var seed int64 = int64(n.nbNodes * n.nbNodes * (len(n.attached) + 1))
+	var attached *Network[U] = newNetwork(n.control, inboxes, seed)
+	n.attached = append(n.attached, attached.startLinks)
+	return attached
+}
+
+func newNetwork[T any](c *control, inboxes []chan T, seed int64) *Network[T] {
+	var n Network[T]
+	n.control = c
+	n.nbNodes = len(inboxes)
+	n.inboxes = inboxes
+	n.seed = seed
+	n.links = make([][]chan T, n.nbNodes)
+	n.stats = make([][]LinkStats, n.nbNodes)
+	for src := 0; src < n.nbNodes; src++ {
+		n.links[src] = make([]chan T, n.nbNodes)
+		n.stats[src] = make([]LinkStats, n.nbNodes)
+		for dst := 0; dst < n.nbNodes; dst++ {
+			if src == dst {
+				n.links[src][dst] = inboxes[dst]
+			} else {
+				// a link keeps the capacity of the inbox it wraps
+				n.links[src][dst] = make(chan T, cap(inboxes[dst]))
+			}
+		}
+	}
+	return &n
+}
+
+// Links returns the channels node src must use, to receive on its own index and to send on the others
+func (n *Network[T]) Links(src int) []chan T {
+	return n.links[src]
+}
+
+func (n *Network[T]) SetSchedule(schedule []Step) {
+	n.schedule = schedule
+}
+
+func (n *Network[T]) Start() {
+	n.startLinks()
+	for _, start := range n.attached {
+		start()
+	}
+	go n.runSchedule()
+}
+
+func (n *Network[T]) startLinks() {
+	for src := 0; src < n.nbNodes; src++ {
+		for dst := 0; dst < n.nbNodes; dst++ {
+			if src != dst {
+				n.wg.Add(1)
+				go n.forward(src, dst)
+			}
+		}
+	}
+}
+
+// Stop stops forwarding messages, on n and the networks attached to it,
+// messages still on the links are lost and counted as such
+func (n *Network[T]) Stop() {
+	close(n.stop)
+	n.wg.Wait()
+}
+
+func (n *Network[T]) SetFaults(faults Faults) {
+	n.mutex.Lock()
+	n.faults = faults
+	n.mutex.Unlock()
+	log.Print("Network, faults: ", faults.String())
+}
+
+// Partition splits the nodes in sets that cannot communicate with each other,
+// nodes that are in none of the sets form one more set
+func (n *Network[T]) Partition(sets [][]int) {
+	var partition = make([]int, n.nbNodes)
+	for i := 0; i < n.nbNodes; i++ {
+		partition[i] = len(sets)
+	}
+	for s := 0; s < len(sets); s++ {
+		for _, id := range sets[s] {
+			if id >= 0 && id < n.nbNodes {
+				partition[id] = s
+			}
+		}
+	}
+	n.mutex.Lock()
+	n.partition = partition
+	n.mutex.Unlock()
+	log.Print("Network, partition: ", sets)
+}
+
+func (n *Network[T]) Heal() {
+	n.mutex.Lock()
+	n.partition = nil
+	n.mutex.Unlock()
+	log.Print("Network, partition healed")
+}
+
+func (n *Network[T]) isPartitioned(src int, dst int) bool {
+	return n.partition != nil && n.partition[src] != n.partition[dst]
+}
+
+func (n *Network[T]) runSchedule() {
+	var start time.Time = time.Now()
+	for i := 0; i < len(n.schedule); i++ {
+		var step Step = n.schedule[i]
+		select {
+		case <-n.stop:
+			return
+		case <-time.After(time.Until(start.Add(step.At))):
+		}
+		if step.Faults != nil {
+			n.SetFaults(*step.Faults)
+		}
+		if step.Heal {
+			n.Heal()
+		}
+		if step.Partition != nil {
+			n.Partition(step.Partition)
+		}
+	}
+}
+
+func (n *Network[T]) forward(src int, dst int) {
+	defer n.wg.Done()
+	n.mutex.Lock()
+	var seed int64 = n.faults.Seed
+	n.mutex.Unlock()
+	var random = rand.New(rand.NewSource(seed + n.seed + int64(src * n.nbNodes + dst)))
+	var held []T
+	// ready when the held messages waited MAX_HOLD for a next message
+	var flush <-chan time.Time
+	for {
+		var msg T
+		select {
+		case <-n.stop:
+			n.lose(src, dst, len(held))
+			return
+		case <-flush:
+			if n.deliver(src, dst, held) == false {
+				return
+			}
+			held = nil
+			flush = nil
+			continue
+		case msg = <-n.links[src][dst]:
+		}
+		n.mutex.Lock()
+		var faults Faults = n.faults
+		var partitioned bool = n.isPartitioned(src, dst)
+		var stats *LinkStats = &n.stats[src][dst]
+		stats.Sent ++
+		var copies int = 1
+		if partitioned || random.Float64() < faults.DropRate {
+			stats.Dropped ++
+			copies = 0
+		} else if random.Float64() < faults.DuplicateRate {
+			stats.Duplicated ++
+			copies = 2
+		}
+		var reorder bool = copies > 0 && random.Float64() < faults.ReorderRate
+		if reorder {
+			stats.Reordered ++
+		}
+		var delay time.Duration = faults.MinDelay
+		if faults.MaxDelay > faults.MinDelay {
+			delay += time.Duration(random.Int63n(int64(faults.MaxDelay - faults.MinDelay)))
+		}
+		n.mutex.Unlock()
+
+		if copies == 0 {
+			continue
+		}
+		var batch []T
+		for c := 0; c < copies; c++ {
+			batch = append(batch, msg)
+		}
+		if reorder {
+			// delivered after the next message on this link
+			held = append(held, batch...)
+			if flush == nil {
+				flush = time.After(MAX_HOLD)
+			}
+			continue
+		}
+		if delay > 0 {
+			select {
+			case <-n.stop:
+				n.lose(src, dst, len(batch) + len(held))
+				return
+			case <-time.After(delay):
+			}
+		}
+		if n.deliver(src, dst, append(batch, held...)) == false {
+			return
+		}
+		held = nil
+		flush = nil
+	}
+}
+
+// deliver delivers msgs in order, it returns false if the network stopped
+// before, the messages not delivered are then lost
+func (n *Network[T]) deliver(src int, dst int, msgs []T) bool {
+	for k, msg := range msgs {
+		select {
+		case <-n.stop:
+			n.lose(src, dst, len(msgs) - k)
+			return false
+		case n.inboxes[dst] <- msg:
+		}
+		n.mutex.Lock()
+		n.stats[src][dst].Delivered ++
+		n.mutex.Unlock()
+	}
+	return true
+}
+
+func (n *Network[T]) lose(src int, dst int, nbMsg int) {
+	n.mutex.Lock()
+	n.stats[src][dst].Lost += nbMsg
+	n.mutex.Unlock()
+}
+
+// Stats returns a summary of what happened on all the links
+func (n *Network[T]) Stats() string {
+	var total LinkStats
+	var val string = ""
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for src := 0; src < n.nbNodes; src++ {
+		for dst := 0; dst < n.nbNodes; dst++ {
+			var s LinkStats = n.stats[src][dst]
+			if s.Sent == 0 {
+				continue
+			}
+			val += fmt.Sprintf("  %d -> %d: %s\n", src, dst, s.String())
+			total.Sent += s.Sent
+			total.Delivered += s.Delivered
+			total.Dropped += s.Dropped
+			total.Duplicated += s.Duplicated
+			total.Reordered += s.Reordered
+			total.Lost += s.Lost
+		}
+	}
+	return "Network stats: " + total.String() + "\n" + val
+}
+
+func (s *LinkStats) String() string {
+	return fmt.Sprintf("sent=%d, delivered=%d, dropped=%d, duplicated=%d, reordered=%d, lost=%d",
+		s.Sent,
+		s.Delivered,
+		s.Dropped,
+		s.Duplicated,
+		s.Reordered,
+		s.Lost)
+}
+
+func (f *Faults) String() string {
+	return fmt.Sprintf("seed=%d, drop=%.2f, duplicate=%.2f, reorder=%.2f, delay=[%v, %v]",
+		f.Seed,
+		f.DropRate,
+		f.DuplicateRate,
+		f.ReorderRate,
+		f.MinDelay,
+		f.MaxDelay)
+}
+
+////////////////////////////////////////////////////////////
+// Scripts
+////////////////////////////////////////////////////////////
+
+// ParseSchedule reads a script of faults, starting from the initial faults,
+// one step per line, # starts a comment:
+//   <time> drop <rate>
+//   <time> duplicate <rate>
+//   <time> reorder <rate>
+//   <time> delay <min> <max>
+//   <time> partition <id,id,...> <id,id,...> ...
+//   <time> heal
+// where time is a Go duration since the start of the run, e.g. 1.5s
+func ParseSchedule(r io.Reader, initial Faults) ([]Step, error) {
+	var schedule []Step
+	var faults Faults = initial
+	var scanner = bufio.NewScanner(r)
+	var lineNumber int = 0
+	for scanner.Scan() {
+		lineNumber ++
+		var line string = scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		var fields []string = strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected <time> <action>", lineNumber)
+		}
+		var step Step
+		var err error
+		step.At, err = time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		var args []string = fields[2:]
+		switch fields[1] {
+		case "drop", "duplicate", "reorder":
+			if len(args) != 1 {
+				return nil, fmt.Errorf("line %d: %s expects a rate", lineNumber, fields[1])
+			}
+			rate, err := strconv.ParseFloat(args[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			if fields[1] == "drop" {
+				faults.DropRate = rate
+			} else if fields[1] == "duplicate" {
+				faults.DuplicateRate = rate
+			} else {
+				faults.ReorderRate = rate
+			}
+			var f Faults = faults
+			step.Faults = &f
+		case "delay":
+			if len(args) != 2 {
+				return nil, fmt.Errorf("line %d: delay expects a minimum and a maximum", lineNumber)
+			}
+			faults.MinDelay, err = time.ParseDuration(args[0])
+			if err == nil {
+				faults.MaxDelay, err = time.ParseDuration(args[1])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			var f Faults = faults
+			step.Faults = &f
+		case "partition":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("line %d: partition expects at least one set of nodes", lineNumber)
+			}
+			for _, arg := range args {
+				var set []int
+				for _, id := range strings.Split(arg, ",") {
+					v, err := strconv.Atoi(id)
+					if err != nil {
+						return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+					}
+					set = append(set, v)
+				}
+				step.Partition = append(step.Partition, set)
+			}
+		case "heal":
+			step.Heal = true
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %s", lineNumber, fields[1])
+		}
+		schedule = append(schedule, step)
+	}
+	return schedule, scanner.Err()
+}
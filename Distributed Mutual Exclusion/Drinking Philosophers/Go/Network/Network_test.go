@@ -0,0 +1,217 @@
+package Network
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTest returns a started network of nbNodes nodes with buffered inboxes
+func newTest(nbNodes int, faults Faults) (*Network[int], []chan int) {
+	var inboxes = make([]chan int, nbNodes)
+	for i := 0; i < nbNodes; i++ {
+		inboxes[i] = make(chan int, 100)
+	}
+	var network *Network[int] = New(inboxes, faults)
+	network.Start()
+	return network, inboxes
+}
+
+// receive returns the messages received on inbox until nothing comes for timeout
+func receive(inbox chan int, timeout time.Duration) []int {
+	var msgs []int
+	for {
+		select {
+		case msg := <-inbox:
+			msgs = append(msgs, msg)
+		case <-time.After(timeout):
+			return msgs
+		}
+	}
+}
+
+func (n *Network[T]) linkStats(src int, dst int) LinkStats {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.stats[src][dst]
+}
+
+// Without faults, the messages of a link are delivered once and in order
+func TestNoFaults(t *testing.T) {
+	network, inboxes := newTest(2, Faults{Seed: 1})
+	defer network.Stop()
+	for i := 0; i < 10; i++ {
+		network.Links(0)[1] <- i
+	}
+	var msgs []int = receive(inboxes[1], 50 * time.Millisecond)
+	if len(msgs) != 10 {
+		t.Fatal("received ", msgs)
+	}
+	for i := 0; i < 10; i++ {
+		if msgs[i] != i {
+			t.Error("out of order: ", msgs)
+			break
+		}
+	}
+	// messages to itself do not go through the network
+	if network.Links(0)[0] != inboxes[0] {
+		t.Error("link to itself is not the inbox")
+	}
+}
+
+func TestDropDuplicate(t *testing.T) {
+	network, inboxes := newTest(2, Faults{Seed: 1, DropRate: 1})
+	for i := 0; i < 5; i++ {
+		network.Links(0)[1] <- i
+	}
+	if msgs := receive(inboxes[1], 50 * time.Millisecond); len(msgs) != 0 {
+		t.Error("dropped messages received: ", msgs)
+	}
+	network.SetFaults(Faults{Seed: 1, DuplicateRate: 1})
+	for i := 0; i < 5; i++ {
+		network.Links(0)[1] <- i
+	}
+	if msgs := receive(inboxes[1], 50 * time.Millisecond); len(msgs) != 10 {
+		t.Error("duplicated messages received: ", msgs)
+	}
+	network.Stop()
+	var stats LinkStats = network.linkStats(0, 1)
+	if stats.Sent != 10 || stats.Dropped != 5 || stats.Duplicated != 5 || stats.Delivered != 10 {
+		t.Error(stats.String())
+	}
+}
+
+// A message held back to be reordered is delivered after the next one, or
+// after MAX_HOLD when it is the last one on its link
+func TestReorder(t *testing.T) {
+	network, inboxes := newTest(2, Faults{Seed: 1, ReorderRate: 1})
+	defer network.Stop()
+	network.Links(0)[1] <- 1
+	// the faults are read once the message is on the link
+	time.Sleep(10 * time.Millisecond)
+	network.SetFaults(Faults{Seed: 1})
+	network.Links(0)[1] <- 2
+	if msgs := receive(inboxes[1], 50 * time.Millisecond); len(msgs) != 2 || msgs[0] != 2 || msgs[1] != 1 {
+		t.Error("expected [2 1], received ", msgs)
+	}
+	network.SetFaults(Faults{Seed: 1, ReorderRate: 1})
+	network.Links(0)[1] <- 3
+	if msgs := receive(inboxes[1], MAX_HOLD + 100 * time.Millisecond); len(msgs) != 1 || msgs[0] != 3 {
+		t.Error("last held message not delivered, received ", msgs)
+	}
+}
+
+// Messages still held back when the network stops are counted as lost
+func TestStopLost(t *testing.T) {
+	MAX_HOLD = time.Hour
+	defer func() { MAX_HOLD = 100 * time.Millisecond }()
+	network, _ := newTest(2, Faults{Seed: 1, ReorderRate: 1})
+	network.Links(0)[1] <- 1
+	network.Links(0)[1] <- 2
+	time.Sleep(20 * time.Millisecond)
+	network.Stop()
+	var stats LinkStats = network.linkStats(0, 1)
+	if stats.Sent != 2 || stats.Lost != 2 || stats.Delivered != 0 {
+		t.Error(stats.String())
+	}
+	if !strings.Contains(network.Stats(), "lost=2") {
+		t.Error(network.Stats())
+	}
+}
+
+// A partition drops the messages between its sets, in both directions, on the
+// network and on the networks attached to it
+func TestPartition(t *testing.T) {
+	var inboxes = make([]chan int, 3)
+	var heartbeats = make([]chan bool, 3)
+	for i := 0; i < 3; i++ {
+		inboxes[i] = make(chan int, 100)
+		heartbeats[i] = make(chan bool, 100)
+	}
+	var network *Network[int] = New(inboxes, Faults{Seed: 1})
+	var attached *Network[bool] = Attach(network, heartbeats)
+	network.Start()
+	defer network.Stop()
+	network.Partition([][]int{{0}, {1, 2}})
+	network.Links(0)[1] <- 1
+	network.Links(2)[0] <- 2
+	network.Links(1)[2] <- 3
+	attached.Links(1)[0] <- true
+	if msgs := receive(inboxes[0], 50 * time.Millisecond); len(msgs) != 0 {
+		t.Error("received across the partition: ", msgs)
+	}
+	if msgs := receive(inboxes[1], 10 * time.Millisecond); len(msgs) != 0 {
+		t.Error("received across the partition: ", msgs)
+	}
+	if msgs := receive(inboxes[2], 10 * time.Millisecond); len(msgs) != 1 {
+		t.Error("not received in the same set: ", msgs)
+	}
+	if len(heartbeats[0]) != 0 {
+		t.Error("attached network received across the partition")
+	}
+	network.Heal()
+	network.Links(0)[1] <- 4
+	attached.Links(1)[0] <- true
+	if msgs := receive(inboxes[1], 50 * time.Millisecond); len(msgs) != 1 {
+		t.Error("not received after heal: ", msgs)
+	}
+	select {
+	case <-heartbeats[0]:
+	case <-time.After(50 * time.Millisecond):
+		t.Error("attached network did not receive after heal")
+	}
+}
+
+// The faults of each link are drawn from the seed, a run can be replayed
+func TestSeed(t *testing.T) {
+	var run = func(seed int64) LinkStats {
+		network, inboxes := newTest(2, Faults{Seed: seed, DropRate: 0.3, DuplicateRate: 0.3})
+		for i := 0; i < 50; i++ {
+			network.Links(0)[1] <- i
+		}
+		receive(inboxes[1], 50 * time.Millisecond)
+		network.Stop()
+		return network.linkStats(0, 1)
+	}
+	if run(7) != run(7) {
+		t.Error("same seed, different faults")
+	}
+	if run(7) == run(8) {
+		t.Error("different seeds, same faults")
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	var script string = `
+# comment
+0.5s drop 0.1
+1s   delay 1ms 5ms
+2s   partition 0,1 2
+3s   heal # the end
+`
+	schedule, err := ParseSchedule(strings.NewReader(script), Faults{Seed: 3, DuplicateRate: 0.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 4 {
+		t.Fatal(len(schedule), " steps")
+	}
+	if schedule[0].At != 500 * time.Millisecond || schedule[0].Faults.DropRate != 0.1 || schedule[0].Faults.DuplicateRate != 0.2 {
+		t.Error("step 0: ", schedule[0].Faults.String())
+	}
+	// the faults of a step include the ones of the previous steps
+	if schedule[1].Faults.DropRate != 0.1 || schedule[1].Faults.MaxDelay != 5 * time.Millisecond {
+		t.Error("step 1: ", schedule[1].Faults.String())
+	}
+	if len(schedule[2].Partition) != 2 || len(schedule[2].Partition[0]) != 2 || schedule[2].Faults != nil {
+		t.Error("step 2: ", schedule[2].Partition)
+	}
+	if !schedule[3].Heal {
+		t.Error("step 3 does not heal")
+	}
+	for _, bad := range []string{"1s", "x drop 0.1", "1s drop", "1s delay 1ms", "1s partition 0,a", "1s foo"} {
+		if _, err := ParseSchedule(strings.NewReader(bad), Faults{}); err == nil {
+			t.Error(bad, ": no error")
+		}
+	}
+}
@@ -0,0 +1,832 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+How-to run: 
+  go run ./cmd/drinking --algo=Rhee 2>&1  |tee /tmp/tmp.log
+or, as a regression, 100 runs with the seeds 1 to 100:
+  go run ./cmd/drinking --algo=Rhee --seed=1 --runs=100 --timeout=1m 2>/dev/null
+with Dijkstra's resource ordering as the dining philosophers subroutine:
+  go run ./cmd/drinking --algo=Rhee --dining=ordered 2>&1  |tee /tmp/tmp.log
+
+Parameters:
+- Number of nodes 
+- Number of iterations 
+- Workload: the resources of each request, the think and CS durations, see
+  the Workload package
+- Seed of the random choices of the nodes (waiting times and requested resources)
+- Dining philosophers subroutine, see the Dining package
+
+Protocol
+Each node is a user, that requests resources, and the resource manager (RM)
+of the resource with the same id. A request goes through these steps:
+1. the user enters the critical section of the dining philosophers subroutine
+   (dining CS), Chandy-Misra by default, and sends REPORT to the RMs of its
+   resources
+2. a RM receiving a REPORT becomes rm_critical and answers MARKED with the
+   occupied positions of its queue. The REPORT, RELEASE and ADV it receives
+   while rm_critical are kept in its queue of pending messages, and processed
+   in order once the SELECT is received
+3. with all the MARKED, the user selects the position following the highest
+   occupied one, sends SELECT to its RMs and leaves the dining CS. The user has
+   then the same position in all its queues
+4. when the position before the one of a user is empty, the RM sends DEC to
+   this user. With DEC from all its RMs, the user sends them ADV and moves one
+   position forward in all its queues
+5. a RM sends GRANT to the user at position 0. With all the GRANT, the user
+   enters its CS, then sends RELEASE to its RMs that free the position 0
+A node checks when it enters its CS that no other node uses its resources,
+the run ends on a Fatal otherwise.
+*/ 
+
+/*
+    Go implementation of Injong Rhee mutual exclusion algorithm, uses a Dining Philosophers algorithm as a subroutine
+    Algorithm by Injong Rhee 1995
+
+References : 
+ * https://doi.org/10.1109/ICDCS.1995.500015: Injong Rhee, "A fast distributed modular algorithm for resource allocation," Proceedings of 15th International Conference on Distributed Computing Systems, Vancouver, BC, Canada, 1995, pp. 161-168, doi: 10.1109/ICDCS.1995.500015.
+*/
+
+package Rhee
+
+/*
+  logrus package is used for logs: https://github.com/sirupsen/logrus
+*/
+import (
+	"bytes" // for go routine ID getGID
+	"encoding/gob"
+	"fmt"
+	"runtime" // for debugging purpose
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"drinking/Dining"
+	"drinking/Stats"
+	"drinking/Topology"
+	"drinking/Trace"
+	"drinking/Workload"
+	log "github.com/sirupsen/logrus"
+)
+
+/* global variable declaration */
+var NB_MSG            int = 0
+var CURRENT_ITERATION int = 0
+
+var Nodes []Node
+
+// Message types
+var REQ_TYPE     int = 0
+var REP_TYPE     int = 1
+var REPORT_TYPE  int = 2
+var SELECT_TYPE  int = 3
+var RELEASE_TYPE int = 4
+var MARKED_TYPE  int = 5
+var GRANT_TYPE   int = 6
+var ADV_TYPE     int = 7
+var DEC_TYPE     int = 8
+
+var DINING_TYPE  int = 9 // message of the dining philosophers subroutine
+
+var EMPTY int = -1
+
+var MESSAGE_NAMES = []string{"REQ", "REP", "REPORT", "SELECT", "RELEASE", "MARKED", "GRANT", "ADV", "DEC", "DINING"}
+
+var Logger = log.New()
+
+// Subroutine creates the dining philosophers layer of each node, Chandy-Misra
+// by default, see the Dining package
+var Subroutine Dining.New = Dining.NewChandyMisra
+
+// Tracer records the execution when set, see the Trace package
+var Tracer *Trace.Tracer
+
+// Recorder records the statistics of the run when set, see the Stats package
+var Recorder *Stats.Recorder
+
+// globalMutex protects the variables shared by all the nodes: NB_MSG,
+// CURRENT_ITERATION and resourceUser
+var globalMutex sync.Mutex
+
+// resourceUser is the node in CS using each resource, to check mutual exclusion
+var resourceUser map[int]int
+
+type Request struct {
+	RequesterNodeId int
+	RequestId      int
+	MessageType    int
+	ResourceId     []int
+	Occupied       []int
+	Position       int
+	TraceId        int
+	Dining         Dining.Message // of DINING_TYPE
+}
+
+type Node struct {
+	Id                   int
+	NbIterations         int
+	InDiningCS           bool
+	InRheeCS             bool
+	// From paper: variables for resource managers
+	Rm_critical          bool
+	Occupant             map[int] int // position => user, empty positions are not in the map
+	Has_dec_sent         map[int] bool // true when DEC was sent to the occupant of the position
+	// From paper: variables for users
+	Req_report           bool
+	// variables for implementation
+	Messages             []chan bytes.Buffer
+	NbRheeCS             int
+	RequestIdCounter     int
+	currentRequest       Request
+	position             int // position of the current request in the queues of its RMs
+	nbMarkedRcv          int
+	nbGrantRcv           int
+	nbDecRcv             int
+	PositionSelected     map[int]int
+	dining               Dining.Subroutine
+	workload             *Workload.Generator
+	csDuration           time.Duration // of the current request
+	pendingRequests      []Request // REPORT, RELEASE and ADV received while rm_critical
+	mutex                sync.Mutex // held while a message is handled
+	stop                 chan bool
+	stopped              bool
+}
+
+////////////////////////////////////////////////////////////
+// Debug functions
+////////////////////////////////////////////////////////////
+// get ID of go routine
+func getGID() uint64 {
+    b := make([]byte, 64)
+    b = b[:runtime.Stack(b, false)]
+    b = bytes.TrimPrefix(b, []byte("goroutine "))
+    b = b[:bytes.IndexByte(b, ' ')]
+    n, _ := strconv.ParseUint(string(b), 10, 64)
+    return n
+}
+
+// Debug function, called with the mutex of the node held
+func (n *Node) display() {
+	Logger.Debug("!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!  N#", n.Id, ", inDiningCS=", n.InDiningCS, ", inRheeCS=", n.InRheeCS, ", rm_critical=", n.Rm_critical, ", position=", n.position)
+	for p, o := range n.Occupant {
+		Logger.Debug("!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!   occupant[", p, "] =", "Node #", o)
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Utility functions
+////////////////////////////////////////////////////////////
+func UnmarshalRequest(b bytes.Buffer, request *Request) error {
+	dec := gob.NewDecoder(&b)
+
+	error := dec.Decode(request)
+
+	return error
+}
+
+func MarshalRequest(request Request) (bytes.Buffer, error) {
+	var buffer bytes.Buffer        
+	enc := gob.NewEncoder(&buffer) 
+	error := enc.Encode(request)
+
+	return buffer, error
+}
+
+func messageName(messageType int) string {
+	if messageType >= 0 && messageType < len(MESSAGE_NAMES) {
+		return MESSAGE_NAMES[messageType]
+	}
+	return strconv.Itoa(messageType)
+}
+
+// iterationsDone returns true when the nodes entered their CS nbIterations times in total
+func iterationsDone(nbIterations int) bool {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return CURRENT_ITERATION >= nbIterations
+}
+
+// traceState records the variables of the node as a user and as a resource manager
+func (n *Node) traceState() {
+	if Tracer == nil {
+		return
+	}
+	var state = map[string]interface{}{
+		"InDiningCS": n.InDiningCS,
+		"InRheeCS": n.InRheeCS,
+		"Rm_critical": n.Rm_critical,
+		"Occupant": n.Occupant,
+	}
+	Tracer.State(n.Id, state)
+}
+
+func (r *Request) String() string {
+	var val string
+	var res string = ""
+	var occ string = ""
+
+	for i := 0; i < len(r.ResourceId); i ++ {
+		res +=  strconv.Itoa(r.ResourceId[i]) + ", "
+	}
+
+	for i := 0; i < len(r.Occupied); i ++ {
+		occ +=  strconv.Itoa(r.Occupied[i]) + ", "
+	}
+
+	val = fmt.Sprintf("Request #%d, requester node=%d, position=%d messageType=%d, resources={%s}, occupied={%s}",
+		r.RequestId,
+		r.RequesterNodeId,
+		r.Position,
+		r.MessageType,
+		res,
+		occ)
+	return val
+}
+
+////////////////////////////////////////////////////////////
+// Node class
+////////////////////////////////////////////////////////////
+func (n *Node) String() string {
+	var val string
+	val = fmt.Sprintf("Node #%d, inDiningCS=%t, inRheeCS=%t",
+		n.Id,
+		n.InDiningCS,
+		n.InRheeCS)
+	return val
+}
+
+// occupant returns the user at position p in the queue of the RM, EMPTY if there is none
+func (n *Node) occupant(p int) int {
+	if o, ok := n.Occupant[p]; ok {
+		return o
+	}
+	return EMPTY
+}
+
+// transmit sends content to dst, unless the node is stopped before dst receives it
+func (n *Node) transmit(dst int, content bytes.Buffer) {
+	select {
+	case n.Messages[dst] <- content:
+		globalMutex.Lock()
+		NB_MSG ++
+		globalMutex.Unlock()
+	case <-n.stop:
+	}
+}
+
+// EnterDiningCS is called by the dining philosophers subroutine once no
+// other node is in its dining CS
+func (n *Node) EnterDiningCS(request Request) {
+	Logger.Info("Node #", n.Id, " ######################### Node.EnterDiningCS, req=", request.String())
+	n.InDiningCS = true
+	n.traceState()
+	n.display()
+}
+
+func (n *Node) ExecuteDiningCSCode(request Request) {
+	Logger.Debug("Node #", n.Id, " ######################### Node.executeDiningCSCode")
+
+	if n.Req_report == false {
+		n.Req_report = true
+		n.PositionSelected[request.RequestId] = 0
+		n.nbMarkedRcv = 0
+		for k := 0; k < len(request.ResourceId); k++ {
+			go n.sendReport(request.ResourceId[k], request)
+		}
+	}	
+}
+
+func (n *Node) ReleaseDiningCS(request Request) {
+	Logger.Info("Node #", n.Id," Node.releaseDiningCS #########################, req=", request.String())
+	n.InDiningCS = false
+	n.dining.Release()
+	n.traceState()
+	n.display()
+}
+
+func (n *Node) EnterCS(request Request) {
+	Logger.Info("Node #", n.Id, " ######################### Node.EnterCS")
+	globalMutex.Lock()
+	for _, r := range request.ResourceId {
+		if user, ok := resourceUser[r]; ok {
+			Logger.Fatal("Node #", n.Id, " enters CS with resource #", r, " used by Node #", user)
+		}
+		resourceUser[r] = n.Id
+	}
+	CURRENT_ITERATION ++
+	globalMutex.Unlock()
+	n.InRheeCS = true
+	n.NbRheeCS ++
+	Recorder.EnterCS(n.Id)
+	Tracer.EnterCS(n.Id)
+	n.traceState()
+	n.display()
+}
+
+func (n *Node) ExecuteCSCode(request Request) {
+	Logger.Debug("Node #", n.Id, " ######################### Node.ExecuteCSCode")
+	// Logger.Debug(n)
+	time.Sleep(n.csDuration)
+}
+
+func (n *Node) ReleaseCS(request Request) {
+	Logger.Info("Node #", n.Id," Node.ReleaseCS #########################, req=", request.String())
+	globalMutex.Lock()
+	for _, r := range request.ResourceId {
+		delete(resourceUser, r)
+	}
+	globalMutex.Unlock()
+	n.InRheeCS = false
+	Tracer.ReleaseCS(n.Id)
+	n.traceState()
+	n.display()
+	for i := 0; i < len(request.ResourceId); i++ {
+		go n.sendRelease(request.ResourceId[i], request)
+	}
+}
+
+// executeCS runs the CS of request outside of the routine receiving the
+// messages, then requests the next CS
+func (n *Node) executeCS(request Request) {
+	n.mutex.Lock()
+	if n.stopped {
+		n.mutex.Unlock()
+		return
+	}
+	n.EnterCS(request)
+	n.mutex.Unlock()
+
+	n.ExecuteCSCode(request)
+
+	n.mutex.Lock()
+	n.ReleaseCS(request)
+	n.mutex.Unlock()
+	n.requestCS()
+}
+
+func (n *Node) buildRequest(resources []int) Request {
+	var request Request
+	request.MessageType = REQ_TYPE
+	request.RequesterNodeId = n.Id
+	request.RequestId = n.RequestIdCounter
+	n.RequestIdCounter ++
+	request.ResourceId = resources
+	return request
+}
+
+// adjust_queue sends DEC to the occupants of the RM queue that can move one position forward
+func (n *Node) adjust_queue(request Request) {
+	Logger.Debug("Node #", n.Id,"** Node.adjust_queue **");
+	for p, o := range n.Occupant {
+		if p > 0 && n.occupant(p - 1) == EMPTY && n.Has_dec_sent[p] == false {
+			n.Has_dec_sent[p] = true
+			go n.sendDec(p, o, request)
+		}
+	}
+	n.display()
+}
+
+func (n *Node) sendReport(dst int, request Request) {
+	request.MessageType = REPORT_TYPE
+	request.RequesterNodeId = n.Id
+
+	request.TraceId = Tracer.NextId()
+	content, err := MarshalRequest(request)
+	if err != nil {
+		Logger.Fatal("sendReport", err)
+	}			
+	// Logger.Debug("Node #", n.Id, ", send REPORT #", request.RequestId, ":", content, " to Node #", dst, ", routine #", getGID())
+	Logger.Debug("Node #", n.Id, ", send REPORT #", request.RequestId, " to Node #", dst, ", routine #", getGID())
+	Tracer.Send(n.Id, dst, messageName(request.MessageType), request.TraceId)
+	n.transmit(dst, content)
+}
+
+func (n *Node) sendSelect(position int, dst int, request Request) {
+	request.MessageType = SELECT_TYPE
+	request.RequesterNodeId = n.Id
+	request.Position = position
+
+	request.TraceId = Tracer.NextId()
+	content, err := MarshalRequest(request)
+	if err != nil {
+		Logger.Fatal("sendSelect", err)
+	}			
+	// Logger.Debug("Node #", n.Id, ", send SELECT #", request.RequestId, " with position=", position, ":", content, " to Node #", dst, ", routine #", getGID())
+	Logger.Debug("Node #", n.Id, ", send SELECT #", request.RequestId, " with position=", position, " to Node #", dst, ", routine #", getGID())
+	Tracer.Send(n.Id, dst, messageName(request.MessageType), request.TraceId)
+	n.transmit(dst, content)
+}
+
+func (n *Node) sendRelease(dst int, request Request) {
+	request.MessageType = RELEASE_TYPE
+	request.RequesterNodeId = n.Id
+
+	request.TraceId = Tracer.NextId()
+	content, err := MarshalRequest(request)
+	if err != nil {
+		Logger.Fatal("sendRelease", err)
+	}			
+	// Logger.Debug("Node #", n.Id, ", send RELEASE #", request.RequestId, ":", content, " to Node #", dst, ", routine #", getGID())
+	Logger.Debug("Node #", n.Id, ", send RELEASE #", request.RequestId, " to Node #", dst, ", routine #", getGID())
+	Tracer.Send(n.Id, dst, messageName(request.MessageType), request.TraceId)
+	n.transmit(dst, content)
+}
+
+func (n *Node) sendMarked(occupied []int, dst int, request Request) {
+	request.MessageType = MARKED_TYPE
+	request.RequesterNodeId = n.Id
+	request.Occupied = occupied
+
+	request.TraceId = Tracer.NextId()
+	content, err := MarshalRequest(request)
+	if err != nil {
+		Logger.Fatal("sendMarked", err)
+	}			
+	// Logger.Debug("Node #", n.Id, ", send MARKED #", request.RequestId, ":", content, " to Node #", dst, " with occupied", occupied, ", routine #", getGID())	
+	Logger.Debug("Node #", n.Id, ", send MARKED #", request.RequestId, " to Node #", dst, " with occupied", occupied, ", routine #", getGID())	
+	Tracer.Send(n.Id, dst, messageName(request.MessageType), request.TraceId)
+	n.transmit(dst, content)
+}
+
+func (n *Node) sendGrant(dst int, request Request) {
+	request.MessageType = GRANT_TYPE
+	request.RequesterNodeId = n.Id
+
+	request.TraceId = Tracer.NextId()
+	content, err := MarshalRequest(request)
+	if err != nil {
+		Logger.Fatal("sendGrant", err)
+	}			
+	// Logger.Debug("Node #", n.Id, ", send GRANT #", request.RequestId, ":", content, " to Node #", dst, ", routine #", getGID())
+	Logger.Debug("Node #", n.Id, ", send GRANT #", request.RequestId, " to Node #", dst, ", routine #", getGID())
+	Tracer.Send(n.Id, dst, messageName(request.MessageType), request.TraceId)
+	n.transmit(dst, content)
+}
+
+func (n *Node) sendAdv(position int, dst int, request Request) {
+	request.MessageType = ADV_TYPE
+	request.RequesterNodeId = n.Id
+	request.Position = position
+
+	request.TraceId = Tracer.NextId()
+	content, err := MarshalRequest(request)
+	if err != nil {
+		Logger.Fatal("sendAdv", err)
+	}			
+	// Logger.Debug("Node #", n.Id, ", send ADV #", request.RequestId, " with position=", position, ":", content, " to Node #", dst, ", routine #", getGID())
+	Logger.Debug("Node #", n.Id, ", send ADV #", request.RequestId, " with position=", position, " to Node #", dst, ", routine #", getGID())
+	Tracer.Send(n.Id, dst, messageName(request.MessageType), request.TraceId)
+	n.transmit(dst, content)
+}
+
+func (n *Node) sendDec(p int, dst int, request Request) {
+	request.MessageType = DEC_TYPE
+	request.RequesterNodeId = n.Id
+	request.Position = p
+
+	request.TraceId = Tracer.NextId()
+	content, err := MarshalRequest(request)
+	if err != nil {
+		Logger.Fatal("sendDec", err)
+	}			
+	// Logger.Debug("Node #", n.Id, ", send DEC #", request.RequestId, ":", content, " to Node #", dst, ", routine #", getGID())
+	Logger.Debug("Node #", n.Id, ", send DEC #", request.RequestId, " to Node #", dst, " with position #", p, ", routine #", getGID())
+	Tracer.Send(n.Id, dst, messageName(request.MessageType), request.TraceId)
+	n.transmit(dst, content)
+}
+
+// handlePendingRequests processes in order the messages kept while the RM
+// was rm_critical, until one of them makes it rm_critical again
+func (n *Node) handlePendingRequests() {
+	for len(n.pendingRequests) > 0 && n.Rm_critical == false {
+		r :=  n.pendingRequests[0]
+		n.pendingRequests = n.pendingRequests[1:]
+
+		Logger.Info("Node #", n.Id,"** handlePendingRequests[0] **, req=", r.String());
+		switch r.MessageType {
+		case REPORT_TYPE:
+			n.receiveReport(r)
+		case ADV_TYPE:
+			n.receiveAdv(r)
+		case RELEASE_TYPE:
+			n.receiveRelease(r)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Resource manager
+////////////////////////////////////////////////////////////
+func (n *Node) receiveReport(request Request) {
+	Logger.Debug("Node #", n.Id,"** Node.receiveReport **, req=", request.String());
+	if n.Rm_critical == true {
+		Logger.Info("Node #", n.Id, " already rm_critical, REPORT kept pending")
+		n.pendingRequests = append(n.pendingRequests, request)
+		return
+	}
+	n.Rm_critical = true
+	Logger.Info("Node #", n.Id, ", rm_critical false => true")
+	n.traceState()
+	var occupied []int
+	for p := range n.Occupant {
+		occupied = append(occupied, p)
+	}
+	sort.Ints(occupied)
+	go n.sendMarked(occupied, request.RequesterNodeId, request)
+}
+
+func (n *Node) receiveSelect(request Request) {
+	Logger.Debug("Node #", n.Id,"** Node.receiveSelect **, req=", request.String());
+	if n.Rm_critical == false {
+		Logger.Fatal("Node #", n.Id,"** Node.receiveSelect but not rm_critical")
+	}
+	if n.occupant(request.Position) != EMPTY {
+		Logger.Fatal("Node #", n.Id,"** Node.receiveSelect, position ", request.Position, " already occupied by Node #", n.occupant(request.Position))
+	}
+	Logger.Info("Node #", n.Id, ", rm_critical ", n.Rm_critical, " => false")
+	n.Rm_critical = false
+	n.Occupant[request.Position] = request.RequesterNodeId
+	n.Has_dec_sent[request.Position] = false
+	n.traceState()
+	if request.Position == 0 {
+		go n.sendGrant(request.RequesterNodeId, request)
+	}
+	n.adjust_queue(request)
+	n.handlePendingRequests()
+}
+
+func (n *Node) receiveRelease(request Request) {
+	Logger.Debug("Node #", n.Id, "** Node.receiveRelease **");
+	if n.Rm_critical == true {
+		Logger.Info("Node #", n.Id, ", receiveRelease but is rm_critical, RELEASE kept pending")
+		n.pendingRequests = append(n.pendingRequests, request)
+		return
+	}
+	if n.occupant(0) != request.RequesterNodeId {
+		Logger.Fatal("Node #", n.Id, "** Node.receiveRelease from Node #", request.RequesterNodeId, " but position 0 is occupied by Node #", n.occupant(0))
+	}
+	delete(n.Occupant, 0)
+	delete(n.Has_dec_sent, 0)
+	n.traceState()
+	n.adjust_queue(request)
+}
+
+func (n *Node) receiveAdv(request Request) {
+	Logger.Debug("Node #", n.Id,"** Node.receiveAdv **");
+	if n.Rm_critical == true {
+		Logger.Info("Node #", n.Id, ", receiveAdv but is rm_critical, ADV kept pending")
+		n.pendingRequests = append(n.pendingRequests, request)
+		return 
+	}
+	// DEC was only sent because position p - 1 was empty, and new users
+	// select positions after the occupied ones, so it is still empty
+	var p int = request.Position
+	if n.occupant(p) != request.RequesterNodeId || n.occupant(p - 1) != EMPTY {
+		Logger.Fatal("Node #", n.Id, "** Node.receiveAdv from Node #", request.RequesterNodeId, " for position ", p, ", occupant[", p, "]=", n.occupant(p), ", occupant[", p - 1, "]=", n.occupant(p - 1))
+	}
+	delete(n.Occupant, p)
+	delete(n.Has_dec_sent, p)
+	n.Occupant[p - 1] = request.RequesterNodeId
+	n.Has_dec_sent[p - 1] = false
+	n.traceState()
+	if p - 1 == 0 {
+		go n.sendGrant(request.RequesterNodeId, request)
+	}
+	n.adjust_queue(request)
+}
+
+////////////////////////////////////////////////////////////
+// User
+////////////////////////////////////////////////////////////
+func (n *Node) receiveMarked(request Request) {
+	var position_selected int = 0;
+	n.nbMarkedRcv ++
+	for i := 0; i < len(request.Occupied); i ++ {
+		if request.Occupied[i] + 1 > position_selected {
+			position_selected = request.Occupied[i] + 1
+		}
+	}
+	if position_selected > n.PositionSelected[request.RequestId] {
+		n.PositionSelected[request.RequestId] = position_selected
+	}
+	if n.nbMarkedRcv == len(n.currentRequest.ResourceId) {
+		Logger.Debug("Node #", n.Id, " ALL MARKED RECEIVED")
+		n.position = n.PositionSelected[request.RequestId]
+		delete(n.PositionSelected, request.RequestId)
+		n.nbDecRcv = 0
+		n.nbGrantRcv = 0
+		for k := 0; k < len(n.currentRequest.ResourceId); k++ {
+			go n.sendSelect(n.position, n.currentRequest.ResourceId[k], n.currentRequest)
+		}
+		n.Req_report = false
+		n.ReleaseDiningCS(n.currentRequest)
+	} else {
+		Logger.Debug("Node #", n.Id, " is still expecting ", len(n.currentRequest.ResourceId) - n.nbMarkedRcv, " MARKED")
+	}
+}
+
+func (n *Node) receiveGrant(request Request) {
+	Logger.Debug("Node #", n.Id,"** Node.receiveGrant **");
+	n.nbGrantRcv ++
+	if n.nbGrantRcv == len(n.currentRequest.ResourceId) {
+		Logger.Debug("Node #", n.Id, " ALL GRANT RECEIVED")
+		go n.executeCS(n.currentRequest)
+	} else {
+		Logger.Debug("Node #", n.Id, " is still expecting ", len(n.currentRequest.ResourceId) - n.nbGrantRcv, " GRANT")
+	}
+}
+
+func (n *Node) receiveDec(request Request) {
+	Logger.Debug("Node #", n.Id,"** Node.receiveDec ** req=", request.String());
+	if request.Position != n.position {
+		Logger.Fatal("Node #", n.Id, "** Node.receiveDec for position ", request.Position, " but is at position ", n.position)
+	}
+	n.nbDecRcv ++
+	if n.nbDecRcv == len(n.currentRequest.ResourceId) {
+		n.nbDecRcv = 0
+		n.position --
+		for k := 0; k < len(n.currentRequest.ResourceId); k++ {
+			go n.sendAdv(request.Position, n.currentRequest.ResourceId[k], n.currentRequest)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Dining philosophers subroutine
+////////////////////////////////////////////////////////////
+func (n *Node) RequestDiningCS(request Request) {
+	Logger.Info("Node #", n.Id, " RequestDiningCS")
+	n.dining.Request()
+	Logger.Info("Node #", n.Id," END - RequestDiningCS")	
+}
+
+// sendDining sends a message of the dining philosophers subroutine to dst
+func (n *Node) sendDining(dst int, message Dining.Message) {
+	var request Request
+	request.MessageType = DINING_TYPE
+	request.RequesterNodeId = n.Id
+	request.Dining = message
+
+	request.TraceId = Tracer.NextId()
+	content, err := MarshalRequest(request)
+	if err != nil {
+		Logger.Fatal("sendDining", err)
+	}			
+	Logger.Info("Node #", n.Id, ", send ", Dining.MessageName(message.Type), " to Node #", dst)	
+	Tracer.Send(n.Id, dst, Dining.MessageName(message.Type), request.TraceId)
+	n.transmit(dst, content)
+}
+
+func (n *Node) rcv() {	
+	Logger.Debug("Node #", n.Id," rcv", ", routine #", getGID())	
+	for {
+		select {
+		case <-n.stop:
+			Logger.Debug("Node #", n.Id, " end rcv")
+			return
+		case msg := <-n.Messages[n.Id]:
+			var request Request
+			err := UnmarshalRequest(msg, &request)
+			if err != nil {
+				Logger.Fatal("rcv", err)
+			}			
+			Logger.Debug(request.String())
+			var requester = request.RequesterNodeId
+			var name string = messageName(request.MessageType)
+			if request.MessageType == DINING_TYPE {
+				name = Dining.MessageName(request.Dining.Type)
+			}
+			Tracer.Receive(n.Id, requester, name, request.TraceId)
+			n.mutex.Lock()
+			if (request.MessageType == REPORT_TYPE) {
+				Logger.Info("Node #", n.Id, ", received REPORT from Node #", requester)
+				n.receiveReport(request)
+			} else if (request.MessageType == SELECT_TYPE) {
+				Logger.Info("Node #", n.Id, ", received SELECT from Node #", requester)
+				n.receiveSelect(request)
+			} else if (request.MessageType == RELEASE_TYPE) {
+				Logger.Info("Node #", n.Id, ", received RELEASE from Node #", requester)
+				n.receiveRelease(request)
+			} else if (request.MessageType == MARKED_TYPE) {
+				Logger.Info("Node #", n.Id, ", received MARKED from Node #", requester)
+				n.receiveMarked(request)
+			} else if (request.MessageType == GRANT_TYPE) {
+				Logger.Info("Node #", n.Id, ", received GRANT from Node #", requester)
+				n.receiveGrant(request)
+			} else if (request.MessageType == ADV_TYPE) {
+				Logger.Info("Node #", n.Id, ", received ADV from Node #", requester)
+				n.receiveAdv(request)
+			} else if (request.MessageType == DEC_TYPE) {
+				Logger.Info("Node #", n.Id, ", received DEC from Node #", requester)
+				n.receiveDec(request)
+			} else if (request.MessageType == DINING_TYPE) {
+				Logger.Info("Node #", n.Id, ", received ", name, " from Node #", requester)
+				n.dining.Receive(request.Dining)
+			} else {
+				Logger.Fatal("Unknown message type=", request.MessageType)
+			}
+			n.mutex.Unlock()
+		}
+	}
+}
+
+func (n *Node) requestCS() {
+	Logger.Info("Node #", n.Id, " requestCS")
+	if iterationsDone(n.NbIterations) {
+		return
+	}
+
+	var next Workload.Request = n.workload.Next()
+	time.Sleep(next.Think)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.stopped || iterationsDone(n.NbIterations) {
+		return
+	}
+	n.currentRequest = n.buildRequest(next.Resources)
+	n.csDuration = next.CS
+	Recorder.Request(n.Id)
+	
+	var requester = n.currentRequest.RequesterNodeId
+	var res = n.currentRequest.ResourceId
+	Logger.Debug("Node #", n.Id, "<-REQ#", n.currentRequest.RequestId, ", Requester #", requester, ", nb of res:", len(res), " res ", res)
+	n.RequestDiningCS(n.currentRequest)
+
+	Logger.Info("Node #", n.Id," END requestCS")	
+}
+
+func (n *Node) Rhee(wg *sync.WaitGroup) {
+	Logger.Info("Node #", n.Id)
+
+	go n.requestCS()
+	go n.rcv()
+	for {
+		time.Sleep(100 * time.Millisecond)
+		if iterationsDone(n.NbIterations) {
+			break
+		}
+	}
+
+	Logger.Info("Node #", n.Id," END after ", n.NbIterations," CS entries")	
+	wg.Done()
+}
+
+// Stop ends the routines of all the nodes once the run is over, so that a new
+// run can be started with Init
+func Stop() {
+	for i := 0; i < len(Nodes); i++ {
+		Nodes[i].mutex.Lock()
+		Nodes[i].stopped = true
+		close(Nodes[i].stop)
+		Nodes[i].mutex.Unlock()
+	}
+}
+
+func Init(nbNodes int, nbIterations int, workload *Workload.Config, seed int64) {
+	Logger.SetLevel(log.DebugLevel)
+	// Logger.SetLevel(log.InfoLevel)
+	Logger.Print("Rhee.Init, seed ", seed, ", workload ", workload)	
+	if workload.NbResources != nbNodes {
+		Logger.Fatal("The workload must have one resource per node, ", nbNodes)
+	}
+	// any two nodes may request the same resource, they are all neighbors in
+	// the dining philosophers subroutine
+	var graph *Topology.Graph = Topology.Complete(nbNodes)
+
+	// messages of a previous run may still be counted
+	globalMutex.Lock()
+	NB_MSG = 0
+	CURRENT_ITERATION = 0
+	resourceUser = make(map[int]int)
+	globalMutex.Unlock()
+	Nodes = make([]Node, nbNodes)
+	var messages = make([]chan bytes.Buffer, nbNodes)
+
+	Logger.Info("nb_process #", nbNodes)
+	
+	for i := 0; i < nbNodes; i++ {		
+		var n *Node = &Nodes[i]
+		n.Id = i
+		n.NbIterations = nbIterations
+		n.dining = Subroutine(i, graph, func(dst int, message Dining.Message) {
+			go n.sendDining(dst, message)
+		}, func() {
+			n.EnterDiningCS(n.currentRequest)
+			n.ExecuteDiningCSCode(n.currentRequest)
+		})
+		messages[i] = make(chan bytes.Buffer)
+		Nodes[i].RequestIdCounter = i * 100
+		Nodes[i].PositionSelected = make(map[int]int)
+		Nodes[i].Occupant = make(map[int]int)
+		Nodes[i].Has_dec_sent = make(map[int]bool)
+		Nodes[i].Rm_critical = false
+		Nodes[i].Req_report = false
+		Nodes[i].workload = workload.Generator(i, seed + int64(i))
+		Nodes[i].stop = make(chan bool)
+	}
+
+	for i := 0; i < nbNodes; i++ {
+		Nodes[i].Messages = messages
+	} 
+}
@@ -0,0 +1,59 @@
+package Rhee
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"drinking/internal/testutil"
+	"drinking/Stats"
+	"drinking/Workload"
+)
+
+// Regression over the seeds 1 to 50, as the --runs option of cmd/drinking:
+// every node must end, and a Fatal of the algorithm, such as two nodes in CS
+// with the same resource, fails the test instead of ending it
+func TestSeeds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("50 runs")
+	}
+	var nbNodes int = 5
+	var nbIterations int = 20
+	var nbFatal int = 0
+	var fatalMutex sync.Mutex
+	Logger.ExitFunc = func(int) {
+		fatalMutex.Lock()
+		nbFatal ++
+		fatalMutex.Unlock()
+	}
+	defer func() { Logger.ExitFunc = nil }()
+	defer func() { Tracer = nil }()
+	for seed := int64(1); seed <= 50; seed++ {
+		var workload *Workload.Config = testutil.NewWorkload(t, nbNodes, 3)
+		Init(nbNodes, nbIterations, workload, seed)
+		Recorder = Stats.New()
+		var tracer *testutil.Tracer = testutil.NewTracer()
+		Tracer = tracer.Tracer
+		var wg sync.WaitGroup
+		for i := 0; i < nbNodes; i++ {
+			wg.Add(1)
+			go Nodes[i].Rhee(&wg)
+		}
+		testutil.Wait(t, &wg, time.Minute)
+		Stop()
+		testutil.CheckExclusion(t, tracer, testutil.Requests(workload, seed))
+		var nbCS int = 0
+		for i := 0; i < nbNodes; i++ {
+			nbCS += Nodes[i].NbRheeCS
+		}
+		if nbCS < nbIterations {
+			t.Error("seed ", seed, ": ", nbCS, " CS entries instead of ", nbIterations)
+		}
+		fatalMutex.Lock()
+		var n int = nbFatal
+		fatalMutex.Unlock()
+		if n > 0 {
+			t.Fatal("seed ", seed, ": ", n, " Fatal, see the log")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package Stats
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// A CS entry counts the response time of the last request of its node only
+func TestRecorder(t *testing.T) {
+	var r *Recorder = New()
+	r.Request(0)
+	r.Request(1)
+	time.Sleep(20 * time.Millisecond)
+	r.EnterCS(0)
+	r.EnterCS(0) // without a request
+	r.EnterCS(2) // never requested
+	r.Request(0)
+	r.EnterCS(0)
+	if len(r.responses) != 2 {
+		t.Fatal(len(r.responses), " responses")
+	}
+	if r.responses[0] < 20 * time.Millisecond || r.responses[1] >= 20 * time.Millisecond {
+		t.Error("response times: ", r.responses)
+	}
+	var summary string = r.Summary(10)
+	if !strings.HasPrefix(summary, "2 CS entries in ") || !strings.HasSuffix(summary, ", 10 messages (5.00 per CS)") {
+		t.Error(summary)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var summary string = New().Summary(3)
+	if !strings.HasPrefix(summary, "0 CS entries in ") || !strings.Contains(summary, "response time mean 0s max 0s, 3 messages (0.00 per CS)") {
+		t.Error(summary)
+	}
+}
+
+// A nil recorder does nothing
+func TestNil(t *testing.T) {
+	var r *Recorder
+	r.Request(0)
+	r.EnterCS(0)
+	if r.Summary(1) != "" {
+		t.Error("summary of a nil recorder")
+	}
+}
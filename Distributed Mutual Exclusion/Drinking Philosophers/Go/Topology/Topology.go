@@ -0,0 +1,302 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Conflict graphs of the philosophers: two philosophers share a fork when they
+are neighbors in the graph.
+
+Topologies are written as:
+* complete: every philosopher is a neighbor of all the others
+* ring: philosopher #i is a neighbor of #i-1 and #i+1, modulo the number of
+  philosophers
+* grid or grid:RxC: the philosophers are on a grid of R rows and C columns, in
+  row order, each one is a neighbor of the ones above, below, left and right.
+  Without size the grid has ceil(sqrt(n)) columns and its last row may be
+  incomplete
+* random or random:P: each pair of philosophers are neighbors with probability
+  P, 0.5 by default
+* file:PATH: the edges are read from a file, one per line:
+    # philosopher philosopher
+    0 1
+    1 2
+
+Initial forks: the fork shared by two neighbors is given to the one with the
+lowest id, dirty. A dirty fork is sent to the neighbor asking for it, so the
+holder of a dirty fork has the lowest priority on the edge. Orienting every
+edge from the highest id to the lowest one gives an acyclic precedence graph,
+whatever the topology, as ids are totally ordered.
+*/
+
+package Topology
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Topology names
+var COMPLETE string = "complete"
+var RING     string = "ring"
+var GRID     string = "grid"
+var RANDOM   string = "random"
+var FILE     string = "file"
+
+var TOPOLOGIES = []string{COMPLETE, RING, GRID, RANDOM, FILE}
+
+var DEFAULT_EDGE_PROBABILITY float64 = 0.5
+
+type Graph struct {
+	Name      string
+	NbNodes   int
+	Neighbors [][]int // sorted ids of the neighbors of each node
+}
+
+// New returns a graph of nbNodes nodes without edges
+func New(name string, nbNodes int) *Graph {
+	var g Graph
+	g.Name = name
+	g.NbNodes = nbNodes
+	g.Neighbors = make([][]int, nbNodes)
+	return &g
+}
+
+func (g *Graph) String() string {
+	return fmt.Sprintf("%s, %d nodes, %d edges", g.Name, g.NbNodes, len(g.Edges()))
+}
+
+func (g *Graph) HasEdge(i int, j int) bool {
+	var k int = sort.SearchInts(g.Neighbors[i], j)
+	return k < len(g.Neighbors[i]) && g.Neighbors[i][k] == j
+}
+
+// AddEdge adds an edge between i and j, adding it twice has no effect
+func (g *Graph) AddEdge(i int, j int) error {
+	if i < 0 || i >= g.NbNodes || j < 0 || j >= g.NbNodes {
+		return fmt.Errorf("edge %d-%d: ids must be between 0 and %d", i, j, g.NbNodes - 1)
+	}
+	if i == j {
+		return fmt.Errorf("edge %d-%d: a node cannot be its own neighbor", i, j)
+	}
+	if g.HasEdge(i, j) {
+		return nil
+	}
+	g.Neighbors[i] = append(g.Neighbors[i], j)
+	sort.Ints(g.Neighbors[i])
+	g.Neighbors[j] = append(g.Neighbors[j], i)
+	sort.Ints(g.Neighbors[j])
+	return nil
+}
+
+// Edges returns the edges of the graph, the lowest id first
+func (g *Graph) Edges() [][2]int {
+	var edges [][2]int
+	for i := 0; i < g.NbNodes; i++ {
+		for _, j := range g.Neighbors[i] {
+			if i < j {
+				edges = append(edges, [2]int{i, j})
+			}
+		}
+	}
+	return edges
+}
+
+// InitialHolder returns the neighbor holding the fork of the edge i-j at the start
+func InitialHolder(i int, j int) int {
+	if i < j {
+		return i
+	}
+	return j
+}
+
+func Complete(nbNodes int) *Graph {
+	var g *Graph = New(COMPLETE, nbNodes)
+	for i := 0; i < nbNodes; i++ {
+		for j := i + 1; j < nbNodes; j++ {
+			g.AddEdge(i, j)
+		}
+	}
+	return g
+}
+
+func Ring(nbNodes int) *Graph {
+	var g *Graph = New(RING, nbNodes)
+	for i := 0; i < nbNodes && nbNodes > 1; i++ {
+		g.AddEdge(i, (i + 1) % nbNodes)
+	}
+	return g
+}
+
+// Grid returns a grid of rows * cols nodes, the last row has only the nodes below nbNodes
+func Grid(nbNodes int, rows int, cols int) *Graph {
+	var g *Graph = New(fmt.Sprintf("%s:%dx%d", GRID, rows, cols), nbNodes)
+	for i := 0; i < nbNodes; i++ {
+		if (i + 1) % cols != 0 && i + 1 < nbNodes {
+			g.AddEdge(i, i + 1)
+		}
+		if i + cols < nbNodes {
+			g.AddEdge(i, i + cols)
+		}
+	}
+	return g
+}
+
+// Random returns a graph where each edge exists with the probability p
+func Random(nbNodes int, p float64, r *rand.Rand) *Graph {
+	var g *Graph = New(fmt.Sprintf("%s:%g", RANDOM, p), nbNodes)
+	for i := 0; i < nbNodes; i++ {
+		for j := i + 1; j < nbNodes; j++ {
+			if r.Float64() < p {
+				g.AddEdge(i, j)
+			}
+		}
+	}
+	return g
+}
+
+// ReadEdgeList reads a graph of nbNodes nodes, one edge per line
+func ReadEdgeList(r io.Reader, name string, nbNodes int) (*Graph, error) {
+	var g *Graph = New(name, nbNodes)
+	var scanner = bufio.NewScanner(r)
+	var line int = 0
+	for scanner.Scan() {
+		line ++
+		var text string = scanner.Text()
+		if k := strings.Index(text, "#"); k >= 0 {
+			text = text[:k]
+		}
+		var fields []string = strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected 2 ids, got %q", line, scanner.Text())
+		}
+		i, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		j, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if err = g.AddEdge(i, j); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return g, scanner.Err()
+}
+
+// Distances returns the distance in edges of every node to node from, -1 for
+// the nodes that cannot be reached
+func (g *Graph) Distances(from int) []int {
+	var distances = make([]int, g.NbNodes)
+	for i := 0; i < g.NbNodes; i++ {
+		distances[i] = -1
+	}
+	distances[from] = 0
+	var queue = []int{from}
+	for len(queue) > 0 {
+		var i int = queue[0]
+		queue = queue[1:]
+		for _, j := range g.Neighbors[i] {
+			if distances[j] == -1 {
+				distances[j] = distances[i] + 1
+				queue = append(queue, j)
+			}
+		}
+	}
+	return distances
+}
+
+// Color returns a color for each node such that neighbors have distinct
+// colors, and the number of colors. The nodes are colored greedily by
+// decreasing degree (Welsh-Powell), each one with the lowest color not used by
+// its neighbors, so there are at most the maximum degree + 1 colors
+func (g *Graph) Color() ([]int, int) {
+	var order = make([]int, g.NbNodes)
+	for i := 0; i < g.NbNodes; i++ {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return len(g.Neighbors[order[a]]) > len(g.Neighbors[order[b]])
+	})
+	var colors = make([]int, g.NbNodes)
+	for i := 0; i < g.NbNodes; i++ {
+		colors[i] = -1
+	}
+	var nbColors int = 0
+	for _, i := range order {
+		var used = make([]bool, len(g.Neighbors[i]) + 1)
+		for _, j := range g.Neighbors[i] {
+			if colors[j] >= 0 && colors[j] < len(used) {
+				used[colors[j]] = true
+			}
+		}
+		var c int = 0
+		for used[c] {
+			c ++
+		}
+		colors[i] = c
+		if c + 1 > nbColors {
+			nbColors = c + 1
+		}
+	}
+	return colors, nbColors
+}
+
+// Parse returns the graph of nbNodes nodes described by spec, the random
+// graphs are drawn with seed
+func Parse(spec string, nbNodes int, seed int64) (*Graph, error) {
+	var name, arg string = spec, ""
+	if k := strings.Index(spec, ":"); k >= 0 {
+		name, arg = spec[:k], spec[k + 1:]
+	}
+	switch strings.ToLower(name) {
+	case COMPLETE:
+		return Complete(nbNodes), nil
+	case RING:
+		return Ring(nbNodes), nil
+	case GRID:
+		var cols int = int(math.Ceil(math.Sqrt(float64(nbNodes))))
+		if cols == 0 {
+			cols = 1
+		}
+		var rows int = (nbNodes + cols - 1) / cols
+		if arg != "" {
+			if _, err := fmt.Sscanf(arg, "%dx%d", &rows, &cols); err != nil {
+				return nil, fmt.Errorf("grid size %q, expected RxC: %v", arg, err)
+			}
+			if rows * cols != nbNodes {
+				return nil, fmt.Errorf("grid of %dx%d nodes for %d nodes", rows, cols, nbNodes)
+			}
+		}
+		return Grid(nbNodes, rows, cols), nil
+	case RANDOM:
+		var p float64 = DEFAULT_EDGE_PROBABILITY
+		if arg != "" {
+			var err error
+			if p, err = strconv.ParseFloat(arg, 64); err != nil || p < 0 || p > 1 {
+				return nil, fmt.Errorf("edge probability %q must be between 0 and 1", arg)
+			}
+		}
+		return Random(nbNodes, p, rand.New(rand.NewSource(seed))), nil
+	case FILE:
+		file, err := os.Open(arg)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		g, err := ReadEdgeList(file, spec, nbNodes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", arg, err)
+		}
+		return g, nil
+	}
+	return nil, fmt.Errorf("unknown topology %q, expected one of %v", spec, TOPOLOGIES)
+}
@@ -0,0 +1,137 @@
+package Topology
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGraphs(t *testing.T) {
+	var tests = []struct {
+		g        *Graph
+		expected string
+	}{
+		{Complete(4), "[[0 1] [0 2] [0 3] [1 2] [1 3] [2 3]]"},
+		{Ring(4), "[[0 1] [0 3] [1 2] [2 3]]"},
+		{Ring(1), "[]"},
+		// 0 1 2
+		// 3 4
+		{Grid(5, 2, 3), "[[0 1] [0 3] [1 2] [1 4] [3 4]]"},
+	}
+	for _, test := range tests {
+		if edges := fmt.Sprint(test.g.Edges()); edges != test.expected {
+			t.Error(test.g, ": ", edges)
+		}
+	}
+}
+
+func TestAddEdge(t *testing.T) {
+	var g *Graph = New("test", 3)
+	if g.AddEdge(0, 3) == nil || g.AddEdge(-1, 0) == nil || g.AddEdge(1, 1) == nil {
+		t.Error("bad edge added")
+	}
+	g.AddEdge(2, 0)
+	g.AddEdge(0, 2)
+	if !g.HasEdge(0, 2) || !g.HasEdge(2, 0) || g.HasEdge(0, 1) || len(g.Edges()) != 1 {
+		t.Error(g.Neighbors)
+	}
+}
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		spec    string
+		nbEdges int
+	}{
+		{"complete", 10},
+		{"Ring", 5},
+		{"grid", 5},       // 2x3 without the last node
+		{"grid:5x1", 4},
+		{"random:0", 0},
+		{"random:1", 10},
+	}
+	for _, test := range tests {
+		g, err := Parse(test.spec, 5, 1)
+		if err != nil || len(g.Edges()) != test.nbEdges {
+			t.Error(test.spec, ": ", g, " ", err)
+		}
+	}
+	for _, bad := range []string{"foo", "grid:2x2", "grid:x", "random:2", "file:/nonexistent"} {
+		if _, err := Parse(bad, 5, 1); err == nil {
+			t.Error(bad, ": no error")
+		}
+	}
+	// a random graph is drawn from the seed
+	g1, _ := Parse("random", 10, 7)
+	g2, _ := Parse("random", 10, 7)
+	if fmt.Sprint(g1.Edges()) != fmt.Sprint(g2.Edges()) {
+		t.Error("random graphs of the same seed differ")
+	}
+}
+
+func TestReadEdgeList(t *testing.T) {
+	g, err := ReadEdgeList(strings.NewReader("# philosopher philosopher\n0 1\n\n1 2 # comment\n"), "test", 3)
+	if err != nil || fmt.Sprint(g.Edges()) != "[[0 1] [1 2]]" {
+		t.Error(g, err)
+	}
+	for _, bad := range []string{"0", "0 x", "0 3", "1 1"} {
+		if _, err := ReadEdgeList(strings.NewReader(bad), "test", 3); err == nil {
+			t.Error(bad, ": no error")
+		}
+	}
+	var path string = filepath.Join(t.TempDir(), "edges")
+	if err := os.WriteFile(path, []byte("0 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if g, err := Parse("file:" + path, 3, 1); err != nil || !g.HasEdge(0, 2) {
+		t.Error(g, err)
+	}
+}
+
+func TestDistances(t *testing.T) {
+	var g *Graph = Ring(6)
+	g.NbNodes ++
+	g.Neighbors = append(g.Neighbors, nil) // node 6 is isolated
+	if d := fmt.Sprint(g.Distances(0)); d != "[0 1 2 3 2 1 -1]" {
+		t.Error(d)
+	}
+}
+
+// Neighbors have distinct colors, with at most the maximum degree + 1 colors
+func TestColor(t *testing.T) {
+	var graphs = []*Graph{Complete(5), Ring(5), Ring(6), Grid(9, 3, 3), New("empty", 3)}
+	for seed := int64(1); seed <= 10; seed++ {
+		g, _ := Parse("random", 12, seed)
+		graphs = append(graphs, g)
+	}
+	for _, g := range graphs {
+		colors, nbColors := g.Color()
+		var maxDegree int = 0
+		for i := 0; i < g.NbNodes; i++ {
+			if len(g.Neighbors[i]) > maxDegree {
+				maxDegree = len(g.Neighbors[i])
+			}
+			if colors[i] < 0 || colors[i] >= nbColors {
+				t.Error(g, ": color ", colors[i], " of node #", i)
+			}
+		}
+		for _, e := range g.Edges() {
+			if colors[e[0]] == colors[e[1]] {
+				t.Error(g, ": neighbors ", e, " of the same color")
+			}
+		}
+		if nbColors > maxDegree + 1 {
+			t.Error(g, ": ", nbColors, " colors")
+		}
+	}
+	if _, nbColors := Ring(6).Color(); nbColors != 2 {
+		t.Error("even ring: ", nbColors, " colors")
+	}
+}
+
+func TestInitialHolder(t *testing.T) {
+	if InitialHolder(3, 1) != 1 || InitialHolder(1, 3) != 1 {
+		t.Error("the fork is not held by the lowest id")
+	}
+}
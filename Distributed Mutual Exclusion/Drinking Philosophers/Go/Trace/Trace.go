@@ -0,0 +1,354 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Execution traces of the algorithms, written as JSON lines, one event per line:
+  {"time":1520,"node":0,"type":"send","peer":1,"msg":"REPORT","id":12,"vc":[3],"hlc":{"L":1760000001520000,"C":0}}
+  {"time":1702,"node":1,"type":"receive","peer":0,"msg":"REPORT","id":12,"vc":[3,1],"hlc":{"L":1760000001702000,"C":0}}
+  {"time":1710,"node":1,"type":"state","peer":-1,"state":{"Rm_critical":true},"vc":[3,2],"hlc":{"L":1760000001710000,"C":0}}
+  {"time":2005,"node":0,"type":"enterCS","peer":-1,"vc":[4],"hlc":{"L":1760000002005000,"C":0}}
+  {"time":2507,"node":0,"type":"releaseCS","peer":-1,"vc":[5],"hlc":{"L":1760000002507000,"C":0}}
+
+time is in microseconds since the tracer was created. peer is the destination
+of a send and the source of a receive, -1 for the other events. id identifies a message, so that its
+send and receive events can be matched, it is 0 when the algorithm does not
+carry it in its messages. vc is the vector clock of the event: the tracer
+keeps one vector clock per node, ticks it at each event and merges the clock
+of the matching send at each receive. Messages without id are matched with
+the first send of the same type on the same link. hlc is the hybrid logical
+clock of the event, kept per node the same way: unlike the vector clock, its
+size does not grow with the number of nodes, and it stays close to the wall
+clock of the nodes.
+
+CheckCausality verifies that a trace is causally consistent, CheckExclusion
+that no two nodes were in CS with the same resource.
+
+All methods can be called on a nil *Tracer and then do nothing, so that
+algorithms can be traced or not without testing it everywhere.
+
+Traces are rendered as space-time diagrams by the SpaceTime program.
+*/
+
+package Trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"drinking/Clock"
+)
+
+// Event types
+var SEND       string = "send"
+var RECEIVE    string = "receive"
+var ENTER_CS   string = "enterCS"
+var RELEASE_CS string = "releaseCS"
+var STATE      string = "state"
+
+type Event struct {
+	Time  int64        `json:"time"`
+	Node  int          `json:"node"`
+	Type  string       `json:"type"`
+	Peer  int          `json:"peer"`
+	Msg   string       `json:"msg,omitempty"`
+	Id    int          `json:"id,omitempty"`
+	State interface{}  `json:"state,omitempty"`
+	Clock Clock.Vector `json:"vc,omitempty"`
+	HLC   *Clock.Timestamp `json:"hlc,omitempty"`
+}
+
+// link identifies the messages without id, they are matched in FIFO order
+type link struct {
+	src int
+	dst int
+	msg string
+}
+
+// stamp are the clocks of a send
+type stamp struct {
+	clock Clock.Vector
+	hlc   Clock.Timestamp
+}
+
+type Tracer struct {
+	start   time.Time
+	file    *os.File
+	writer  *bufio.Writer
+	mutex   sync.Mutex
+	nextId  int
+	clocks  []Clock.Vector   // vector clock of each node
+	hlcs    []*Clock.HLC     // hybrid logical clock of each node
+	sent    map[int]stamp    // clocks of the sends of the messages with an id
+	pending map[link][]stamp // clocks of the sends of the messages without id
+}
+
+// New returns a tracer writing to w
+func New(w io.Writer) *Tracer {
+	var t Tracer
+	t.start = time.Now()
+	t.writer = bufio.NewWriter(w)
+	t.nextId = 1
+	t.sent = make(map[int]stamp)
+	t.pending = make(map[link][]stamp)
+	return &t
+}
+
+// Create returns a tracer writing to the file at path, or nil if path is empty
+func Create(path string) (*Tracer, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var t *Tracer = New(file)
+	t.file = file
+	return t, nil
+}
+
+// Close flushes the events and closes the file of the tracer
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	err := t.writer.Flush()
+	if t.file != nil {
+		if err2 := t.file.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// NextId returns a new message id, 0 if t is nil
+func (t *Tracer) NextId() int {
+	if t == nil {
+		return 0
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var id int = t.nextId
+	t.nextId ++
+	return id
+}
+
+// tick sets the clocks of e, called with the mutex held
+func (t *Tracer) tick(e *Event) {
+	for len(t.clocks) <= e.Node {
+		t.clocks = append(t.clocks, nil)
+		t.hlcs = append(t.hlcs, Clock.NewHLC())
+	}
+	var v *Clock.Vector = &t.clocks[e.Node]
+	var send *stamp = nil
+	if e.Type == RECEIVE {
+		if e.Id != 0 {
+			// duplicated messages keep the clocks of their send
+			if s, ok := t.sent[e.Id]; ok {
+				send = &s
+			}
+		} else {
+			var l = link{e.Peer, e.Node, e.Msg}
+			if len(t.pending[l]) > 0 {
+				send = &t.pending[l][0]
+				t.pending[l] = t.pending[l][1:]
+			}
+		}
+	}
+	var hlc Clock.Timestamp
+	if send != nil {
+		v.Merge(send.clock)
+		hlc = t.hlcs[e.Node].Receive(send.hlc)
+	} else {
+		hlc = t.hlcs[e.Node].Now()
+	}
+	v.Tick(e.Node)
+	e.Clock = v.Copy()
+	e.HLC = &hlc
+	if e.Type == SEND {
+		if e.Id != 0 {
+			t.sent[e.Id] = stamp{e.Clock, hlc}
+		} else {
+			var l = link{e.Node, e.Peer, e.Msg}
+			t.pending[l] = append(t.pending[l], stamp{e.Clock, hlc})
+		}
+	}
+}
+
+func (t *Tracer) write(e Event) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	e.Time = int64(time.Since(t.start) / time.Microsecond)
+	t.tick(&e)
+	content, err := json.Marshal(e)
+	if err != nil {
+		log.Fatal("Trace ", err)
+	}
+	t.writer.Write(content)
+	t.writer.WriteByte('\n')
+}
+
+func (t *Tracer) Send(node int, dst int, msg string, id int) {
+	if t == nil {
+		return
+	}
+	t.write(Event{Node: node, Type: SEND, Peer: dst, Msg: msg, Id: id})
+}
+
+func (t *Tracer) Receive(node int, src int, msg string, id int) {
+	if t == nil {
+		return
+	}
+	t.write(Event{Node: node, Type: RECEIVE, Peer: src, Msg: msg, Id: id})
+}
+
+func (t *Tracer) EnterCS(node int) {
+	if t == nil {
+		return
+	}
+	t.write(Event{Node: node, Type: ENTER_CS, Peer: -1})
+}
+
+func (t *Tracer) ReleaseCS(node int) {
+	if t == nil {
+		return
+	}
+	t.write(Event{Node: node, Type: RELEASE_CS, Peer: -1})
+}
+
+// State records the state of the algorithm on node, state must be encodable in JSON
+func (t *Tracer) State(node int, state interface{}) {
+	if t == nil {
+		return
+	}
+	t.write(Event{Node: node, Type: STATE, Peer: -1, State: state})
+}
+
+// Read returns all the events of a trace
+func Read(r io.Reader) ([]Event, error) {
+	var events []Event
+	var decoder = json.NewDecoder(r)
+	for {
+		var e Event
+		err := decoder.Decode(&e)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+// CheckCausality returns the violations of causality found in a trace, in the
+// order of the trace:
+// * the events of a node must be in happens-before order, with increasing times
+// * a receive must come after the send of the message, in the trace order,
+//   in time and in happens-before order
+// Vector clocks and hybrid logical clocks are only checked on the events that
+// have one. Messages without
+// id are matched in FIFO order per link, a fault-injection network that
+// duplicates or reorders them makes the check report false violations.
+func CheckCausality(events []Event) []error {
+	var errors []error
+	var last = make(map[int]int)                // node -> index of its last event
+	var sentById = make(map[int]int)            // message id -> index of the send
+	var sentByLink = make(map[link][]int)       // messages without id, in order of sending
+	for i, e := range events {
+		if j, ok := last[e.Node]; ok {
+			var p Event = events[j]
+			if e.Time < p.Time {
+				errors = append(errors, fmt.Errorf("event #%d of node #%d at %dus is before its previous event #%d at %dus", i, e.Node, e.Time, j, p.Time))
+			}
+			if e.Clock != nil && p.Clock != nil && !Clock.HappensBefore(p.Clock, e.Clock) {
+				errors = append(errors, fmt.Errorf("event #%d of node #%d with clock %v does not happen after its previous event #%d with clock %v", i, e.Node, e.Clock, j, p.Clock))
+			}
+			if e.HLC != nil && p.HLC != nil && Clock.CompareTimestamps(*p.HLC, *e.HLC) != Clock.BEFORE {
+				errors = append(errors, fmt.Errorf("event #%d of node #%d with hlc %v is not after its previous event #%d with hlc %v", i, e.Node, e.HLC, j, p.HLC))
+			}
+		}
+		last[e.Node] = i
+
+		switch e.Type {
+		case SEND:
+			if e.Id != 0 {
+				sentById[e.Id] = i
+			} else {
+				var l = link{e.Node, e.Peer, e.Msg}
+				sentByLink[l] = append(sentByLink[l], i)
+			}
+		case RECEIVE:
+			var j int = -1
+			if e.Id != 0 {
+				if k, ok := sentById[e.Id]; ok {
+					j = k
+				}
+			} else {
+				var l = link{e.Peer, e.Node, e.Msg}
+				if len(sentByLink[l]) > 0 {
+					j = sentByLink[l][0]
+					sentByLink[l] = sentByLink[l][1:]
+				}
+			}
+			if j == -1 {
+				errors = append(errors, fmt.Errorf("event #%d: node #%d receives %s from node #%d, which was never sent before", i, e.Node, e.Msg, e.Peer))
+				continue
+			}
+			var s Event = events[j]
+			if s.Node != e.Peer || s.Peer != e.Node {
+				errors = append(errors, fmt.Errorf("event #%d: message %d received by node #%d from node #%d was sent by node #%d to node #%d", i, e.Id, e.Node, e.Peer, s.Node, s.Peer))
+			}
+			if e.Time < s.Time {
+				errors = append(errors, fmt.Errorf("event #%d: node #%d receives %s at %dus, before it was sent at %dus", i, e.Node, e.Msg, e.Time, s.Time))
+			}
+			if e.Clock != nil && s.Clock != nil && !Clock.HappensBefore(s.Clock, e.Clock) {
+				errors = append(errors, fmt.Errorf("event #%d: reception of %s by node #%d with clock %v does not happen after its send #%d with clock %v", i, e.Msg, e.Node, e.Clock, j, s.Clock))
+			}
+			if e.HLC != nil && s.HLC != nil && Clock.CompareTimestamps(*s.HLC, *e.HLC) != Clock.BEFORE {
+				errors = append(errors, fmt.Errorf("event #%d: reception of %s by node #%d with hlc %v is not after its send #%d with hlc %v", i, e.Msg, e.Node, e.HLC, j, s.HLC))
+			}
+		}
+	}
+	return errors
+}
+
+// CheckExclusion returns an error for each CS entered while another node is in
+// CS with one of its resources, in the trace order. resources returns the
+// resources of the k-th CS of node, from 0. The algorithms trace their CS
+// entry once they hold the resources and their exit before they give them
+// back, so the trace order is enough
+func CheckExclusion(events []Event, resources func(node int, k int) []int) []error {
+	var errors []error
+	var user = make(map[int]int) // resource => node in CS with it
+	var nbCS = make(map[int]int)
+	var inCS = make(map[int][]int) // node => resources of its CS
+	for k, e := range events {
+		if e.Type == ENTER_CS {
+			var held []int = resources(e.Node, nbCS[e.Node])
+			nbCS[e.Node] ++
+			for _, r := range held {
+				if other, ok := user[r]; ok && other != e.Node {
+					errors = append(errors, fmt.Errorf("event #%d: node %d enters CS with resource #%d used by node %d", k, e.Node, r, other))
+				}
+				user[r] = e.Node
+			}
+			inCS[e.Node] = held
+		} else if e.Type == RELEASE_CS {
+			for _, r := range inCS[e.Node] {
+				if user[r] == e.Node {
+					delete(user, r)
+				}
+			}
+			delete(inCS, e.Node)
+		}
+	}
+	return errors
+}
@@ -0,0 +1,106 @@
+package Trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// A trace is written and read back, and is causally consistent
+func TestTrace(t *testing.T) {
+	var buffer bytes.Buffer
+	var tracer *Tracer = New(&buffer)
+	var id int = tracer.NextId()
+	tracer.Send(0, 1, "REQ", id)
+	tracer.Send(1, 0, "FORK", 0)
+	tracer.Receive(1, 0, "REQ", id)
+	tracer.Receive(0, 1, "FORK", 0)
+	tracer.EnterCS(0)
+	tracer.State(0, map[string]int{"x": 1})
+	tracer.ReleaseCS(0)
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := Read(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 7 {
+		t.Fatal(len(events), " events")
+	}
+	for _, e := range events {
+		if e.Clock == nil || e.HLC == nil {
+			t.Error("event without clocks: ", e)
+		}
+	}
+	// the reception merges the clocks of the send
+	if events[2].Clock.String() != "[1 2]" {
+		t.Error("vector clock of the reception: ", events[2].Clock)
+	}
+	if errors := CheckCausality(events); len(errors) != 0 {
+		t.Error(errors)
+	}
+}
+
+// A nil tracer does nothing
+func TestNil(t *testing.T) {
+	var tracer *Tracer
+	tracer.Send(0, 1, "REQ", tracer.NextId())
+	tracer.EnterCS(0)
+	if tracer.Close() != nil {
+		t.Error("close of a nil tracer")
+	}
+}
+
+// CheckCausality reports receptions without a send, and clocks going back
+func TestCheckCausality(t *testing.T) {
+	var trace string = `
+{"time":10,"node":1,"type":"receive","peer":0,"msg":"REQ","id":3}
+{"time":20,"node":0,"type":"send","peer":1,"msg":"REQ","id":4,"vc":[2],"hlc":{"L":20,"C":0}}
+{"time":30,"node":1,"type":"receive","peer":0,"msg":"REQ","id":4,"vc":[1,1],"hlc":{"L":20,"C":0}}
+{"time":25,"node":1,"type":"enterCS","peer":-1}
+`
+	events, err := Read(strings.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errors []error = CheckCausality(events)
+	var expected = []string{"never sent", "does not happen after its send", "hlc", "before its previous event"}
+	if len(errors) != len(expected) {
+		t.Fatal(errors)
+	}
+	for k, e := range errors {
+		if !strings.Contains(e.Error(), expected[k]) {
+			t.Error("expected ", expected[k], ", got ", e)
+		}
+	}
+}
+
+// CheckExclusion reports a CS entered with a resource of a node in CS
+func TestCheckExclusion(t *testing.T) {
+	// node 0 uses the resources 0 and 1, then 2, node 1 uses 1, then 2
+	var resources = func(node int, k int) []int {
+		return [][][]int{{{0, 1}, {2}}, {{1}, {2}}}[node][k]
+	}
+	var trace string = `
+{"time":1,"node":0,"type":"enterCS","peer":-1}
+{"time":2,"node":0,"type":"releaseCS","peer":-1}
+{"time":3,"node":1,"type":"enterCS","peer":-1}
+{"time":4,"node":0,"type":"enterCS","peer":-1}
+{"time":5,"node":1,"type":"releaseCS","peer":-1}
+{"time":6,"node":1,"type":"enterCS","peer":-1}
+{"time":7,"node":0,"type":"releaseCS","peer":-1}
+`
+	events, err := Read(strings.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errors []error = CheckExclusion(events, resources)
+	if len(errors) != 1 || !strings.Contains(errors[0].Error(), "node 1 enters CS with resource #2 used by node 0") {
+		t.Error(errors)
+	}
+	if errors := CheckExclusion(events[:5], resources); len(errors) != 0 {
+		t.Error(errors)
+	}
+}
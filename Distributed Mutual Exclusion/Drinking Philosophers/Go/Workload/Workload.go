@@ -0,0 +1,524 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Workload of the resource allocation algorithms: the requests of the nodes,
+the time they think between two requests and the time they stay in CS.
+
+Each request names its own set of resources, from 1 resource up to all of
+them. Its size is drawn from a distribution:
+* fixed: all requests have the maximum size
+* uniform: sizes between 1 and the maximum, with the same probability
+* geometric: size k with probability 1/2^k, most requests are small, the
+  remaining probability goes to the maximum size
+
+The resources are chosen following an access pattern:
+* uniform: all the resources have the same probability
+* zipf: resource #k is chosen with a weight 1/(k+1)^s, resource #0 is the
+  hot spot
+* ring: the resources are on a ring, node #i requests consecutive resources
+  around resource #i, so that it only conflicts with its neighbors
+* replay: the requests are read from a file, one per line:
+    # node resources [think [cs]]
+    0 1,2
+    1 0,3 20ms 300ms
+  each node replays its lines in order, and starts again at the end, all the
+  nodes must have at least one line. The think and CS durations of a line
+  override the ones of the workload, the sizes are those of the file
+
+The conflict graph of the resources, see ResourceGraph, links the resources
+that a node may request together, for the algorithms that order or color the
+resources.
+
+Think and CS durations are written as:
+* 500ms: a fixed duration
+* 0-100ms: uniform between the two durations
+* exp:200ms: exponential with this mean
+
+Usage:
+  var workloadFlags = Workload.RegisterFlags(flag.CommandLine)
+  flag.Parse()
+  config, err := workloadFlags.Config(nbNodes, nbResources)
+  ...
+  var generator *Workload.Generator = config.Generator(id, seed)
+  var request Workload.Request = generator.Next()
+  time.Sleep(request.Think)
+  ... request.Resources ...
+  time.Sleep(request.CS)
+*/
+
+package Workload
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"drinking/Topology"
+)
+
+// Request size distributions, FIXED and UNIFORM are also duration distributions
+var FIXED       string = "fixed"
+var UNIFORM     string = "uniform"
+var GEOMETRIC   string = "geometric"
+var EXPONENTIAL string = "exp"
+
+var SIZE_DISTRIBUTIONS = []string{FIXED, UNIFORM, GEOMETRIC}
+
+// Access patterns
+var ZIPF   string = "zipf"
+var RING   string = "ring"
+var REPLAY string = "replay"
+
+var PATTERNS = []string{UNIFORM, ZIPF, RING, REPLAY}
+
+// Default think and CS durations, those used by the algorithms before the workload
+var DEFAULT_THINK string = "0-100ms"
+var DEFAULT_CS    string = "500ms"
+
+////////////////////////////////////////////////////////////
+// Request sizes
+////////////////////////////////////////////////////////////
+type SizeDistribution struct {
+	Name string
+	Max  int // the largest request, the size of all requests with FIXED
+}
+
+// NewSizeDistribution checks that the distribution exists and that max is between 1 and nbResources
+func NewSizeDistribution(name string, max int, nbResources int) (SizeDistribution, error) {
+	var d SizeDistribution
+	for _, n := range SIZE_DISTRIBUTIONS {
+		if strings.EqualFold(name, n) {
+			d.Name = n
+		}
+	}
+	if d.Name == "" {
+		return d, fmt.Errorf("unknown request size distribution %q, must be one of %v", name, SIZE_DISTRIBUTIONS)
+	}
+	if max < 1 || max > nbResources {
+		return d, fmt.Errorf("size of requests must be between 1 and the number of resources %d, got %d", nbResources, max)
+	}
+	d.Max = max
+	return d, nil
+}
+
+// Fixed returns the distribution where all requests have size resources
+func Fixed(size int) SizeDistribution {
+	return SizeDistribution{FIXED, size}
+}
+
+func (d SizeDistribution) String() string {
+	if d.Name == FIXED {
+		return fmt.Sprintf("%s %d", d.Name, d.Max)
+	}
+	return fmt.Sprintf("%s 1-%d", d.Name, d.Max)
+}
+
+// Size draws the size of a request
+func (d SizeDistribution) Size(r *rand.Rand) int {
+	switch d.Name {
+	case UNIFORM:
+		return 1 + r.Intn(d.Max)
+	case GEOMETRIC:
+		var size int = 1
+		for size < d.Max && r.Intn(2) == 0 {
+			size ++
+		}
+		return size
+	}
+	return d.Max
+}
+
+// Request draws the resources of a request among the resources 0 to nbResources - 1
+func (d SizeDistribution) Request(r *rand.Rand, nbResources int) []int {
+	return PickResources(r, nbResources, d.Size(r))
+}
+
+// PickResources returns size distinct resources among 0 to nbResources - 1
+func PickResources(r *rand.Rand, nbResources int, size int) []int {
+	var resources []int = r.Perm(nbResources)
+	return resources[:size]
+}
+
+// PickZipfResources returns size distinct resources among 0 to nbResources - 1,
+// resource #k having the weight 1/(k+1)^s
+func PickZipfResources(r *rand.Rand, nbResources int, size int, s float64) []int {
+	var weights = make([]float64, nbResources)
+	var total float64 = 0
+	for k := 0; k < nbResources; k++ {
+		weights[k] = 1 / math.Pow(float64(k + 1), s)
+		total += weights[k]
+	}
+	var resources []int
+	for len(resources) < size {
+		var x float64 = r.Float64() * total
+		var k int = 0
+		for ; k < nbResources - 1; k++ {
+			if weights[k] > 0 && x < weights[k] {
+				break
+			}
+			x -= weights[k]
+		}
+		for weights[k] == 0 {
+			// rounding error at the end of the weights
+			k --
+		}
+		resources = append(resources, k)
+		total -= weights[k]
+		weights[k] = 0
+	}
+	return resources
+}
+
+// PickRingResources returns size consecutive resources on a ring of
+// nbResources resources, among them resource #node
+func PickRingResources(r *rand.Rand, nbResources int, size int, node int) []int {
+	var first int = node - r.Intn(size)
+	var resources = make([]int, size)
+	for k := 0; k < size; k++ {
+		resources[k] = ((first + k) % nbResources + nbResources) % nbResources
+	}
+	return resources
+}
+
+////////////////////////////////////////////////////////////
+// Durations
+////////////////////////////////////////////////////////////
+type Duration struct {
+	Name string
+	Min  time.Duration // the fixed duration, or the mean of the exponential one
+	Max  time.Duration
+}
+
+// ParseDuration parses a duration written as 500ms, 0-100ms or exp:200ms
+func ParseDuration(s string) (Duration, error) {
+	var d Duration
+	var err error
+	if strings.HasPrefix(s, EXPONENTIAL + ":") {
+		d.Name = EXPONENTIAL
+		d.Min, err = time.ParseDuration(strings.TrimPrefix(s, EXPONENTIAL + ":"))
+		d.Max = d.Min
+	} else if i := strings.Index(s, "-"); i > 0 {
+		d.Name = UNIFORM
+		var min, max string = s[:i], s[i + 1:]
+		d.Max, err = time.ParseDuration(max)
+		if err == nil {
+			if _, errNumber := strconv.ParseFloat(min, 64); errNumber == nil {
+				// 0-100ms, the unit of the maximum
+				min = min + strings.TrimLeft(max, "0123456789.")
+			}
+			d.Min, err = time.ParseDuration(min)
+		}
+		if err == nil && d.Min > d.Max {
+			err = fmt.Errorf("the minimum is above the maximum")
+		}
+	} else {
+		d.Name = FIXED
+		d.Min, err = time.ParseDuration(s)
+		d.Max = d.Min
+	}
+	if err == nil && d.Min < 0 {
+		err = fmt.Errorf("negative duration")
+	}
+	if err != nil {
+		return d, fmt.Errorf("duration %q: %v", s, err)
+	}
+	return d, nil
+}
+
+func (d Duration) String() string {
+	switch d.Name {
+	case UNIFORM:
+		return fmt.Sprintf("%v-%v", d.Min, d.Max)
+	case EXPONENTIAL:
+		return fmt.Sprintf("%s:%v", EXPONENTIAL, d.Min)
+	}
+	return d.Min.String()
+}
+
+// Draw draws a duration
+func (d Duration) Draw(r *rand.Rand) time.Duration {
+	switch d.Name {
+	case UNIFORM:
+		return d.Min + time.Duration(r.Int63n(int64(d.Max - d.Min) + 1))
+	case EXPONENTIAL:
+		return time.Duration(r.ExpFloat64() * float64(d.Min))
+	}
+	return d.Min
+}
+
+////////////////////////////////////////////////////////////
+// Workload
+////////////////////////////////////////////////////////////
+type Request struct {
+	Resources []int
+	Think     time.Duration // before the request
+	CS        time.Duration // in CS
+}
+
+// replayLine is a request of a replayed file, the durations are negative when not given
+type replayLine struct {
+	resources []int
+	think     time.Duration
+	cs        time.Duration
+}
+
+type Config struct {
+	Pattern     string
+	Sizes       SizeDistribution
+	NbResources int
+	ZipfS       float64 // the exponent of the zipf pattern
+	Think       Duration
+	CS          Duration
+	replay      map[int][]replayLine // the lines of each node with the replay pattern
+}
+
+// NewConfig returns the workload of the uniform pattern with the default durations
+func NewConfig(nbResources int, sizes SizeDistribution) *Config {
+	var c Config
+	c.Pattern = UNIFORM
+	c.Sizes = sizes
+	c.NbResources = nbResources
+	c.ZipfS = 1
+	c.Think, _ = ParseDuration(DEFAULT_THINK)
+	c.CS, _ = ParseDuration(DEFAULT_CS)
+	return &c
+}
+
+func (c *Config) String() string {
+	var pattern string = c.Pattern
+	if c.Pattern == ZIPF {
+		pattern = fmt.Sprintf("%s s=%g", ZIPF, c.ZipfS)
+	}
+	return fmt.Sprintf("pattern %s, request sizes %v, think %v, CS %v", pattern, c.Sizes, c.Think, c.CS)
+}
+
+// SetPattern checks the access pattern, the replay pattern is set with ReadReplay
+func (c *Config) SetPattern(name string) error {
+	for _, p := range PATTERNS {
+		if strings.EqualFold(name, p) && p != REPLAY {
+			c.Pattern = p
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown access pattern %q, must be one of %v, replay needs a file", name, PATTERNS)
+}
+
+// ReadReplay sets the replay pattern with the requests read from r
+func (c *Config) ReadReplay(r io.Reader) error {
+	var replay = make(map[int][]replayLine)
+	var scanner = bufio.NewScanner(r)
+	var lineNumber int = 0
+	for scanner.Scan() {
+		lineNumber ++
+		var fields []string = strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 4 {
+			return fmt.Errorf("line %d: expected node resources [think [cs]]", lineNumber)
+		}
+		node, err := strconv.Atoi(fields[0])
+		if err != nil || node < 0 {
+			return fmt.Errorf("line %d: bad node %q", lineNumber, fields[0])
+		}
+		var line = replayLine{think: -1, cs: -1}
+		var seen = make(map[int]bool)
+		for _, field := range strings.Split(fields[1], ",") {
+			resource, err := strconv.Atoi(field)
+			if err != nil || resource < 0 || resource >= c.NbResources {
+				return fmt.Errorf("line %d: bad resource %q, must be between 0 and %d", lineNumber, field, c.NbResources - 1)
+			}
+			if seen[resource] {
+				return fmt.Errorf("line %d: resource %d requested twice", lineNumber, resource)
+			}
+			seen[resource] = true
+			line.resources = append(line.resources, resource)
+		}
+		if len(fields) > 2 {
+			if line.think, err = time.ParseDuration(fields[2]); err != nil {
+				return fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+		}
+		if len(fields) > 3 {
+			if line.cs, err = time.ParseDuration(fields[3]); err != nil {
+				return fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+		}
+		replay[node] = append(replay[node], line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	c.Pattern = REPLAY
+	c.replay = replay
+	return nil
+}
+
+// ReplayNodes checks that the replayed file has requests for all the nodes 0 to nbNodes - 1
+func (c *Config) ReplayNodes(nbNodes int) error {
+	for i := 0; i < nbNodes; i++ {
+		if len(c.replay[i]) == 0 {
+			return fmt.Errorf("no request to replay for node #%d", i)
+		}
+	}
+	return nil
+}
+
+// ResourceGraph returns the conflict graph of the resources: two resources
+// are neighbors when nodes #0 to nbNodes - 1 may request them together
+func (c *Config) ResourceGraph(nbNodes int) *Topology.Graph {
+	var g *Topology.Graph = Topology.New("resources, pattern " + c.Pattern, c.NbResources)
+	var addRequest = func(resources []int) {
+		for a := 0; a < len(resources); a++ {
+			for b := a + 1; b < len(resources); b++ {
+				g.AddEdge(resources[a], resources[b])
+			}
+		}
+	}
+	switch c.Pattern {
+	case RING:
+		// node #i requests at most Max consecutive resources around resource #i
+		for i := 0; i < nbNodes; i++ {
+			for first := i - c.Sizes.Max + 1; first <= i; first++ {
+				var window = make([]int, c.Sizes.Max)
+				for k := 0; k < c.Sizes.Max; k++ {
+					window[k] = ((first + k) % c.NbResources + c.NbResources) % c.NbResources
+				}
+				addRequest(window)
+			}
+		}
+	case REPLAY:
+		for i := 0; i < nbNodes; i++ {
+			for _, line := range c.replay[i] {
+				addRequest(line.resources)
+			}
+		}
+	default:
+		// any resources may be requested together
+		if c.Sizes.Max > 1 {
+			var all = make([]int, c.NbResources)
+			for r := 0; r < c.NbResources; r++ {
+				all[r] = r
+			}
+			addRequest(all)
+		}
+	}
+	return g
+}
+
+// Generator draws the requests of a node
+type Generator struct {
+	config *Config
+	node   int
+	rand   *rand.Rand
+	next   int // next line to replay
+}
+
+// Generator returns the generator of the requests of node, its random choices are seeded with seed
+func (c *Config) Generator(node int, seed int64) *Generator {
+	var g Generator
+	g.config = c
+	g.node = node
+	g.rand = rand.New(rand.NewSource(seed))
+	return &g
+}
+
+// Next draws the next request of the node
+func (g *Generator) Next() Request {
+	var c *Config = g.config
+	var request Request
+	request.Think = c.Think.Draw(g.rand)
+	request.CS = c.CS.Draw(g.rand)
+	switch c.Pattern {
+	case ZIPF:
+		request.Resources = PickZipfResources(g.rand, c.NbResources, c.Sizes.Size(g.rand), c.ZipfS)
+	case RING:
+		request.Resources = PickRingResources(g.rand, c.NbResources, c.Sizes.Size(g.rand), g.node)
+	case REPLAY:
+		var lines []replayLine = c.replay[g.node]
+		var line replayLine = lines[g.next % len(lines)]
+		g.next ++
+		request.Resources = make([]int, len(line.resources))
+		copy(request.Resources, line.resources)
+		if line.think >= 0 {
+			request.Think = line.think
+		}
+		if line.cs >= 0 {
+			request.CS = line.cs
+		}
+	default:
+		request.Resources = c.Sizes.Request(g.rand, c.NbResources)
+	}
+	return request
+}
+
+////////////////////////////////////////////////////////////
+// Command line
+////////////////////////////////////////////////////////////
+
+// Flags are the command line flags of the workload, shared by all the programs
+type Flags struct {
+	requestSize     *int
+	requestSizeDist *string
+	pattern         *string
+	zipfS           *float64
+	replay          *string
+	think           *string
+	cs              *string
+}
+
+// RegisterFlags defines the flags of the workload in fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	var f Flags
+	f.requestSize = fs.Int("requestSize", 2, "size of requests, the largest one if their size is not fixed")
+	f.requestSizeDist = fs.String("requestSizeDist", FIXED, "distribution of the size of requests: fixed, uniform or geometric")
+	f.pattern = fs.String("pattern", UNIFORM, "access pattern of the resources: uniform, zipf or ring")
+	f.zipfS = fs.Float64("zipf", 1, "exponent of the zipf access pattern")
+	f.replay = fs.String("replay", "", "file of requests to replay, instead of the access pattern")
+	f.think = fs.String("think", DEFAULT_THINK, "think time before each request: 500ms, 0-100ms or exp:200ms")
+	f.cs = fs.String("cs", DEFAULT_CS, "time spent in CS: 500ms, 0-100ms or exp:200ms")
+	return &f
+}
+
+// Config returns the workload set on the command line for nbNodes nodes sharing nbResources resources
+func (f *Flags) Config(nbNodes int, nbResources int) (*Config, error) {
+	sizes, err := NewSizeDistribution(*f.requestSizeDist, *f.requestSize, nbResources)
+	if err != nil {
+		return nil, err
+	}
+	var c *Config = NewConfig(nbResources, sizes)
+	if err = c.SetPattern(*f.pattern); err != nil {
+		return nil, err
+	}
+	if *f.zipfS <= 0 {
+		return nil, fmt.Errorf("the exponent of the zipf pattern must be positive")
+	}
+	c.ZipfS = *f.zipfS
+	if c.Think, err = ParseDuration(*f.think); err != nil {
+		return nil, err
+	}
+	if c.CS, err = ParseDuration(*f.cs); err != nil {
+		return nil, err
+	}
+	if *f.replay != "" {
+		file, err := os.Open(*f.replay)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		if err = c.ReadReplay(file); err != nil {
+			return nil, fmt.Errorf("%s: %v", *f.replay, err)
+		}
+		if err = c.ReplayNodes(nbNodes); err != nil {
+			return nil, fmt.Errorf("%s: %v", *f.replay, err)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,207 @@
+package Workload
+
+import (
+	"flag"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSizeDistribution(t *testing.T) {
+	d, err := NewSizeDistribution("Uniform", 3, 5)
+	if err != nil || d.Name != UNIFORM || d.Max != 3 || d.String() != "uniform 1-3" {
+		t.Error(d, err)
+	}
+	for _, bad := range []struct {
+		name     string
+		max, nbResources int
+	}{{"foo", 1, 5}, {FIXED, 0, 5}, {FIXED, 6, 5}} {
+		if _, err := NewSizeDistribution(bad.name, bad.max, bad.nbResources); err == nil {
+			t.Error(bad, ": no error")
+		}
+	}
+}
+
+// The sizes are between 1 and the maximum, and the resources of a request distinct
+func TestSizes(t *testing.T) {
+	var r = rand.New(rand.NewSource(1))
+	for _, name := range SIZE_DISTRIBUTIONS {
+		d, _ := NewSizeDistribution(name, 4, 10)
+		var seen = make(map[int]bool)
+		for k := 0; k < 1000; k++ {
+			var resources []int = d.Request(r, 10)
+			seen[len(resources)] = true
+			if len(resources) < 1 || len(resources) > 4 || (name == FIXED && len(resources) != 4) {
+				t.Fatal(name, ": ", resources)
+			}
+			var distinct = make(map[int]bool)
+			for _, res := range resources {
+				if res < 0 || res >= 10 || distinct[res] {
+					t.Fatal(name, ": ", resources)
+				}
+				distinct[res] = true
+			}
+		}
+		if name != FIXED && len(seen) != 4 {
+			t.Error(name, ": sizes ", seen)
+		}
+	}
+}
+
+// Resource #0 is the most requested with zipf
+func TestZipf(t *testing.T) {
+	var r = rand.New(rand.NewSource(1))
+	var count = make([]int, 10)
+	for k := 0; k < 2000; k++ {
+		var resources []int = PickZipfResources(r, 10, 2, 1)
+		if len(resources) != 2 || resources[0] == resources[1] {
+			t.Fatal(resources)
+		}
+		for _, res := range resources {
+			count[res] ++
+		}
+	}
+	for res := 1; res < 10; res++ {
+		if count[res] >= count[0] {
+			t.Error("resource #", res, " requested ", count[res], " times, #0 ", count[0], " times")
+		}
+	}
+	// all the resources
+	if resources := PickZipfResources(r, 5, 5, 3); len(resources) != 5 {
+		t.Error(resources)
+	}
+}
+
+// A ring request has consecutive resources around the one of the node
+func TestRing(t *testing.T) {
+	var r = rand.New(rand.NewSource(1))
+	for k := 0; k < 100; k++ {
+		var resources []int = PickRingResources(r, 6, 3, 0)
+		var found bool = false
+		for i, res := range resources {
+			found = found || res == 0
+			if i > 0 && res != (resources[i - 1] + 1) % 6 {
+				t.Fatal("not consecutive: ", resources)
+			}
+		}
+		if !found {
+			t.Fatal("resource #0 not requested: ", resources)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	var tests = []struct {
+		s        string
+		name     string
+		min, max time.Duration
+	}{
+		{"500ms", FIXED, 500 * time.Millisecond, 500 * time.Millisecond},
+		{"0-100ms", UNIFORM, 0, 100 * time.Millisecond},
+		{"1s-2s", UNIFORM, time.Second, 2 * time.Second},
+		{"exp:200ms", EXPONENTIAL, 200 * time.Millisecond, 200 * time.Millisecond},
+	}
+	for _, test := range tests {
+		d, err := ParseDuration(test.s)
+		if err != nil || d.Name != test.name || d.Min != test.min || d.Max != test.max {
+			t.Error(test.s, ": ", d, " ", err)
+		}
+	}
+	for _, bad := range []string{"", "foo", "2s-1s", "-1s", "exp:x"} {
+		if _, err := ParseDuration(bad); err == nil {
+			t.Error(bad, ": no error")
+		}
+	}
+	var r = rand.New(rand.NewSource(1))
+	d, _ := ParseDuration("10ms-20ms")
+	for k := 0; k < 100; k++ {
+		if x := d.Draw(r); x < 10 * time.Millisecond || x > 20 * time.Millisecond {
+			t.Fatal(x)
+		}
+	}
+}
+
+// The requests of a node are drawn from its seed, a run can be replayed
+func TestGenerator(t *testing.T) {
+	var c *Config = NewConfig(8, Fixed(2))
+	var g1, g2 *Generator = c.Generator(0, 7), c.Generator(0, 7)
+	for k := 0; k < 20; k++ {
+		var r1, r2 Request = g1.Next(), g2.Next()
+		if r1.Think != r2.Think || r1.CS != r2.CS || len(r1.Resources) != 2 || r1.Resources[0] != r2.Resources[0] || r1.Resources[1] != r2.Resources[1] {
+			t.Fatal(r1, r2)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var c *Config = NewConfig(4, Fixed(1))
+	var file string = `
+# node resources [think [cs]]
+0 1,2
+0 3 20ms 300ms
+1 0
+`
+	if err := c.ReadReplay(strings.NewReader(file)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReplayNodes(2); err != nil {
+		t.Error(err)
+	}
+	if err := c.ReplayNodes(3); err == nil {
+		t.Error("node #2 has no request")
+	}
+	var g *Generator = c.Generator(0, 1)
+	var r Request = g.Next()
+	if len(r.Resources) != 2 || r.Resources[0] != 1 || r.Resources[1] != 2 {
+		t.Error(r)
+	}
+	r = g.Next()
+	if len(r.Resources) != 1 || r.Think != 20 * time.Millisecond || r.CS != 300 * time.Millisecond {
+		t.Error(r)
+	}
+	// starts again at the end
+	if r = g.Next(); r.Resources[0] != 1 {
+		t.Error(r)
+	}
+	for _, bad := range []string{"0", "x 1", "0 4", "0 1,1", "0 1 x", "0 1 1s x"} {
+		if err := NewConfig(4, Fixed(1)).ReadReplay(strings.NewReader(bad)); err == nil {
+			t.Error(bad, ": no error")
+		}
+	}
+}
+
+func TestResourceGraph(t *testing.T) {
+	var c *Config = NewConfig(6, Fixed(2))
+	c.SetPattern(RING)
+	var g = c.ResourceGraph(6)
+	if !g.HasEdge(0, 1) || !g.HasEdge(5, 0) || g.HasEdge(0, 2) {
+		t.Error(g.Edges())
+	}
+	c = NewConfig(4, Fixed(1))
+	if len(c.ResourceGraph(4).Edges()) != 0 {
+		t.Error("requests of one resource are not in conflict")
+	}
+	c = NewConfig(4, Fixed(2))
+	if len(c.ResourceGraph(4).Edges()) != 6 {
+		t.Error("any resources may be requested together")
+	}
+}
+
+func TestFlags(t *testing.T) {
+	var fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	var f *Flags = RegisterFlags(fs)
+	if err := fs.Parse([]string{"-requestSize=3", "-requestSizeDist=geometric", "-pattern=zipf", "-think=exp:10ms"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := f.Config(4, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Pattern != ZIPF || c.Sizes.Name != GEOMETRIC || c.Sizes.Max != 3 || c.Think.Name != EXPONENTIAL {
+		t.Error(c)
+	}
+	if _, err := f.Config(4, 2); err == nil {
+		t.Error("requests larger than the resources accepted")
+	}
+}
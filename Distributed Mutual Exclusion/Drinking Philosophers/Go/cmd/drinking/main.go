@@ -0,0 +1,181 @@
+/* 
+The drinking philosophers are the module drinking, each algorithm is a
+package, this command and cmd/spacetime are its binaries. From the directory
+of go.mod, the logger is vendored so the build works offline:
+go build ./...
+go test ./...
+
+go run ./cmd/drinking --algo=Rhee #default
+ or
+go run ./cmd/drinking --algo=ChandyMisra
+
+The available algorithms and the flags they support:
+go run ./cmd/drinking --list
+
+Rhee with requests of 1 to 4 resources among 6:
+go run ./cmd/drinking --algo=Rhee --nodes=6 --requestSize=4 --requestSizeDist=uniform
+
+Rhee with a hot spot on resource #0, short CS and exponential think times:
+go run ./cmd/drinking --algo=Rhee --nodes=8 --pattern=zipf --zipf=1.5 --cs=50-200ms --think=exp:100ms
+
+Rhee replaying the requests of a file, see the Workload package:
+go run ./cmd/drinking --algo=Rhee --replay=requests.txt
+
+Rhee regression, 100 runs with the seeds 1 to 100, each stopped if not finished after 1 minute:
+go run ./cmd/drinking --algo=Rhee --seed=1 --runs=100 --timeout=1m 2>/dev/null
+
+Rhee with a centralized arbiter, or Dijkstra's resource ordering, as its
+dining philosophers subroutine instead of Chandy-Misra, see the Dining package:
+go run ./cmd/drinking --algo=Rhee --dining=arbiter
+go run ./cmd/drinking --algo=Rhee --dining=ordered
+
+Chandy-Misra drinking philosophers, the dining philosophers only solve the conflicts:
+go run ./cmd/drinking --algo=ChandyMisraDrinking --nodes=6 --requestSize=3 --requestSizeDist=uniform
+
+Lynch's coloring algorithm, the nodes request 3 consecutive resources on a
+ring, the resources are colored with 3 colors whatever the number of nodes:
+go run ./cmd/drinking --algo=Lynch --nodes=12 --pattern=ring --requestSize=3
+
+Bouabdallah-Laforest, each node holds the token of one resource and the
+Control Token serializes the requests of tokens held by other nodes:
+go run ./cmd/drinking --algo=BouabdallahLaforest --nodes=6 --requestSize=3 --requestSizeDist=uniform
+
+Chandy-Misra on a 3x4 grid, or on the edges listed in a file, see the Topology package:
+go run ./cmd/drinking --algo=ChandyMisra --nodes=12 --topology=grid:3x4
+go run ./cmd/drinking --algo=ChandyMisra --nodes=6 --topology=file:edges.txt
+
+Choy-Singh double doorway on a 6x6 grid, philosopher #14 crashing in its CS
+after 20 CS entries, the philosophers still hungry 5s later are blocked:
+go run ./cmd/drinking --algo=ChoySingh --nodes=36 --topology=grid --crash=14 --crashAfter=20 --crashObserve=5s
+
+Chandy-Misra in fault-tolerant mode, with philosopher #2 crashing after 3 CS entries:
+go run ./cmd/drinking --algo=ChandyMisra --fd=phi --crash=2 --crashAfter=3
+
+Rhee over a network dropping 1% of the messages and delaying the others, stopped if not finished after 1 minute:
+go run ./cmd/drinking --algo=Rhee --netSeed=42 --netDrop=0.01 --netMaxDelay=20ms --timeout=1m
+
+Faults can also follow a script, see Network.ParseSchedule:
+go run ./cmd/drinking --algo=ChandyMisra --netScript=faults.txt
+
+Dijkstra's resource managers, 8 nodes sharing 64 resources:
+go run ./cmd/drinking --algo=Dijkstra --nodes=8 --resources=64 --requestSize=4
+
+Awerbuch-Saks, the response times are compared with the bound:
+go run ./cmd/drinking --algo=AwerbuchSaks --nodes=8 --requestSize=3 --requestSizeDist=uniform
+
+Centralized coordinator, the baseline of the other algorithms, as a single
+lock or granting the requests with the fewest resources first:
+go run ./cmd/drinking --algo=Coordinator --resources=1 --requestSize=1
+go run ./cmd/drinking --algo=Coordinator --nodes=8 --resources=16 --requestSize=4 --queue=smallest
+
+Lynch regression, 20 runs with the seeds 1 to 20, every algorithm supports --runs:
+go run ./cmd/drinking --algo=Lynch --seed=1 --runs=20 --timeout=1m 2>/dev/null
+
+Execution trace, rendered as a space-time diagram:
+go run ./cmd/drinking --algo=Rhee --trace=/tmp/rhee.jsonl
+go run ./cmd/spacetime -o /tmp/rhee.svg /tmp/rhee.jsonl
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"drinking/Coordinator"
+	"drinking/Dining"
+	"drinking/Driver"
+	"drinking/Topology"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+func main() {
+	algoPtr := flag.String("algo", "Rhee", "algorithm to run: " + strings.Join(Driver.Names(), ", "))
+	listPtr := flag.Bool("list", false, "list the algorithms and the flags they support")
+	nbNodesPtr := flag.Int("nodes", 4, "number of nodes in the system")
+	nbResourcesPtr := flag.Int("resources", 0, "number of resources, one per node if 0")
+	var workloadFlags *Workload.Flags = Workload.RegisterFlags(flag.CommandLine)
+	nbIterationsPtr := flag.Int("nbIterations", 10, "total number of Critical Section requests")
+	diningPtr := flag.String("dining", "", "dining philosophers subroutine of Rhee: " + strings.Join(Dining.SUBROUTINES, ", ") + ", chandymisra when empty")
+	queuePtr := flag.String("queue", "", "queue policy of the Coordinator: " + strings.Join(Coordinator.POLICIES, ", ") + ", fifo when empty")
+	fdPtr := flag.String("fd", "", "failure detector used by ChandyMisra: heartbeat or phi, none when empty")
+	crashPtr := flag.Int("crash", -1, "philosopher to crash during the run with ChandyMisra, in its CS with ChoySingh, -1 for none")
+	crashAfterPtr := flag.Int("crashAfter", 3, "number of CS entries before the crash")
+	crashObservePtr := flag.Duration("crashObserve", 5 * time.Second, "duration of the ChoySingh run after the crash, the philosophers hungry for half of it are blocked")
+	netSeedPtr := flag.Int64("netSeed", 0, "seed of the faults injected in the network")
+	netDropPtr := flag.Float64("netDrop", 0, "probability that a message is dropped")
+	netDuplicatePtr := flag.Float64("netDuplicate", 0, "probability that a message is duplicated")
+	netReorderPtr := flag.Float64("netReorder", 0, "probability that a message is delivered after the next one")
+	netMinDelayPtr := flag.Duration("netMinDelay", 0, "minimum delay of a message")
+	netMaxDelayPtr := flag.Duration("netMaxDelay", 0, "maximum delay of a message")
+	netScriptPtr := flag.String("netScript", "", "script of the faults injected in the network")
+	timeoutPtr := flag.Duration("timeout", 0, "stop the run after this duration, no limit if 0")
+	tracePtr := flag.String("trace", "", "file where the execution trace is written, none when empty")
+	topologyPtr := flag.String("topology", Topology.COMPLETE, "conflict graph of ChandyMisra and ChoySingh: complete, ring, grid[:RxC], random[:P] or file:PATH")
+	seedPtr := flag.Int64("seed", 0, "seed of the random choices of the nodes and of the random topology, based on the time if 0")
+	runsPtr := flag.Int("runs", 1, "number of runs, with the seeds seed, seed+1, ...")
+	flag.Parse()
+	if *listPtr {
+		fmt.Println(Driver.List())
+		return
+	}
+	log.Println("algo:", *algoPtr)
+	var algorithm Driver.Algorithm = Driver.Lookup(*algoPtr)
+	if algorithm == nil {
+		log.Fatal("Unknown algorithm ", *algoPtr, ", must be one of ", strings.Join(Driver.Names(), ", "))
+	}
+
+	var config *Driver.Config = Driver.NewConfig()
+	config.NbNodes = *nbNodesPtr
+	config.NbResources = *nbResourcesPtr
+	config.NbIterations = *nbIterationsPtr
+	config.FailureDetector = *fdPtr
+	config.Dining = *diningPtr
+	config.Queue = *queuePtr
+	config.Crash = *crashPtr
+	config.CrashAfter = *crashAfterPtr
+	config.CrashObserve = *crashObservePtr
+	config.Faults.Seed = *netSeedPtr
+	config.Faults.DropRate = *netDropPtr
+	config.Faults.DuplicateRate = *netDuplicatePtr
+	config.Faults.ReorderRate = *netReorderPtr
+	config.Faults.MinDelay = *netMinDelayPtr
+	config.Faults.MaxDelay = *netMaxDelayPtr
+	config.NetScript = *netScriptPtr
+	config.NetEnabled = *netDropPtr > 0 || *netDuplicatePtr > 0 || *netReorderPtr > 0 || *netMaxDelayPtr > 0 || *netScriptPtr != ""
+	config.Timeout = *timeoutPtr
+
+	var err error
+	config.Tracer, err = Trace.Create(*tracePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer config.Tracer.Close()
+
+	var seed int64 = *seedPtr
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	config.Seed = seed
+	if err := Driver.Setup(algorithm, config, workloadFlags, *topologyPtr); err != nil {
+		config.Tracer.Close()
+		log.Fatal(err)
+	}
+	for run := 0; run < *runsPtr; run++ {
+		if *runsPtr > 1 {
+			log.Print(algorithm.Info().Name, " run #", run, ", seed ", seed + int64(run))
+		}
+		config.Seed = seed + int64(run)
+		if err := Driver.Run(algorithm, config); err != nil {
+			config.Tracer.Close()
+			log.Fatal(err)
+		}
+	}
+	if *runsPtr > 1 {
+		log.Print(*runsPtr, " ", algorithm.Info().Name, " runs finished")
+	}
+}
@@ -0,0 +1,340 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Renders an execution trace written by the Trace package as a space-time diagram.
+
+How-to run: 
+  go run ./cmd/drinking --algo=Rhee --trace=/tmp/rhee.jsonl
+  go run ./cmd/spacetime -format=svg -o /tmp/rhee.svg /tmp/rhee.jsonl
+  go run ./cmd/spacetime -format=mermaid -states -o /tmp/rhee.mmd /tmp/rhee.jsonl
+  go run ./cmd/spacetime -check /tmp/rhee.jsonl
+
+Parameters:
+- -format: svg (default), a diagram with one horizontal line per node, time
+  going from left to right, or mermaid, a sequence diagram
+- -o: output file, standard output if empty
+- -from, -to: only render the events in this time window
+- -states: also render the state events of the algorithms
+- -check: do not render, check that the trace is causally consistent with
+  its timestamps and vector clocks, exits with status 1 on violations
+
+A message is matched to its reception with its id, or, for algorithms that do
+not carry ids, with the first reception of the same type on the same link.
+Messages sent but never received are drawn crossed.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"drinking/Trace"
+)
+
+type Message struct {
+	Send    Trace.Event
+	Receive Trace.Event
+	Lost    bool
+	event   int // index of the send event in Diagram.Events
+}
+
+type Interval struct {
+	Node  int
+	Start int64
+	End   int64
+}
+
+type Diagram struct {
+	NbNodes   int
+	Start     int64
+	End       int64
+	Messages  []Message
+	CS        []Interval
+	States    []Trace.Event
+	Events    []Trace.Event
+}
+
+func linkKey(src int, dst int, msg string) string {
+	return fmt.Sprintf("%d-%d-%s", src, dst, msg)
+}
+
+func buildDiagram(events []Trace.Event, from int64, to int64) Diagram {
+	var d Diagram
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+
+	var sentById = make(map[int]int)            // message id -> index in d.Messages
+	var sentByLink = make(map[string][]int)     // messages without id, in order of sending
+	var enter = make(map[int]int64)
+	d.Start = -1
+	for _, e := range events {
+		if e.Node + 1 > d.NbNodes {
+			d.NbNodes = e.Node + 1
+		}
+		if e.Peer + 1 > d.NbNodes {
+			d.NbNodes = e.Peer + 1
+		}
+		if e.Time < from || (to >= 0 && e.Time > to) {
+			continue
+		}
+		if d.Start == -1 {
+			d.Start = e.Time
+		}
+		d.End = e.Time
+		d.Events = append(d.Events, e)
+		switch e.Type {
+		case Trace.SEND:
+			var m Message
+			m.Send = e
+			m.Lost = true
+			m.event = len(d.Events) - 1
+			d.Messages = append(d.Messages, m)
+			if e.Id != 0 {
+				sentById[e.Id] = len(d.Messages) - 1
+			} else {
+				var key string = linkKey(e.Node, e.Peer, e.Msg)
+				sentByLink[key] = append(sentByLink[key], len(d.Messages) - 1)
+			}
+		case Trace.RECEIVE:
+			var idx int = -1
+			if e.Id != 0 {
+				if i, ok := sentById[e.Id]; ok && d.Messages[i].Lost {
+					idx = i
+				}
+			} else {
+				var key string = linkKey(e.Peer, e.Node, e.Msg)
+				if len(sentByLink[key]) > 0 {
+					idx = sentByLink[key][0]
+					sentByLink[key] = sentByLink[key][1:]
+				}
+			}
+			if idx >= 0 {
+				d.Messages[idx].Receive = e
+				d.Messages[idx].Lost = false
+			}
+		case Trace.ENTER_CS:
+			enter[e.Node] = e.Time
+		case Trace.RELEASE_CS:
+			var start, ok = enter[e.Node]
+			if !ok {
+				start = d.Start
+			}
+			d.CS = append(d.CS, Interval{Node: e.Node, Start: start, End: e.Time})
+			delete(enter, e.Node)
+		case Trace.STATE:
+			d.States = append(d.States, e)
+		}
+	}
+	// nodes still in their CS at the end of the window
+	for node, start := range enter {
+		d.CS = append(d.CS, Interval{Node: node, Start: start, End: d.End})
+	}
+	return d
+}
+
+func stateString(state interface{}) string {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Sprint(state)
+	}
+	return string(content)
+}
+
+////////////////////////////////////////////////////////////
+// Mermaid
+////////////////////////////////////////////////////////////
+func writeMermaid(w io.Writer, d Diagram, states bool) {
+	fmt.Fprintln(w, "sequenceDiagram")
+	for i := 0; i < d.NbNodes; i++ {
+		fmt.Fprintf(w, "    participant N%d as Node #%d\n", i, i)
+	}
+	var lost = make(map[int]bool)
+	for _, m := range d.Messages {
+		lost[m.event] = m.Lost
+	}
+	var inCS = make(map[int]bool)
+	for i, e := range d.Events {
+		switch e.Type {
+		case Trace.SEND:
+			var arrow string = "->>"
+			var label string = e.Msg
+			if e.Id != 0 {
+				label += fmt.Sprintf(" #%d", e.Id)
+			}
+			if lost[i] {
+				arrow = "-x"
+				label += " (lost)"
+			}
+			fmt.Fprintf(w, "    N%d%sN%d: %s\n", e.Node, arrow, e.Peer, label)
+		case Trace.ENTER_CS:
+			inCS[e.Node] = true
+			fmt.Fprintf(w, "    Note over N%d: enter CS\n", e.Node)
+			fmt.Fprintf(w, "    activate N%d\n", e.Node)
+		case Trace.RELEASE_CS:
+			if inCS[e.Node] {
+				fmt.Fprintf(w, "    deactivate N%d\n", e.Node)
+				inCS[e.Node] = false
+			}
+			fmt.Fprintf(w, "    Note over N%d: release CS\n", e.Node)
+		case Trace.STATE:
+			if states {
+				// ; and # have a special meaning in mermaid
+				fmt.Fprintf(w, "    Note right of N%d: %s\n", e.Node, mermaidEscape(stateString(e.State)))
+			}
+		}
+	}
+	for node, in := range inCS {
+		if in {
+			fmt.Fprintf(w, "    deactivate N%d\n", node)
+		}
+	}
+}
+
+func mermaidEscape(s string) string {
+	var val string = ""
+	for _, c := range s {
+		switch c {
+		case ';':
+			val += "#59;"
+		case '#':
+			val += "#35;"
+		default:
+			val += string(c)
+		}
+	}
+	return val
+}
+
+////////////////////////////////////////////////////////////
+// SVG
+////////////////////////////////////////////////////////////
+var SVG_MARGIN      float64 = 80
+var SVG_NODE_HEIGHT float64 = 80
+
+func writeSVG(w io.Writer, d Diagram, width float64, states bool) {
+	var height float64 = 2 * SVG_MARGIN + float64(d.NbNodes - 1) * SVG_NODE_HEIGHT
+	var duration float64 = float64(d.End - d.Start)
+	if duration <= 0 {
+		duration = 1
+	}
+	var x = func(t int64) float64 {
+		return SVG_MARGIN + float64(t - d.Start) * (width - 2 * SVG_MARGIN) / duration
+	}
+	var y = func(node int) float64 {
+		return SVG_MARGIN + float64(node) * SVG_NODE_HEIGHT
+	}
+
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" font-family=\"monospace\" font-size=\"10\">\n", width, height)
+	fmt.Fprintln(w, "<defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\"><path d=\"M 0 0 L 10 5 L 0 10 z\"/></marker></defs>")
+	fmt.Fprintln(w, "<rect width=\"100%\" height=\"100%\" fill=\"white\"/>")
+	fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%.1f\">%s</text>\n", SVG_MARGIN, SVG_MARGIN / 3, html.EscapeString(fmt.Sprintf("from %v to %v", time.Duration(d.Start) * time.Microsecond, time.Duration(d.End) * time.Microsecond)))
+
+	// process lines
+	for i := 0; i < d.NbNodes; i++ {
+		fmt.Fprintf(w, "<text x=\"5\" y=\"%.1f\" font-size=\"12\">Node #%d</text>\n", y(i) + 4, i)
+		fmt.Fprintf(w, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"black\"/>\n", SVG_MARGIN, y(i), width - SVG_MARGIN, y(i))
+	}
+	// critical sections
+	for _, cs := range d.CS {
+		fmt.Fprintf(w, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"8\" fill=\"red\" opacity=\"0.6\"><title>Node #%d in CS</title></rect>\n", x(cs.Start), y(cs.Node) - 4, x(cs.End) - x(cs.Start), cs.Node)
+	}
+	// messages
+	for _, m := range d.Messages {
+		var label string = m.Send.Msg
+		if m.Send.Id != 0 {
+			label += fmt.Sprintf(" #%d", m.Send.Id)
+		}
+		var x1 float64 = x(m.Send.Time)
+		var y1 float64 = y(m.Send.Node)
+		var x2, y2 float64
+		if m.Lost {
+			// lost messages are drawn going half way
+			x2 = x1 + 20
+			y2 = (y(m.Send.Node) + y(m.Send.Peer)) / 2
+			label += " (lost)"
+			fmt.Fprintf(w, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"gray\" stroke-dasharray=\"4 2\"><title>%s</title></line>\n", x1, y1, x2, y2, html.EscapeString(label))
+			fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%.1f\" fill=\"gray\">x</text>\n", x2 - 3, y2 + 3)
+			continue
+		}
+		x2 = x(m.Receive.Time)
+		y2 = y(m.Receive.Node)
+		fmt.Fprintf(w, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"blue\" marker-end=\"url(#arrow)\"><title>%s</title></line>\n", x1, y1, x2, y2, html.EscapeString(label))
+		fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%.1f\" fill=\"blue\">%s</text>\n", (x1 + x2) / 2 + 2, (y1 + y2) / 2, html.EscapeString(m.Send.Msg))
+	}
+	// states
+	if states {
+		for _, e := range d.States {
+			fmt.Fprintf(w, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"3\" fill=\"green\"><title>%s</title></circle>\n", x(e.Time), y(e.Node), html.EscapeString(stateString(e.State)))
+		}
+	}
+	fmt.Fprintln(w, "</svg>")
+}
+
+func main() {
+	formatPtr := flag.String("format", "svg", "output format: svg or mermaid")
+	outputPtr := flag.String("o", "", "output file, standard output if empty")
+	fromPtr := flag.Duration("from", 0, "start of the time window")
+	toPtr := flag.Duration("to", -1, "end of the time window, end of the trace if negative")
+	widthPtr := flag.Float64("width", 1600, "width of the SVG diagram")
+	statesPtr := flag.Bool("states", false, "render the state events")
+	checkPtr := flag.Bool("check", false, "check the causal consistency of the trace instead of rendering it")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: SpaceTime [flags] trace.jsonl")
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	events, err := Trace.Read(file)
+	file.Close()
+	if err != nil {
+		log.Fatal(flag.Arg(0), ": ", err)
+	}
+	if *checkPtr {
+		var violations []error = Trace.CheckCausality(events)
+		for _, v := range violations {
+			fmt.Fprintln(os.Stderr, v)
+		}
+		if len(violations) > 0 {
+			log.Fatal(len(violations), " causality violations in ", len(events), " events")
+		}
+		fmt.Println(len(events), "events, causally consistent")
+		return
+	}
+
+	var to int64 = -1
+	if *toPtr >= 0 {
+		to = int64(*toPtr / time.Microsecond)
+	}
+	var d Diagram = buildDiagram(events, int64(*fromPtr / time.Microsecond), to)
+
+	var out io.Writer = os.Stdout
+	if *outputPtr != "" {
+		f, err := os.Create(*outputPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	var w = bufio.NewWriter(out)
+	defer w.Flush()
+	switch *formatPtr {
+	case "svg":
+		writeSVG(w, d, *widthPtr, *statesPtr)
+	case "mermaid":
+		writeMermaid(w, d, *statesPtr)
+	default:
+		log.Fatal("Unknown format ", *formatPtr)
+	}
+}
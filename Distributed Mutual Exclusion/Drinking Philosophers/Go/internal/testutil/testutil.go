@@ -0,0 +1,119 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Helpers of the tests of the algorithms: a short workload, a deadline on the
+run, and a mutual exclusion check on the trace of the run, independent of the
+checks of the algorithms:
+  var tracer *testutil.Tracer = testutil.NewTracer()
+  Tracer = tracer.Tracer
+  ... run the nodes, testutil.Wait(t, &wg, time.Minute) ...
+  testutil.CheckExclusion(t, tracer, testutil.Requests(workload, seed))
+*/
+
+package testutil
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"drinking/Topology"
+	"drinking/Trace"
+	"drinking/Workload"
+)
+
+// NewWorkload returns requests of 1 to size resources among nbResources, with short think and CS durations
+func NewWorkload(t *testing.T, nbResources int, size int) *Workload.Config {
+	t.Helper()
+	sizes, err := Workload.NewSizeDistribution(Workload.UNIFORM, size, nbResources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c *Workload.Config = Workload.NewConfig(nbResources, sizes)
+	c.Think, _ = Workload.ParseDuration("0-20ms")
+	c.CS, _ = Workload.ParseDuration("0-20ms")
+	return c
+}
+
+// Wait fails the test if the nodes are not done after timeout
+func Wait(t *testing.T, wg *sync.WaitGroup, timeout time.Duration) {
+	t.Helper()
+	var done = make(chan bool)
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatal("run did not end after ", timeout)
+	}
+}
+
+// Tracer records a trace in memory
+type Tracer struct {
+	*Trace.Tracer
+	buffer *bytes.Buffer
+}
+
+func NewTracer() *Tracer {
+	var buffer bytes.Buffer
+	return &Tracer{Trace.New(&buffer), &buffer}
+}
+
+// Requests returns the resources of the k-th request of each node, drawn
+// from workload as the nodes do: node i draws its requests in order from the
+// generator of seed seed + i
+func Requests(workload *Workload.Config, seed int64) func(node int, k int) []int {
+	var generators = make(map[int]*Workload.Generator)
+	var requests = make(map[int][][]int)
+	return func(node int, k int) []int {
+		if generators[node] == nil {
+			generators[node] = workload.Generator(node, seed + int64(node))
+		}
+		for len(requests[node]) <= k {
+			var next Workload.Request = generators[node].Next()
+			requests[node] = append(requests[node], append([]int(nil), next.Resources...))
+		}
+		return requests[node][k]
+	}
+}
+
+// Edges returns the resources of the philosophers of graph: a philosopher
+// uses the edges to all its neighbors, numbered i * NbNodes + j with i < j
+func Edges(graph *Topology.Graph) func(node int, k int) []int {
+	return func(node int, k int) []int {
+		var edges []int
+		for _, j := range graph.Neighbors[node] {
+			edges = append(edges, min(node, j) * graph.NbNodes + max(node, j))
+		}
+		return edges
+	}
+}
+
+// CheckExclusion fails the test if the trace of tracer has two nodes in CS
+// with the same resource, resources returns the resources of the k-th CS of
+// a node. The nodes must be stopped
+func CheckExclusion(t *testing.T, tracer *Tracer, resources func(node int, k int) []int) {
+	t.Helper()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	events, err := Trace.Read(tracer.buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nbCS int = 0
+	for _, e := range events {
+		if e.Type == Trace.ENTER_CS {
+			nbCS ++
+		}
+	}
+	if nbCS == 0 {
+		t.Error("no CS entry traced")
+	}
+	for _, err := range Trace.CheckExclusion(events, resources) {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,7 @@
+module lamportbakery
+
+go 1.21
+
+require drinking v0.0.0
+
+replace drinking => "../../../Drinking Philosophers/Go"
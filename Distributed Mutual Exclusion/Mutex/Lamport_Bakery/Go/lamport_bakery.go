@@ -2,7 +2,7 @@
   Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
 
 How-to run: 
-  go build lamport_bakery.go
+  go build -o lamport_bakery .
   ./lamport_bakery 2>&1 |tee /tmp/tmp.log
 
 Parameters:
@@ -34,6 +34,8 @@ import (
 	"strings"
 	"strconv"
 	"time"
+
+	"drinking/Clock"
 )
 
 /*
@@ -58,7 +60,9 @@ type ByTimestamp []Request
 
 func (a ByTimestamp) Len() int           { return len(a) }
 func (a ByTimestamp) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByTimestamp) Less(i, j int) bool { return a[i].timestamp < a[j].timestamp }
+func (a ByTimestamp) Less(i, j int) bool {
+	return Clock.CompareLamport(a[i].timestamp, a[i].id, a[j].timestamp, a[j].id) == Clock.BEFORE
+}
 
 
 type Request struct {
@@ -68,7 +72,7 @@ type Request struct {
 
 type Node struct {
 	id         int
-	timestamp  int
+	clock      *Clock.Lamport
 	inCS       bool      
 	queue      []Request
 	replies    [4]int
@@ -78,7 +82,7 @@ type Node struct {
 
 func (n *Node) String() string {
 	var val string
-	val = fmt.Sprintf("Node #%d, timestamp=%d\n", n.id, n.timestamp)
+	val = fmt.Sprintf("Node #%d, timestamp=%d\n", n.id, n.clock.Now())
 	for i := 0; i < len(n.queue); i ++ {
 		val = val + fmt.Sprintf("  req #%d, timestamp=%d\n", n.queue[i].id, n.queue[i].timestamp)
 		
@@ -105,9 +109,11 @@ func (n *Node) sendRequestToAllOtherNodes(r Request) {
 
 func (n *Node) sendReleaseToAllOtherNodes() {
 	log.Print("node #", n.id," sendReleaseToAllOtherNodes")	
+	// a single release event, with the same timestamp for all the nodes
+	var timestamp int = n.clock.Tick()
 	for i := 0; i < len(n.messages); i++ {
 		if n.id != i {
-			var content = fmt.Sprintf("REL%d%d", n.id, n.timestamp)
+			var content = fmt.Sprintf("REL%d%d", n.id, timestamp)
 			log.Print("node #", n.id, " , SENDING release ", content, " to node #", i)	
 			n.messages[i] <- content
 		}
@@ -125,8 +131,7 @@ func (n *Node) requestCS() {
 	var r Request 
 	r.id = n.id
 	
-	n.timestamp++
-	r.timestamp = n.timestamp
+	r.timestamp = n.clock.Tick()
 	
 	n.queue = append(n.queue, r)
 	sort.Sort(ByTimestamp(n.queue))
@@ -157,13 +162,6 @@ func (n *Node) releaseCS() {
 	}
 }
 
-func Max(x, y int) int {
-        if x < y {
-                return y
-        }
-        return x
-}
-
 func (n *Node) waitForReplies() {	
 	// log.Print("node #", n.id," waitForReplies")	
 	select {
@@ -177,7 +175,7 @@ func (n *Node) waitForReplies() {
 			if err2 != nil {
 				log.Fatal(err2)
 			}
-			n.timestamp = Max(ts, n.timestamp) + 1
+			n.clock.Receive(ts)
 			n.replies[requester] = 1
 			log.Print("node #", n.id, " , RECEIVED reply from node #", requester, n.replies)	
 
@@ -190,9 +188,9 @@ func (n *Node) waitForReplies() {
 			if err2 != nil {
 				log.Fatal(err2)
 			}
-			n.timestamp = Max(ts, n.timestamp) + 1
+			n.clock.Receive(ts)
 
-			var content = fmt.Sprintf("REP%d%d", n.id, n.timestamp)
+			var content = fmt.Sprintf("REP%d%d", n.id, n.clock.Tick())
 			log.Print("node #", n.id, " , SENDING reply ", content, " to node #", requester)	
 			var r Request 
 			r.id = requester
@@ -209,7 +207,7 @@ func (n *Node) waitForReplies() {
 			if err2 != nil {
 				log.Fatal(err2)
 			}
-			n.timestamp = Max(ts, n.timestamp) + 1
+			n.clock.Receive(ts)
 			for i := 0; i < len(n.queue); i++ {
 				if n.queue[i].id == requester {
 					n.queue = append(n.queue[:i], n.queue[i+1:]...)
@@ -259,7 +257,7 @@ func main() {
 	for i := 0; i < len(nodes); i++ {
 		nodes[i].id = i
 		nodes[i].inCS = false
-		nodes[i].timestamp = i * 10
+		nodes[i].clock = Clock.NewLamport(i * 10)
 		for r := 0; r < len(nodes); r++ {
 			nodes[i].replies[r] = 0
 		}
@@ -0,0 +1,3 @@
+module lockservice
+
+go 1.21
@@ -0,0 +1,648 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Lock service: each daemon is a node of a cluster running the Naimi-Trehel
+algorithm, it grants named locks to its local clients with a lease and a
+fencing token.
+
+How-to run, a cluster of 3 nodes on one host:
+  go build -o lock-service .
+  ./lock-service -id=0 -peers=127.0.0.1:7000,127.0.0.1:7001,127.0.0.1:7002 -listen=/tmp/lock-0.sock &
+  ./lock-service -id=1 -peers=127.0.0.1:7000,127.0.0.1:7001,127.0.0.1:7002 -listen=/tmp/lock-1.sock &
+  ./lock-service -id=2 -peers=127.0.0.1:7000,127.0.0.1:7001,127.0.0.1:7002 -listen=/tmp/lock-2.sock &
+then, from a client of node #1:
+  curl --unix-socket /tmp/lock-1.sock -X POST 'http://lock/acquire?name=db&ttl=10s&wait=30s'
+  {"Name":"db","Fence":3,"Expires":"..."}
+  curl --unix-socket /tmp/lock-1.sock -X POST 'http://lock/renew?name=db&fence=3&ttl=10s'
+  curl --unix-socket /tmp/lock-1.sock -X POST 'http://lock/release?name=db&fence=3'
+  curl --unix-socket /tmp/lock-1.sock http://lock/locks
+
+Parameters:
+- -id: id of the node, its index in -peers
+- -peers: addresses host:port of all the nodes, in order of id. A node listens
+  on its own address for the messages of the other nodes only
+- -listen: Unix socket of the local clients, the only access to the client API
+- -ttl: lease of a lock when the client does not set one
+
+Client API, over HTTP:
+- POST /acquire?name=N&ttl=D&wait=D: waits for lock N, at most wait if set,
+  and returns its lease as JSON: the name, the fencing token and the
+  expiration time. 408 when wait elapsed
+- POST /renew?name=N&fence=F&ttl=D: extends the lease F of lock N by ttl
+  from now. 409 when the lease is not held anymore
+- POST /release?name=N&fence=F: releases the lease F of lock N. 409 when the
+  lease is not held anymore
+- GET /locks: the state of the locks known by the node
+
+Leases and fencing tokens
+* A lease expires after its ttl unless renewed, the node then releases the
+  lock as the client would, and the token goes to the next requester. A
+  client must not assume it still holds a lock after the expiration time
+* The token of each lock carries a counter incremented at each grant, the
+  fencing token. It is increasing across the cluster: a storage that rejects
+  the writes with a fencing token lower than the last one it has seen is
+  protected from a client whose lease expired while it was paused
+
+Protocol
+Each lock is a Naimi-Trehel instance of its own, created when the node first
+hears of it: node #0 initially holds the token and is the last requester known
+by all the others, so that the nodes agree on the initial state without
+exchanging messages.
+* A node requesting a lock sends REQ to its last and becomes the root of the
+  tree of the lock. A node receiving REQ forwards it to its last, or, being
+  the root, sends the token if it does not use the lock, or keeps the
+  requester as its next otherwise. The receiver becomes the last of the
+  requester
+* The clients of a node wait in FIFO order: the node requests the lock for
+  the first one, grants the lease when the token arrives, and on release
+  sends the token to its next, if any, then requests it again for its next
+  client
+* A node forgets a lock once its state is the initial one again: not used,
+  without the token, and with node #0 as last. The token holder and the nodes
+  whose last is another node keep it, it routes the requests and carries the
+  fencing token
+The messages between the nodes are sent over HTTP, in order to each node, a
+message is retried until its destination receives it. A message may then be
+received twice: each one carries a sequence number per sender and
+destination, and a node drops the messages older than the last one it
+received from the same sender. A restarted node numbers its messages from 1
+again, so they also carry its epoch, the time it started: a node receiving a
+newer epoch of a sender resets its sequence number, and rejects the
+messages of an older epoch with 410 Gone, which the sender does not retry.
+The state of the locks is not recovered by a restarted node.
+
+References :
+* M. Naimi, M. Tréhel, A. Arnold, "A Log(N) Distributed Mutual Exclusion Algorithm Based on the Path Reversal", Journal of Parallel and Distributed Computing, 34, 1-13 (1996).
+* M. Kleppmann, "How to do distributed locking", 2016, for the fencing tokens
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* global variable declaration */
+var DEFAULT_TTL time.Duration = 10 * time.Second
+var RETRY_INTERVAL time.Duration = 100 * time.Millisecond
+
+// Message types
+var REQ_TYPE   string = "REQ"
+var TOKEN_TYPE string = "TOKEN"
+
+// Message between the nodes
+type Message struct {
+	Type      string
+	Lock      string
+	From      int
+	Epoch     int64 // start time of From in nanoseconds, see NewServer
+	Seq       uint64 // sequence number of the messages of From to the destination
+	Requester int // REQ only
+	Fence     uint64 // TOKEN only, the fencing token of the last grant
+}
+
+// Lease of a lock granted to a client
+type Lease struct {
+	Name    string
+	Fence   uint64
+	Expires time.Time
+}
+
+// waiter is a client waiting for a lock
+type waiter struct {
+	ttl     time.Duration
+	granted chan Lease // receives the lease, buffered
+}
+
+// lock is the Naimi-Trehel state of a node for one lock
+type lock struct {
+	name       string
+	hasToken   bool
+	requesting bool // from the request until the release of the lock
+	next       int // the dynamic distributed list
+	last       int // the last requester, -1 when the node is the root
+	fence      uint64 // fencing token of the last grant, carried by the token
+	holder     *Lease // the lease of the client holding the lock, nil if none
+	expiry     *time.Timer
+	waiters    []*waiter // the clients waiting for the lock, in FIFO order
+}
+
+type Server struct {
+	id        int
+	peers     []string // addresses of the nodes
+	epoch     int64 // start time of the node, distinguishes its incarnations
+	mutex     sync.Mutex // protects locks, stopped, outbox, sent, received and epochs
+	locks     map[string]*lock
+	client    *http.Client
+	stopped   bool
+	stop      chan bool // closed by Close
+	outbox    [][]Message // messages to each node not received yet, in order
+	wake      []chan bool // wakes the routine sending to each node
+	sent      []uint64 // sequence number of the last message to each node
+	received  []uint64 // sequence number of the last message from each node
+	epochs    []int64 // epoch of the last message from each node
+	peerMux   *http.ServeMux
+	clientMux *http.ServeMux
+}
+
+func (l *lock) String() string {
+	var val string
+	val = fmt.Sprintf("Lock %q, has_token=%v, requesting=%v, next=%d, last=%d, fence=%d, %d waiting",
+		l.name,
+		l.hasToken,
+		l.requesting,
+		l.next,
+		l.last,
+		l.fence,
+		len(l.waiters))
+	return val
+}
+
+// NewServer creates node id of the cluster of the nodes at the addresses peers
+func NewServer(id int, peers []string) *Server {
+	var s Server
+	s.id = id
+	s.epoch = time.Now().UnixNano()
+	s.peers = peers
+	s.locks = make(map[string]*lock)
+	s.client = &http.Client{Timeout: 5 * time.Second}
+	s.stop = make(chan bool)
+	s.outbox = make([][]Message, len(peers))
+	s.wake = make([]chan bool, len(peers))
+	s.sent = make([]uint64, len(peers))
+	s.received = make([]uint64, len(peers))
+	s.epochs = make([]int64, len(peers))
+	for dst := range peers {
+		s.wake[dst] = make(chan bool, 1)
+		if dst != id {
+			go s.forward(dst)
+		}
+	}
+	s.peerMux = http.NewServeMux()
+	s.peerMux.HandleFunc("/peer", s.handlePeer)
+	s.clientMux = http.NewServeMux()
+	s.clientMux.HandleFunc("/acquire", s.handleAcquire)
+	s.clientMux.HandleFunc("/renew", s.handleRenew)
+	s.clientMux.HandleFunc("/release", s.handleRelease)
+	s.clientMux.HandleFunc("/locks", s.handleLocks)
+	return &s
+}
+
+// PeerHandler serves the messages of the other nodes
+func (s *Server) PeerHandler() http.Handler {
+	return s.peerMux
+}
+
+// ClientHandler serves the client API
+func (s *Server) ClientHandler() http.Handler {
+	return s.clientMux
+}
+
+// Close stops the lease timers and the messages still retried
+func (s *Server) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	close(s.stop)
+	for _, l := range s.locks {
+		if l.expiry != nil {
+			l.expiry.Stop()
+		}
+	}
+}
+
+// newLock returns lock name in its initial state
+func (s *Server) newLock(name string) *lock {
+	var l = &lock{name: name, next: -1, last: 0}
+	if l.last == s.id {
+		l.hasToken = true
+		l.last = -1
+	}
+	return l
+}
+
+// get returns lock name, created in its initial state if the node did not
+// hear of it yet. Called with the mutex held
+func (s *Server) get(name string) *lock {
+	if l, ok := s.locks[name]; ok {
+		return l
+	}
+	var l *lock = s.newLock(name)
+	s.locks[name] = l
+	return l
+}
+
+// forgetIfIdle removes lock l when it is back in its initial state, get
+// creates it again as it was. Called with the mutex held
+func (s *Server) forgetIfIdle(l *lock) {
+	if l.requesting || l.holder != nil || len(l.waiters) > 0 || l.next != -1 {
+		return
+	}
+	var initial *lock = s.newLock(l.name)
+	if l.hasToken != initial.hasToken || l.last != initial.last || (l.hasToken && l.fence != initial.fence) {
+		return
+	}
+	log.Print("Node #", s.id, " forgets lock ", l.name)
+	delete(s.locks, l.name)
+}
+
+////////////////////////////////////////////////////////////
+// Naimi-Trehel
+////////////////////////////////////////////////////////////
+
+// send queues m to node dst, the routine of dst delivers it. Called with the mutex held
+func (s *Server) send(dst int, m Message) {
+	s.sent[dst] ++
+	m.From = s.id
+	m.Epoch = s.epoch
+	m.Seq = s.sent[dst]
+	s.outbox[dst] = append(s.outbox[dst], m)
+	select {
+	case s.wake[dst] <- true:
+	default:
+	}
+}
+
+// forward delivers the messages to node dst in order, until the server is closed
+func (s *Server) forward(dst int) {
+	for {
+		s.mutex.Lock()
+		var stopped bool = s.stopped
+		var pending []Message = s.outbox[dst]
+		s.mutex.Unlock()
+		if stopped {
+			return
+		}
+		if len(pending) == 0 {
+			select {
+			case <-s.wake[dst]:
+			case <-s.stop:
+			}
+			continue
+		}
+		s.deliver(dst, pending[0])
+		s.mutex.Lock()
+		s.outbox[dst] = s.outbox[dst][1:]
+		s.mutex.Unlock()
+	}
+}
+
+// deliver posts m to node dst, retrying until it is received or the server is closed
+func (s *Server) deliver(dst int, m Message) {
+	content, err := json.Marshal(m)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for {
+		response, err := s.client.Post("http://" + s.peers[dst] + "/peer", "application/json", bytes.NewReader(content))
+		if err == nil {
+			response.Body.Close()
+			if response.StatusCode == http.StatusOK {
+				return
+			}
+			if response.StatusCode == http.StatusGone {
+				log.Print("Node #", s.id, ", ", m.Type, " of lock ", m.Lock, " dropped by Node #", dst, ", it knows a newer epoch")
+				return
+			}
+			err = fmt.Errorf("%s", response.Status)
+		}
+		log.Print("Node #", s.id, ", ", m.Type, " of lock ", m.Lock, " to Node #", dst, " failed, retrying: ", err)
+		select {
+		case <-time.After(RETRY_INTERVAL):
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// requestCS sends REQ to the last requester of the lock, unless the node is
+// the root. Called with the mutex held
+func (s *Server) requestCS(l *lock) {
+	l.requesting = true
+	if l.last != -1 {
+		var last int = l.last
+		l.last = -1
+		log.Print("Node #", s.id, " requests lock ", l.name, ", REQ to last #", last)
+		s.send(last, Message{Type: REQ_TYPE, Lock: l.name, Requester: s.id})
+	}
+}
+
+// grantIfICan gives the lock to the first waiting client when the node holds
+// the token and no client holds the lock. Called with the mutex held
+func (s *Server) grantIfICan(l *lock) {
+	if !l.hasToken || l.holder != nil || len(l.waiters) == 0 {
+		return
+	}
+	var w *waiter = l.waiters[0]
+	l.waiters = l.waiters[1:]
+	l.fence ++
+	var lease = Lease{Name: l.name, Fence: l.fence, Expires: time.Now().Add(w.ttl)}
+	l.holder = &lease
+	var fence uint64 = l.fence
+	l.expiry = time.AfterFunc(w.ttl, func() { s.expire(l.name, fence) })
+	log.Print("Node #", s.id, " ######################### grants lock ", l.name, ", fence ", fence)
+	w.granted <- lease
+}
+
+// releaseCS sends the token to the next requester if any, and requests it
+// again when clients are still waiting. Called with the mutex held
+func (s *Server) releaseCS(l *lock) {
+	log.Print("Node #", s.id, " releases lock ", l.name, " #########################")
+	l.holder = nil
+	if l.expiry != nil {
+		l.expiry.Stop()
+		l.expiry = nil
+	}
+	l.requesting = false
+	if l.next != -1 {
+		var next int = l.next
+		l.hasToken = false
+		l.next = -1
+		s.send(next, Message{Type: TOKEN_TYPE, Lock: l.name, Fence: l.fence})
+	}
+	if len(l.waiters) > 0 {
+		s.requestCS(l)
+		s.grantIfICan(l)
+	}
+}
+
+func (s *Server) receiveRequestCS(l *lock, j int) {
+	if l.last == -1 {
+		if l.requesting {
+			l.next = j
+		} else {
+			l.hasToken = false
+			s.send(j, Message{Type: TOKEN_TYPE, Lock: l.name, Fence: l.fence})
+		}
+	} else {
+		// Forwarding request to last
+		s.send(l.last, Message{Type: REQ_TYPE, Lock: l.name, Requester: j})
+	}
+	l.last = j
+}
+
+func (s *Server) receiveToken(l *lock, fence uint64) {
+	log.Print("** Node #", s.id, " Got TOKEN of lock ", l.name, " **")
+	l.hasToken = true
+	l.fence = fence
+	if len(l.waiters) == 0 {
+		// the clients gave up while the token was on its way
+		s.releaseCS(l)
+		return
+	}
+	s.grantIfICan(l)
+}
+
+// expire releases lock name if its lease fence is still held and was not
+// renewed since the timer fired
+func (s *Server) expire(name string, fence uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var l *lock = s.locks[name]
+	if s.stopped || l == nil || l.holder == nil || l.holder.Fence != fence || time.Now().Before(l.holder.Expires) {
+		return
+	}
+	log.Print("Node #", s.id, " lease ", fence, " of lock ", name, " expired")
+	s.releaseCS(l)
+	s.forgetIfIdle(l)
+}
+
+////////////////////////////////////////////////////////////
+// HTTP handlers
+////////////////////////////////////////////////////////////
+
+func (s *Server) handlePeer(w http.ResponseWriter, r *http.Request) {
+	var m Message
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if m.Type != REQ_TYPE && m.Type != TOKEN_TYPE {
+		http.Error(w, "unknown message type " + m.Type, http.StatusBadRequest)
+		return
+	}
+	if m.From < 0 || m.From >= len(s.peers) || m.From == s.id {
+		http.Error(w, fmt.Sprintf("bad sender %d", m.From), http.StatusBadRequest)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if m.Epoch < s.epochs[m.From] {
+		http.Error(w, fmt.Sprintf("epoch %d of Node #%d is over", m.Epoch, m.From), http.StatusGone)
+		return
+	}
+	if m.Epoch > s.epochs[m.From] {
+		log.Print("Node #", s.id, ", Node #", m.From, " starts epoch ", m.Epoch)
+		s.epochs[m.From] = m.Epoch
+		s.received[m.From] = 0
+	}
+	if m.Seq <= s.received[m.From] {
+		log.Print("Node #", s.id, " drops ", m.Type, " #", m.Seq, " of lock ", m.Lock, " from Node #", m.From, ", already received")
+		return
+	}
+	s.received[m.From] = m.Seq
+	var l *lock = s.get(m.Lock)
+	if m.Type == REQ_TYPE {
+		s.receiveRequestCS(l, m.Requester)
+	} else {
+		s.receiveToken(l, m.Fence)
+	}
+	s.forgetIfIdle(l)
+}
+
+// durationParam returns the duration parameter key of r, def when absent
+func durationParam(r *http.Request, key string, def time.Duration) (time.Duration, error) {
+	var value string = r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("must be positive")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s=%s: %v", key, value, err)
+	}
+	return d, nil
+}
+
+// leaseParams returns the name and the fencing token of the lease of r
+func leaseParams(r *http.Request) (string, uint64, error) {
+	var name string = r.URL.Query().Get("name")
+	if name == "" {
+		return "", 0, fmt.Errorf("name is missing")
+	}
+	fence, err := strconv.ParseUint(r.URL.Query().Get("fence"), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("fence: %v", err)
+	}
+	return name, fence, nil
+}
+
+func writeLease(w http.ResponseWriter, lease Lease) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lease)
+}
+
+func (s *Server) handleAcquire(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var name string = r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name is missing", http.StatusBadRequest)
+		return
+	}
+	ttl, err := durationParam(r, "ttl", DEFAULT_TTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wait, err := durationParam(r, "wait", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var client = &waiter{ttl: ttl, granted: make(chan Lease, 1)}
+	s.mutex.Lock()
+	var l *lock = s.get(name)
+	l.waiters = append(l.waiters, client)
+	if !l.requesting {
+		s.requestCS(l)
+	}
+	s.grantIfICan(l)
+	s.mutex.Unlock()
+
+	// no limit unless wait is set
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timeout = time.After(wait)
+	}
+	select {
+	case lease := <-client.granted:
+		writeLease(w, lease)
+		return
+	case <-timeout:
+	case <-r.Context().Done():
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case lease := <-client.granted:
+		// granted in the meantime, nobody will release it
+		if l.holder != nil && l.holder.Fence == lease.Fence {
+			s.releaseCS(l)
+		}
+	default:
+		for k := range l.waiters {
+			if l.waiters[k] == client {
+				l.waiters = append(l.waiters[:k], l.waiters[k + 1:]...)
+				break
+			}
+		}
+	}
+	s.forgetIfIdle(l)
+	http.Error(w, fmt.Sprintf("lock %s not granted after %v", name, wait), http.StatusRequestTimeout)
+}
+
+func (s *Server) handleRenew(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	name, fence, err := leaseParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ttl, err := durationParam(r, "ttl", DEFAULT_TTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var l *lock = s.locks[name]
+	if l == nil || l.holder == nil || l.holder.Fence != fence {
+		http.Error(w, fmt.Sprintf("lease %d of lock %s is not held", fence, name), http.StatusConflict)
+		return
+	}
+	l.holder.Expires = time.Now().Add(ttl)
+	l.expiry.Reset(ttl)
+	writeLease(w, *l.holder)
+}
+
+func (s *Server) handleRelease(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	name, fence, err := leaseParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var l *lock = s.locks[name]
+	if l == nil || l.holder == nil || l.holder.Fence != fence {
+		http.Error(w, fmt.Sprintf("lease %d of lock %s is not held", fence, name), http.StatusConflict)
+		return
+	}
+	s.releaseCS(l)
+	s.forgetIfIdle(l)
+}
+
+func (s *Server) handleLocks(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, l := range s.locks {
+		fmt.Fprintln(w, l)
+	}
+}
+
+func main() {
+	idPtr := flag.Int("id", 0, "id of the node, its index in -peers")
+	peersPtr := flag.String("peers", "127.0.0.1:7000", "comma separated addresses host:port of the nodes, in order of id")
+	listenPtr := flag.String("listen", "", "Unix socket of the clients")
+	ttlPtr := flag.Duration("ttl", DEFAULT_TTL, "lease of a lock when the client does not set one")
+	flag.Parse()
+
+	var peers []string = strings.Split(*peersPtr, ",")
+	if *idPtr < 0 || *idPtr >= len(peers) {
+		log.Fatal("-id must be between 0 and ", len(peers) - 1, ", got ", *idPtr)
+	}
+	if *listenPtr == "" {
+		log.Fatal("-listen is missing, the clients connect to a Unix socket")
+	}
+	DEFAULT_TTL = *ttlPtr
+	var s *Server = NewServer(*idPtr, peers)
+	log.Print("Node #", *idPtr, " of ", len(peers), " listening on ", peers[*idPtr])
+
+	// a socket left by a previous run
+	os.Remove(*listenPtr)
+	listener, err := net.Listen("unix", *listenPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Node #", *idPtr, " clients on ", *listenPtr)
+	go func() {
+		log.Fatal(http.Serve(listener, s.ClientHandler()))
+	}()
+	log.Fatal(http.ListenAndServe(peers[*idPtr], s.PeerHandler()))
+}
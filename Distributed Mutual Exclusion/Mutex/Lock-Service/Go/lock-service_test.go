@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startCluster runs nbNodes nodes on the loopback, it returns the nodes and
+// the Unix sockets of their clients
+func startCluster(t *testing.T, nbNodes int) ([]*Server, []string) {
+	var listeners []net.Listener
+	var peers []string
+	for i := 0; i < nbNodes; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners = append(listeners, listener)
+		peers = append(peers, listener.Addr().String())
+	}
+	var servers []*Server
+	var sockets []string
+	var dir string = t.TempDir()
+	for i := 0; i < nbNodes; i++ {
+		var s *Server = NewServer(i, peers)
+		var socket string = filepath.Join(dir, fmt.Sprintf("lock-%d.sock", i))
+		clients, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var peerServer = &http.Server{Handler: s.PeerHandler()}
+		var clientServer = &http.Server{Handler: s.ClientHandler()}
+		go peerServer.Serve(listeners[i])
+		go clientServer.Serve(clients)
+		t.Cleanup(func() {
+			s.Close()
+			peerServer.Close()
+			clientServer.Close()
+		})
+		servers = append(servers, s)
+		sockets = append(sockets, socket)
+	}
+	return servers, sockets
+}
+
+// post sends a client request to the node of the Unix socket, it returns the
+// status and the lease if any, 0 when the request failed
+func post(t *testing.T, socket string, query string) (int, Lease) {
+	var client = &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+	var lease Lease
+	response, err := client.Post("http://lock" + query, "", nil)
+	if err != nil {
+		t.Error(err)
+		return 0, lease
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		content, _ := io.ReadAll(response.Body)
+		if len(content) > 0 {
+			if err := json.Unmarshal(content, &lease); err != nil {
+				t.Error(err)
+				return 0, lease
+			}
+		}
+	}
+	return response.StatusCode, lease
+}
+
+// The clients of all the nodes take two locks in turn, no two of them hold
+// the same lock at the same time and the fencing tokens increase
+func TestMutualExclusion(t *testing.T) {
+	_, peers := startCluster(t, 3)
+	var mutex sync.Mutex
+	var holders = make(map[string]int)
+	var lastFence = make(map[string]uint64)
+	var wg sync.WaitGroup
+	for c := 0; c < 6; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			var name string = fmt.Sprintf("lock-%d", c % 2)
+			for k := 0; k < 5; k++ {
+				status, lease := post(t, peers[c % len(peers)], "/acquire?name=" + name + "&ttl=10s")
+				if status != http.StatusOK {
+					t.Error("client #", c, ": acquire ", name, ": ", status)
+					return
+				}
+				mutex.Lock()
+				holders[name] ++
+				if holders[name] > 1 {
+					t.Error("client #", c, ": ", name, " held twice")
+				}
+				if lease.Fence <= lastFence[name] {
+					t.Error("client #", c, ": fence ", lease.Fence, " of ", name, " after ", lastFence[name])
+				}
+				lastFence[name] = lease.Fence
+				mutex.Unlock()
+				time.Sleep(time.Millisecond)
+				mutex.Lock()
+				holders[name] --
+				mutex.Unlock()
+				if status, _ := post(t, peers[c % len(peers)], fmt.Sprintf("/release?name=%s&fence=%d", name, lease.Fence)); status != http.StatusOK {
+					t.Error("client #", c, ": release ", name, ": ", status)
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+	if lastFence["lock-0"] != 15 || lastFence["lock-1"] != 15 {
+		t.Error("last fences ", lastFence, " instead of 15 grants per lock")
+	}
+}
+
+// An expired lease is released for the next client, and can be neither
+// renewed nor released by its former holder
+func TestLeaseExpiry(t *testing.T) {
+	_, peers := startCluster(t, 3)
+	status, first := post(t, peers[1], "/acquire?name=db&ttl=100ms")
+	if status != http.StatusOK {
+		t.Fatal("acquire: ", status)
+	}
+	if status, _ := post(t, peers[2], "/acquire?name=db&wait=20ms"); status != http.StatusRequestTimeout {
+		t.Error("acquire of a held lock: ", status, " instead of ", http.StatusRequestTimeout)
+	}
+	if status, _ := post(t, peers[1], fmt.Sprintf("/renew?name=db&fence=%d&ttl=200ms", first.Fence)); status != http.StatusOK {
+		t.Error("renew: ", status)
+	}
+	status, second := post(t, peers[2], "/acquire?name=db&wait=5s")
+	if status != http.StatusOK {
+		t.Fatal("acquire after expiry: ", status)
+	}
+	if second.Fence <= first.Fence {
+		t.Error("fence ", second.Fence, " after ", first.Fence)
+	}
+	for _, query := range []string{"/renew?name=db&fence=%d", "/release?name=db&fence=%d"} {
+		if status, _ := post(t, peers[1], fmt.Sprintf(query, first.Fence)); status != http.StatusConflict {
+			t.Error(fmt.Sprintf(query, first.Fence), " of an expired lease: ", status, " instead of ", http.StatusConflict)
+		}
+	}
+	if status, _ := post(t, peers[2], fmt.Sprintf("/release?name=db&fence=%d", second.Fence)); status != http.StatusOK {
+		t.Error("release: ", status)
+	}
+}
+
+// The peer address serves the messages of the nodes only, the Unix socket the
+// clients only
+func TestHandlers(t *testing.T) {
+	var s *Server = NewServer(0, []string{"127.0.0.1:1"})
+	defer s.Close()
+	var tests = []struct {
+		handler http.Handler
+		path    string
+		status  int
+	}{
+		{s.PeerHandler(), "/acquire?name=db", http.StatusNotFound},
+		{s.PeerHandler(), "/locks", http.StatusNotFound},
+		{s.ClientHandler(), "/peer", http.StatusNotFound},
+		{s.ClientHandler(), "/locks", http.StatusOK},
+	}
+	for _, test := range tests {
+		var recorder = httptest.NewRecorder()
+		test.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, test.path, nil))
+		if recorder.Code != test.status {
+			t.Error(test.path, ": ", recorder.Code, " instead of ", test.status)
+		}
+	}
+}
+
+// A message received twice, when its sender retried it, is handled once
+func TestDuplicate(t *testing.T) {
+	var s *Server = NewServer(0, []string{"127.0.0.1:1", "127.0.0.1:1"})
+	defer s.Close()
+	content, _ := json.Marshal(Message{Type: REQ_TYPE, Lock: "db", From: 1, Epoch: 1, Seq: 1, Requester: 1})
+	for k := 0; k < 2; k++ {
+		var recorder = httptest.NewRecorder()
+		s.PeerHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/peer", bytes.NewReader(content)))
+		if recorder.Code != http.StatusOK {
+			t.Error("REQ #", k, ": ", recorder.Code)
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// the first REQ gives the token to node #1, the second one would be
+	// forwarded to it as its last
+	if s.sent[1] != 1 || len(s.outbox[1]) != 1 || s.outbox[1][0].Type != TOKEN_TYPE {
+		t.Error(s.sent[1], " messages sent to Node #1: ", s.outbox[1])
+	}
+}
+
+// The messages of a restarted node, numbered from 1 again, are handled, the
+// ones of its previous epoch are rejected
+func TestRestart(t *testing.T) {
+	var s *Server = NewServer(0, []string{"127.0.0.1:1", "127.0.0.1:1"})
+	defer s.Close()
+	var tests = []struct {
+		m      Message
+		status int
+	}{
+		{Message{Type: REQ_TYPE, Lock: "a", From: 1, Epoch: 1, Seq: 1, Requester: 1}, http.StatusOK},
+		{Message{Type: REQ_TYPE, Lock: "b", From: 1, Epoch: 2, Seq: 1, Requester: 1}, http.StatusOK},
+		{Message{Type: REQ_TYPE, Lock: "c", From: 1, Epoch: 1, Seq: 2, Requester: 1}, http.StatusGone},
+	}
+	for _, test := range tests {
+		content, _ := json.Marshal(test.m)
+		var recorder = httptest.NewRecorder()
+		s.PeerHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/peer", bytes.NewReader(content)))
+		if recorder.Code != test.status {
+			t.Error(test.m, ": ", recorder.Code, " instead of ", test.status)
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// the token of a and b given to node #1
+	if s.sent[1] != 2 {
+		t.Error(s.sent[1], " messages sent to Node #1: ", s.outbox[1])
+	}
+}
+
+// A lease renewed before its expiry is still held after it, with the same
+// fencing token, even if the timer of the first lease fires late
+func TestRenewBeforeExpiry(t *testing.T) {
+	servers, sockets := startCluster(t, 2)
+	status, lease := post(t, sockets[0], "/acquire?name=db&ttl=200ms")
+	if status != http.StatusOK {
+		t.Fatal("acquire: ", status)
+	}
+	time.Sleep(100 * time.Millisecond)
+	status, renewed := post(t, sockets[0], fmt.Sprintf("/renew?name=db&fence=%d&ttl=5s", lease.Fence))
+	if status != http.StatusOK || renewed.Fence != lease.Fence || !renewed.Expires.After(lease.Expires) {
+		t.Fatal("renew: ", status, " ", renewed)
+	}
+	time.Sleep(300 * time.Millisecond)
+	servers[0].expire("db", lease.Fence)
+	if status, _ := post(t, sockets[1], "/acquire?name=db&wait=50ms"); status != http.StatusRequestTimeout {
+		t.Error("acquire of a renewed lease: ", status, " instead of ", http.StatusRequestTimeout)
+	}
+	if status, _ := post(t, sockets[0], fmt.Sprintf("/renew?name=db&fence=%d", lease.Fence)); status != http.StatusOK {
+		t.Error("renew after the first expiry: ", status)
+	}
+	if status, _ := post(t, sockets[0], fmt.Sprintf("/release?name=db&fence=%d", lease.Fence)); status != http.StatusOK {
+		t.Error("release of a renewed lease: ", status)
+	}
+}
+
+// The nodes forget the locks back in their initial state
+func TestForget(t *testing.T) {
+	servers, sockets := startCluster(t, 3)
+	for _, i := range []int{1, 0} {
+		status, lease := post(t, sockets[i], "/acquire?name=db")
+		if status != http.StatusOK {
+			t.Fatal("acquire on Node #", i, ": ", status)
+		}
+		if status, _ := post(t, sockets[i], fmt.Sprintf("/release?name=db&fence=%d", lease.Fence)); status != http.StatusOK {
+			t.Fatal("release on Node #", i, ": ", status)
+		}
+	}
+	// Node #0 holds the token, Node #1 has Node #0 as last again, Node #2 never heard of the lock
+	var expected = []bool{true, false, false}
+	var deadline time.Time = time.Now().Add(5 * time.Second)
+	for i, s := range servers {
+		for {
+			s.mutex.Lock()
+			var _, known = s.locks["db"]
+			s.mutex.Unlock()
+			if known == expected[i] {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Node #", i, " knows lock db: ", known)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Explicit-state model checker of the mutual exclusion algorithms, written as
+pure state machines: the state of a node and a message give the next state
+of the node and the messages it sends, see Spec. This is the Go equivalent of
+a TLA+ or PlusCal specification, checked exhaustively for a few nodes.
+
+How-to run, from Mutex/ModelCheck/Go:
+  go run ./cmd/modelcheck --algo=RicartAgrawala --nodes=3 --requests=2
+  go run ./cmd/modelcheck --algo=NaimiTrehel --nodes=4 --requests=1
+
+Model
+* A global state is the state of every node, the messages in transit and
+  the number of requests of each node
+* A transition is either a local input of a node, REQUEST_CS when it is idle
+  and has requests left, RELEASE_CS when it is in CS, or the delivery of any
+  message in transit: the messages are delivered in every order, the
+  channels are not FIFO
+* The number of requests of each node is bounded, so that the state space is
+  finite. It grows fast with the number of nodes: Ricart-Agrawala with 4
+  nodes and 1 request has more than a million states
+
+The global states are explored in breadth first order, a counterexample is
+then a shortest trace from the initial state to:
+* a safety violation: two nodes in CS at the same time
+* a deadlock: no transition, while a node is still requesting or in CS
+
+Specs, the only two algorithms checked:
+* RicartAgrawala, see RicartAgrawala.go
+* NaimiTrehel, see NaimiTrehel.go
+The programs of Mutex/Ricart-Agrawala and Mutex/Naimi-Trehel run these specs:
+their handlers call Step, and a change of the transitions of a program is a
+change of its spec. What they add around Step is not checked: the channels,
+the write-ahead logs, the crashes and the restarts, and the named locks of
+Ricart-Agrawala, each one a RicartAgrawala instance of its own sharing the
+highest sequence number of the node. A check is a proof for the algorithm
+with the given numbers of nodes and requests, not for the whole program.
+
+Out of scope:
+* Mutex/Lamport_Bakery, a shared memory algorithm: its transitions are the
+  reads and writes of the registers of the other nodes, not messages, and
+  its numbers are unbounded
+* Mutex/Lock-Service, whose safety relies on the expiry of leases and on
+  fencing tokens, that is on time, which this model does not have
+*/
+
+package ModelCheck
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Local inputs of a node
+var REQUEST_CS string = "REQUEST_CS"
+var RELEASE_CS string = "RELEASE_CS"
+
+// Message to a node, sent by a node or a local input when From == To. The
+// fields used depend on the algorithm
+type Message struct {
+	Type      string
+	From      int
+	To        int
+	Seq       int // sequence number, Ricart-Agrawala
+	Requester int // the node requesting the CS, Naimi-Trehel
+}
+
+func (m Message) String() string {
+	return fmt.Sprintf("%s(%d->%d, seq=%d, requester=%d)", m.Type, m.From, m.To, m.Seq, m.Requester)
+}
+
+// before orders the messages in transit, so that a global state has one key
+func (m Message) before(other Message) bool {
+	if m.Type != other.Type {
+		return m.Type < other.Type
+	}
+	if m.From != other.From {
+		return m.From < other.From
+	}
+	if m.To != other.To {
+		return m.To < other.To
+	}
+	if m.Seq != other.Seq {
+		return m.Seq < other.Seq
+	}
+	return m.Requester < other.Requester
+}
+
+// State of a node, String must encode all of it: two states with the same
+// string are the same state
+type State interface {
+	Idle() bool // neither requesting nor in CS
+	InCS() bool
+	String() string
+}
+
+// Spec is an algorithm as a pure state machine
+type Spec interface {
+	Name() string
+	// Init returns the initial state of node id among nbNodes
+	Init(id int, nbNodes int) State
+	// Step handles message at the node of state, without modifying state,
+	// it returns the new state of the node and the messages it sends
+	Step(state State, message Message) (State, []Message)
+}
+
+var SPECS = []Spec{RicartAgrawala{}, NaimiTrehel{}}
+
+// Lookup returns the spec name, whatever its case, nil if none
+func Lookup(name string) Spec {
+	for _, spec := range SPECS {
+		if strings.EqualFold(spec.Name(), name) {
+			return spec
+		}
+	}
+	return nil
+}
+
+// Counterexample is a trace leading to a safety violation or a deadlock
+type Counterexample struct {
+	Kind  string // "safety" or "deadlock"
+	Trace []string // the transitions from the initial state, then the last state
+}
+
+func (c *Counterexample) Error() string {
+	return c.Kind + " violation:\n  " + strings.Join(c.Trace, "\n  ")
+}
+
+// Result of an exhaustive check
+type Result struct {
+	States      int
+	Transitions int
+}
+
+// global is a state of the whole system
+type global struct {
+	nodes    []State
+	network  []Message // in transit, sorted by before
+	requests []int // the number of REQUEST_CS of each node
+}
+
+// key encodes g, the states are visited once per key
+func (g *global) key() string {
+	var b strings.Builder
+	for i, node := range g.nodes {
+		b.WriteString(node.String())
+		b.WriteByte('/')
+		b.WriteString(strconv.Itoa(g.requests[i]))
+		b.WriteByte(';')
+	}
+	for _, m := range g.network {
+		b.WriteString(m.Type)
+		for _, v := range []int{m.From, m.To, m.Seq, m.Requester} {
+			b.WriteByte(',')
+			b.WriteString(strconv.Itoa(v))
+		}
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+func (g *global) String() string {
+	var parts []string
+	for i, node := range g.nodes {
+		parts = append(parts, fmt.Sprintf("node #%d{%s} requests=%d", i, node, g.requests[i]))
+	}
+	for _, m := range g.network {
+		parts = append(parts, m.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// step returns the global state after message is handled by its destination
+func (g *global) step(spec Spec, message Message, delivered int) *global {
+	var next = &global{}
+	next.nodes = append([]State(nil), g.nodes...)
+	next.requests = append([]int(nil), g.requests...)
+	for k, m := range g.network {
+		if k != delivered {
+			next.network = append(next.network, m)
+		}
+	}
+	var out []Message
+	next.nodes[message.To], out = spec.Step(g.nodes[message.To], message)
+	next.network = append(next.network, out...)
+	sort.Slice(next.network, func(a int, b int) bool { return next.network[a].before(next.network[b]) })
+	if message.Type == REQUEST_CS {
+		next.requests[message.To] ++
+	}
+	return next
+}
+
+// successors returns the transitions enabled in g and the states they lead to
+func (g *global) successors(spec Spec, maxRequests int) ([]Message, []*global) {
+	var transitions []Message
+	var states []*global
+	for i, node := range g.nodes {
+		if node.Idle() && g.requests[i] < maxRequests {
+			var m = Message{Type: REQUEST_CS, From: i, To: i}
+			transitions = append(transitions, m)
+			states = append(states, g.step(spec, m, -1))
+		}
+		if node.InCS() {
+			var m = Message{Type: RELEASE_CS, From: i, To: i}
+			transitions = append(transitions, m)
+			states = append(states, g.step(spec, m, -1))
+		}
+	}
+	for k, m := range g.network {
+		// identical messages in transit lead to the same state
+		if k > 0 && g.network[k - 1] == m {
+			continue
+		}
+		transitions = append(transitions, m)
+		states = append(states, g.step(spec, m, k))
+	}
+	return transitions, states
+}
+
+// Check explores all the global states of spec with nbNodes nodes, each one
+// requesting the CS at most maxRequests times. It returns a
+// *Counterexample if a safety violation or a deadlock is reachable, an error
+// if there are more than maxStates states
+func Check(spec Spec, nbNodes int, maxRequests int, maxStates int) (Result, error) {
+	var result Result
+	var initial = &global{requests: make([]int, nbNodes)}
+	for i := 0; i < nbNodes; i++ {
+		initial.nodes = append(initial.nodes, spec.Init(i, nbNodes))
+	}
+	// parent and transition of each state, to rebuild the traces
+	var states = []*global{initial}
+	var parents = []int{-1}
+	var transitions = []string{""}
+	var visited = map[string]bool{initial.key(): true}
+
+	var trace = func(k int, kind string) *Counterexample {
+		var steps []string
+		for last := k; parents[last] != -1; last = parents[last] {
+			steps = append([]string{transitions[last]}, steps...)
+		}
+		steps = append(steps, "state: " + states[k].String())
+		return &Counterexample{Kind: kind, Trace: steps}
+	}
+
+	for k := 0; k < len(states); k++ {
+		var g *global = states[k]
+		var inCS int = 0
+		for _, node := range g.nodes {
+			if node.InCS() {
+				inCS ++
+			}
+		}
+		if inCS > 1 {
+			return result, trace(k, "safety")
+		}
+		enabled, next := g.successors(spec, maxRequests)
+		result.Transitions += len(next)
+		if len(next) == 0 {
+			for _, node := range g.nodes {
+				if !node.Idle() {
+					return result, trace(k, "deadlock")
+				}
+			}
+		}
+		for j, s := range next {
+			var key string = s.key()
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			states = append(states, s)
+			parents = append(parents, k)
+			transitions = append(transitions, enabled[j].String())
+			if len(states) > maxStates {
+				return result, fmt.Errorf("%s with %d nodes and %d requests: more than %d states", spec.Name(), nbNodes, maxRequests, maxStates)
+			}
+		}
+		result.States = len(states)
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package ModelCheck
+
+import (
+	"errors"
+	"testing"
+)
+
+// The specs are safe and deadlock free, Ricart-Agrawala with 4 nodes has
+// more than a million states and is left to cmd/modelcheck
+func TestSpecs(t *testing.T) {
+	var tests = []struct {
+		spec        Spec
+		nbNodes     int
+		maxRequests int
+	}{
+		{RicartAgrawala{}, 2, 3},
+		{RicartAgrawala{}, 3, 2},
+		{NaimiTrehel{}, 2, 3},
+		{NaimiTrehel{}, 3, 2},
+		{NaimiTrehel{}, 4, 1},
+	}
+	for _, test := range tests {
+		result, err := Check(test.spec, test.nbNodes, test.maxRequests, 1000000)
+		if err != nil {
+			t.Error(test.spec.Name(), ", ", test.nbNodes, " nodes, ", test.maxRequests, " requests: ", err)
+			continue
+		}
+		if result.States < 2 || result.Transitions < result.States - 1 {
+			t.Error(test.spec.Name(), ", ", test.nbNodes, " nodes: ", result.States, " states and ", result.Transitions, " transitions")
+		}
+	}
+}
+
+// noTieBreak is Ricart-Agrawala without the tie break on the ids: two
+// requests with the same sequence number both get their replies
+type noTieBreak struct {
+	RicartAgrawala
+}
+
+func (noTieBreak) Step(state State, message Message) (State, []Message) {
+	var s RicartAgrawalaState = state.(RicartAgrawalaState)
+	if message.Type == REQUEST && s.Requesting && message.Seq == s.SeqNumber {
+		s.HighestSeqNumber = max(s.HighestSeqNumber, message.Seq)
+		return s, []Message{{Type: REPLY, From: s.Id, To: message.From}}
+	}
+	return RicartAgrawala{}.Step(state, message)
+}
+
+// lostNext is Naimi-Trehel forgetting its next requester on release: the
+// token stays at the root, and the requester waits for it forever
+type lostNext struct {
+	NaimiTrehel
+}
+
+func (lostNext) Step(state State, message Message) (State, []Message) {
+	var s NaimiTrehelState = state.(NaimiTrehelState)
+	if message.Type == RELEASE_CS {
+		s.Requesting = false
+		s.InCriticalSection = false
+		s.Next = -1
+		return s, nil
+	}
+	return NaimiTrehel{}.Step(state, message)
+}
+
+// The counterexamples of faulty specs are found, and are shortest traces
+func TestCounterexamples(t *testing.T) {
+	var tests = []struct {
+		spec     Spec
+		kind     string
+		length   int // number of transitions of the trace
+	}{
+		// both nodes request, then both requests are answered
+		{noTieBreak{}, "safety", 6},
+		// node #1 requests, node #0 requests and enters its CS, receives
+		// the request of node #1 then releases its CS
+		{lostNext{}, "deadlock", 4},
+	}
+	for _, test := range tests {
+		_, err := Check(test.spec, 2, 1, 1000000)
+		var counterexample *Counterexample
+		if !errors.As(err, &counterexample) {
+			t.Error(test.kind, ": no counterexample, ", err)
+			continue
+		}
+		if counterexample.Kind != test.kind || len(counterexample.Trace) != test.length + 1 {
+			t.Error("expected a ", test.kind, " violation after ", test.length, " transitions, got ", counterexample)
+		}
+	}
+}
+
+// Check gives up on a state space larger than maxStates
+func TestMaxStates(t *testing.T) {
+	_, err := Check(RicartAgrawala{}, 3, 2, 10)
+	var counterexample *Counterexample
+	if err == nil || errors.As(err, &counterexample) {
+		t.Error("more than 10 states: ", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if Lookup("naimitrehel") == nil || Lookup("RicartAgrawala") == nil || Lookup("foo") != nil {
+		t.Error("lookup of the specs")
+	}
+}
@@ -0,0 +1,104 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Naimi-Trehel as a pure state machine, run by the handlers of
+Mutex/Naimi-Trehel/Go/naimi-trehel.go, which add its write-ahead log. The
+transitions:
+* initially node #0 holds the token and is the last requester known by all
+  the others
+* REQUEST_CS sends REQ to the last requester, unless the node is the root,
+  and the node becomes the root
+* REQ(j), receiveRequestCS: the root sends the token to j if it is not
+  requesting, or keeps j as its next otherwise, any other node forwards the
+  request to its last. j becomes the last requester
+* TOKEN, receiveToken: the node enters its CS
+* RELEASE_CS sends the token to the next requester, if any
+*/
+
+package ModelCheck
+
+import (
+	"fmt"
+)
+
+// Message types
+var REQ   string = "REQ"
+var TOKEN string = "TOKEN"
+
+type NaimiTrehelState struct {
+	Id                int
+	HasToken          bool
+	Requesting        bool // from REQUEST_CS to RELEASE_CS
+	InCriticalSection bool
+	Next              int // the dynamic distributed list
+	Last              int // the last requester, -1 when the node is the root
+}
+
+func (s NaimiTrehelState) Idle() bool {
+	return !s.Requesting
+}
+
+func (s NaimiTrehelState) InCS() bool {
+	return s.InCriticalSection
+}
+
+func (s NaimiTrehelState) String() string {
+	return fmt.Sprintf("has_token=%v, requesting=%v, inCS=%v, next=%d, last=%d",
+		s.HasToken, s.Requesting, s.InCriticalSection, s.Next, s.Last)
+}
+
+type NaimiTrehel struct{}
+
+func (NaimiTrehel) Name() string {
+	return "NaimiTrehel"
+}
+
+func (NaimiTrehel) Init(id int, nbNodes int) State {
+	var s = NaimiTrehelState{Id: id, Next: -1, Last: 0}
+	if s.Last == id {
+		s.HasToken = true
+		s.Last = -1
+	}
+	return s
+}
+
+func (NaimiTrehel) Step(state State, message Message) (State, []Message) {
+	var s NaimiTrehelState = state.(NaimiTrehelState)
+	var out []Message
+	switch message.Type {
+	case REQUEST_CS:
+		s.Requesting = true
+		if s.Last != -1 {
+			out = append(out, Message{Type: REQ, From: s.Id, To: s.Last, Requester: s.Id})
+			s.Last = -1
+		}
+	case REQ:
+		var j int = message.Requester
+		if s.Last == -1 {
+			if s.Requesting {
+				s.Next = j
+			} else {
+				s.HasToken = false
+				out = append(out, Message{Type: TOKEN, From: s.Id, To: j})
+			}
+		} else {
+			// Forwarding request to last
+			out = append(out, Message{Type: REQ, From: s.Id, To: s.Last, Requester: j})
+		}
+		s.Last = j
+	case TOKEN:
+		s.HasToken = true
+	case RELEASE_CS:
+		s.Requesting = false
+		s.InCriticalSection = false
+		if s.Next != -1 {
+			out = append(out, Message{Type: TOKEN, From: s.Id, To: s.Next})
+			s.HasToken = false
+			s.Next = -1
+		}
+	}
+	if s.Requesting && !s.InCriticalSection && s.HasToken {
+		s.InCriticalSection = true
+	}
+	return s, out
+}
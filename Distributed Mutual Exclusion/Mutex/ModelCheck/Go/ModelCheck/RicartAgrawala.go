@@ -0,0 +1,100 @@
+/*
+  Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
+
+Ricart-Agrawala as a pure state machine, run by the handlers of
+Mutex/Ricart-Agrawala/Go/ricart-agrawala.go for each of its named locks. It
+has a single lock and no write-ahead log. The transitions:
+* REQUEST_CS chooses a sequence number above the highest one seen, and sends
+  REQUEST to all the other nodes
+* REQUEST(k) from j is deferred while the node requests with priority, i.e.
+  a lower sequence number or the same one and a lower id, it is answered
+  with REPLY otherwise
+* the node enters its CS with the REPLY of all the other nodes
+* RELEASE_CS sends the deferred replies
+*/
+
+package ModelCheck
+
+import (
+	"fmt"
+)
+
+// Message types
+var REQUEST string = "REQUEST"
+var REPLY   string = "REPLY"
+
+type RicartAgrawalaState struct {
+	Id                int
+	Requesting        bool // from REQUEST_CS to RELEASE_CS
+	InCriticalSection bool
+	SeqNumber         int
+	HighestSeqNumber  int
+	Outstanding       int // the number of REPLY still expected
+	Deferred          []bool
+}
+
+func (s RicartAgrawalaState) Idle() bool {
+	return !s.Requesting
+}
+
+func (s RicartAgrawalaState) InCS() bool {
+	return s.InCriticalSection
+}
+
+func (s RicartAgrawalaState) String() string {
+	return fmt.Sprintf("requesting=%v, inCS=%v, seq=%d, highest=%d, outstanding=%d, deferred=%v",
+		s.Requesting, s.InCriticalSection, s.SeqNumber, s.HighestSeqNumber, s.Outstanding, s.Deferred)
+}
+
+type RicartAgrawala struct{}
+
+func (RicartAgrawala) Name() string {
+	return "RicartAgrawala"
+}
+
+func (RicartAgrawala) Init(id int, nbNodes int) State {
+	return RicartAgrawalaState{Id: id, Deferred: make([]bool, nbNodes)}
+}
+
+func (RicartAgrawala) Step(state State, message Message) (State, []Message) {
+	var s RicartAgrawalaState = state.(RicartAgrawalaState)
+	s.Deferred = append([]bool(nil), s.Deferred...)
+	var out []Message
+	switch message.Type {
+	case REQUEST_CS:
+		s.Requesting = true
+		s.HighestSeqNumber ++
+		s.SeqNumber = s.HighestSeqNumber
+		s.Outstanding = len(s.Deferred) - 1
+		for j := 0; j < len(s.Deferred); j++ {
+			if j != s.Id {
+				out = append(out, Message{Type: REQUEST, From: s.Id, To: j, Seq: s.SeqNumber})
+			}
+		}
+	case REQUEST:
+		var k int = message.Seq
+		var j int = message.From
+		s.HighestSeqNumber = max(s.HighestSeqNumber, k)
+		var deferIt bool = s.Requesting && (k > s.SeqNumber || (k == s.SeqNumber && j > s.Id))
+		if deferIt {
+			s.Deferred[j] = true
+		} else {
+			out = append(out, Message{Type: REPLY, From: s.Id, To: j})
+		}
+	case REPLY:
+		s.Outstanding --
+	case RELEASE_CS:
+		s.Requesting = false
+		s.InCriticalSection = false
+		for j := 0; j < len(s.Deferred); j++ {
+			if s.Deferred[j] {
+				s.Deferred[j] = false
+				out = append(out, Message{Type: REPLY, From: s.Id, To: j})
+			}
+		}
+	}
+	if s.Requesting && !s.InCriticalSection && s.Outstanding == 0 {
+		s.InCriticalSection = true
+	}
+	return s, out
+}
@@ -0,0 +1,46 @@
+/*
+Exhaustive check of the mutual exclusion algorithms written as pure state
+machines, see the ModelCheck package for what they model:
+go run ./cmd/modelcheck --algo=RicartAgrawala --nodes=3 --requests=2
+go run ./cmd/modelcheck --algo=NaimiTrehel --nodes=4 --requests=1
+
+The exit status is 1 when a counterexample is found, its trace is printed.
+*/
+
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"modelcheck/ModelCheck"
+)
+
+func main() {
+	var names []string
+	for _, spec := range ModelCheck.SPECS {
+		names = append(names, spec.Name())
+	}
+	algoPtr := flag.String("algo", "RicartAgrawala", "algorithm to check: " + strings.Join(names, ", "))
+	nbNodesPtr := flag.Int("nodes", 3, "number of nodes, 2 to 4")
+	requestsPtr := flag.Int("requests", 1, "number of CS requests of each node")
+	maxStatesPtr := flag.Int("maxStates", 5000000, "the check gives up after this number of states")
+	flag.Parse()
+
+	var spec ModelCheck.Spec = ModelCheck.Lookup(*algoPtr)
+	if spec == nil {
+		log.Fatal("unknown algorithm ", *algoPtr, ", must be one of ", names)
+	}
+	if *nbNodesPtr < 1 || *requestsPtr < 1 {
+		log.Fatal("-nodes and -requests must be at least 1")
+	}
+	var start time.Time = time.Now()
+	result, err := ModelCheck.Check(spec, *nbNodesPtr, *requestsPtr, *maxStatesPtr)
+	if err != nil {
+		log.Fatal(spec.Name(), " with ", *nbNodesPtr, " nodes and ", *requestsPtr, " requests each: ", err)
+	}
+	log.Print(spec.Name(), " with ", *nbNodesPtr, " nodes and ", *requestsPtr, " requests each: safe and deadlock free, ",
+		result.States, " states, ", result.Transitions, " transitions in ", time.Since(start).Round(time.Millisecond))
+}
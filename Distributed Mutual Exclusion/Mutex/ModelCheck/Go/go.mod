@@ -0,0 +1,3 @@
+module modelcheck
+
+go 1.21
@@ -0,0 +1,7 @@
+module naimitrehel
+
+go 1.21
+
+require modelcheck v0.0.0
+
+replace modelcheck => "../../ModelCheck/Go"
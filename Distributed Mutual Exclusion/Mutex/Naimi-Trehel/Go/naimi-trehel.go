@@ -2,11 +2,40 @@
   Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
 
 How-to run: 
-  go run naimi-trehel.go 2>&1 |tee /tmp/tmp.log
+  go run . 2>&1 |tee /tmp/tmp.log
+or, with a write-ahead log per node and node #0 crashing after 4 CS entries:
+  go run . -walDir=/tmp/nt-wal -crashNode=0 -crashAfter=4 2>&1 |tee /tmp/tmp.log
 
 Parameters:
 - Number of nodes is set with NB_NODES global variable
 - Number of CS entries is set with NB_ITERATIONS global variable
+- -walDir: directory of the write-ahead logs, no log is written when empty (default)
+- -crashNode: node stopped then restarted during the run, -1 (default) for none,
+  requires -walDir
+- -crashAfter: number of CS entries in the system before the crash
+- -downtime: time the crashed node stays down
+
+Crash-recovery
+* Each node appends a snapshot of its state to <walDir>/node-<id>.wal before
+  sending the token or a request, so that the log is always ahead of what the
+  other nodes know
+* A crashed node loses its whole memory. Messages sent to it while it is down
+  stay blocked in the channels, they are not lost
+* On restart, the node reads the last snapshot of its log: it knows again
+  whether it holds the token, its last and next pointers and whether it was
+  requesting, in which case it keeps waiting for the token
+* Each incarnation of a node has its own stop channel, closed by the crash:
+  the routines of a crashed incarnation check it before touching the state,
+  and stop. A message received by a crashed incarnation goes back to the
+  channel of the node
+* Logs are kept between runs, remove walDir to start from a clean state
+A node checks when it enters the CS that no other node is in it, the run
+ends on a Fatal if one did.
+
+The transitions of a node are the ones of the NaimiTrehel spec of
+Mutex/ModelCheck, its handlers call Step, so that the model checked
+algorithm is the one run. The program adds around it the channels, the
+write-ahead log and the crashes.
 */ 
 
 /*
@@ -24,18 +53,33 @@ Complexity is O(Log(n))
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"sync"
-	"strings"
-	"strconv"
 	"time"
+
+	"modelcheck/ModelCheck"
 )
 
 /* global variable declaration */
 var NB_NODES int = 4
 var NB_ITERATIONS int = 10
 var CURRENT_ITERATION int = 0
+var CS_DURATION time.Duration = 500 * time.Millisecond
+
+var WAL_DIR string = ""
+
+// globalMutex protects CURRENT_ITERATION, HOLDER and VIOLATIONS
+var globalMutex sync.Mutex
+// HOLDER is the node in the CS, -1 for none, to check mutual exclusion
+var HOLDER int = -1
+// VIOLATIONS is the number of times a node entered the CS held by another one
+var VIOLATIONS int = 0
 /*
 // Debug function
 func displayNodes() {
@@ -47,120 +91,312 @@ func displayNodes() {
 
 type Node struct {
 	id         int
-	has_token  bool
-	requesting bool
+	// has_token, requesting, inCS, next, the dynamic distributed list, and
+	// last, called father in the original paper. Called last here as in
+	// Sopena et al. as it stores the last requester
+	state      ModelCheck.NaimiTrehelState
 	nbCS       int
-	next       int // the dynamic distributed list
-	last       int // called father in the original paper. Called last here as in Sopena et al. as it stores the last requester
-	messages   [4]chan string
+	messages   [4]chan ModelCheck.Message
+	mutex      sync.Mutex // protects the state of the node
+	// Crash-recovery
+	wal        *os.File
+	stop       chan bool // closed by the crash of the current incarnation
+}
+
+// walRecord is the snapshot of the state of a node appended to its write-ahead log
+type walRecord struct {
+	HasToken   bool
+	Requesting bool
+	NbCS       int
+	Next       int
+	Last       int
+}
+
+func (n *Node) String() string {
+	var val string
+	val = fmt.Sprintf("Node #%d, %s", n.id, n.state)
+	return val
+}
+
+func currentIteration() int {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return CURRENT_ITERATION
 }
 
-func (n *Node) enterCS() {
+// stopped returns true once the incarnation of stop has crashed
+func stopped(stop chan bool) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// enterCS returns false if the incarnation of stop has crashed
+func (n *Node) enterCS(stop chan bool) bool {
+	n.mutex.Lock()
+	if stopped(stop) {
+		n.mutex.Unlock()
+		return false
+	}
 	log.Print("Node #", n.id, " ######################### enterCS")
+	globalMutex.Lock()
+	if HOLDER != -1 {
+		log.Print("Node #", n.id, " enters the CS held by Node #", HOLDER)
+		VIOLATIONS ++
+	}
+	HOLDER = n.id
 	CURRENT_ITERATION ++
+	globalMutex.Unlock()
 	n.nbCS ++
-	time.Sleep(500 * time.Millisecond)
+	n.mutex.Unlock()
+	time.Sleep(CS_DURATION)
+	return true
 }
 
-func (n *Node) releaseCS() {
+func (n *Node) releaseCS(stop chan bool) {
+	n.mutex.Lock()
+	if stopped(stop) {
+		// the node crashed in its CS
+		n.mutex.Unlock()
+		return
+	}
 	log.Print("Node #", n.id," releaseCS #######################")	
-	n.requesting = false
-	if n.next != -1 {
-		var content = fmt.Sprintf("token%d", n.next)
-		// log.Print("node #", n.id, " releaseCS, SENDING ", content, " to next #", n.next)				
-		n.messages[n.next] <- content
-		n.has_token = false
-		n.next = -1
+	globalMutex.Lock()
+	HOLDER = -1
+	globalMutex.Unlock()
+	var out []ModelCheck.Message = n.step(ModelCheck.Message{Type: ModelCheck.RELEASE_CS, From: n.id, To: n.id})
+	n.persist()
+	n.mutex.Unlock()
+	n.send(out)
+}
+
+// step handles message with the spec, it must be called with the mutex held.
+// It returns the messages to send, once the state is persisted
+func (n *Node) step(message ModelCheck.Message) []ModelCheck.Message {
+	next, out := ModelCheck.NaimiTrehel{}.Step(n.state, message)
+	n.state = next.(ModelCheck.NaimiTrehelState)
+	return out
+}
+
+func (n *Node) send(out []ModelCheck.Message) {
+	for _, m := range out {
+		// log.Print("node #", n.id, " SENDING ", m)
+		n.messages[m.To] <- m
 	}
 }
 
-func (n *Node) requestCS() {
+func (n *Node) requestCS(stop chan bool) {
 	for {
 		time.Sleep(100 * time.Millisecond)
-
-		// Initialization of request
-		n.has_token = false
-		n.requesting = false
-		n.next = -1
-		n.last = 0
-
-		if n.last == n.id {
-			n.has_token = true
-			n.last = -1
-		} else {
-			n.has_token = false
+		n.mutex.Lock()
+		if stopped(stop) {
+			n.mutex.Unlock()
+			return
 		}
 
-		n.requesting = true
-		if n.last != -1 {
-			var content = fmt.Sprintf("REQ%d", n.id)
-			log.Print("node #", n.id, " requestCS, SENDING ", content, " to last #", n.last)				
-			n.messages[n.last] <- content
-			n.last = -1		
+		// A node restarted while requesting keeps waiting for the token
+		var out []ModelCheck.Message
+		if n.state.Idle() {
+			out = n.step(ModelCheck.Message{Type: ModelCheck.REQUEST_CS, From: n.id, To: n.id})
+			n.persist()
+		}
+		n.mutex.Unlock()
+		for _, m := range out {
+			log.Print("node #", n.id, " requestCS, SENDING ", m)
 		}
+		n.send(out)
 
 		for {
 			time.Sleep(100 * time.Millisecond)
-			if (n.requesting == true && n.has_token == true) {
-				n.enterCS()
-				n.releaseCS()
+			n.mutex.Lock()
+			if stopped(stop) {
+				n.mutex.Unlock()
+				return
+			}
+			var granted bool = n.state.InCS()
+			n.mutex.Unlock()
+			if granted {
+				if n.enterCS(stop) == false {
+					return
+				}
+				n.releaseCS(stop)
 				break
 			}
 		} 
 	}
 }
 
-func (n *Node) receiveRequestCS(j int) {
-	if n.last == -1 {
-		if n.requesting {
-			n.next = j
-		} else {
-			n.has_token = false
-			var content = fmt.Sprintf("token%d", j)
-			// log.Print("node #", n.id, " receiveRequestCS SENDING ", content, " to j #", j)				
-			n.messages[j] <- content
-		}		
-	} else {
-		// Forwarding request to last
-		var content = fmt.Sprintf("REQ%d", j)
-		// log.Print("node #", n.id, " receiveRequestCS fwd SENDING ", content, " to last #", n.last)				
-		n.messages[n.last] <- content
-	}
-	n.last = j
-	// log.Print("node #", n.id, " receiveRequestCS, *update* n.last #", n.last)
-}
-
-func (n *Node) receiveToken() {
-	log.Print("** Node #", n.id, " Got TOKEN **")
-	n.has_token = true
-}
-
-func (n *Node) waitForReplies() {	
+func (n *Node) waitForReplies(stop chan bool) {	
 	for {
 		select {
+		case <-stop:
+			return
 		case msg := <-n.messages[n.id]:
-			if (strings.Contains(msg, "REQ")) {
-				var requester, err = strconv.Atoi(msg[3:])
-				if err != nil {
-					log.Fatal(err)
-				}
-				n.receiveRequestCS(requester)
-				
-			} else if (strings.Contains(msg, "token")) {
-				n.receiveToken()
+			n.mutex.Lock()
+			if stopped(stop) {
+				n.mutex.Unlock()
+				// the next incarnation handles it
+				go func() { n.messages[n.id] <- msg }()
+				return
+			}
+			// REQ(j) is receiveRequestCS, TOKEN is receiveToken
+			if msg.Type == ModelCheck.TOKEN {
+				log.Print("** Node #", n.id, " Got TOKEN **")
 			}
+			var out []ModelCheck.Message = n.step(msg)
+			n.persist()
+			n.mutex.Unlock()
+			n.send(out)
 		}
 	}	
 }
 
+////////////////////////////////////////////////////////////
+// Crash-recovery
+////////////////////////////////////////////////////////////
+func walPath(id int) string {
+	return filepath.Join(WAL_DIR, fmt.Sprintf("node-%d.wal", id))
+}
+
+// persist appends the current state of the node to its write-ahead log, it
+// must be called before sending any message that depends on this state
+func (n *Node) persist() {
+	if n.wal == nil {
+		return
+	}
+	var record walRecord
+	record.HasToken = n.state.HasToken
+	record.Requesting = n.state.Requesting
+	record.NbCS = n.nbCS
+	record.Next = n.state.Next
+	record.Last = n.state.Last
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = n.wal.Write(append(content, '\n'))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = n.wal.Sync()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// openWAL opens the write-ahead log of the node and restores the last
+// snapshot it contains. It returns false when there was nothing to restore
+func (n *Node) openWAL() bool {
+	if WAL_DIR == "" {
+		return false
+	}
+	var restored bool = false
+	file, err := os.OpenFile(walPath(n.id), os.O_RDWR | os.O_CREATE, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var last walRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record walRecord
+		// a record truncated by a crash is ignored, the previous one is kept
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			last = record
+			restored = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if restored {
+		n.state.HasToken = last.HasToken
+		n.state.Requesting = last.Requesting
+		// a node crashed in its CS enters it again
+		n.state.InCriticalSection = last.Requesting && last.HasToken
+		n.nbCS = last.NbCS
+		n.state.Next = last.Next
+		n.state.Last = last.Last
+		log.Print("Node #", n.id, " restored from ", walPath(n.id), ": ", n)
+	}
+	n.wal = file
+	return restored
+}
+
+func (n *Node) init() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.stop = make(chan bool)
+	n.state = ModelCheck.NaimiTrehelState{Id: n.id, Next: -1, Last: -1}
+	if n.openWAL() {
+		return
+	}
+	// Initially node #0 holds the token and is the last requester known by all others
+	n.nbCS = 0
+	n.state = ModelCheck.NaimiTrehel{}.Init(n.id, NB_NODES).(ModelCheck.NaimiTrehelState)
+	n.persist()
+}
+
+// start runs the routines of the current incarnation of the node
+func (n *Node) start() {
+	n.mutex.Lock()
+	var stop chan bool = n.stop
+	n.mutex.Unlock()
+	go n.requestCS(stop)
+	go n.waitForReplies(stop)
+}
+
+// halt stops the current incarnation of the node and wipes everything it had
+// in memory, only its write-ahead log survives
+func (n *Node) halt() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	close(n.stop)
+	if n.wal != nil {
+		n.wal.Close()
+		n.wal = nil
+	}
+	n.state = ModelCheck.NaimiTrehelState{Id: n.id, Next: -1, Last: -1}
+	n.nbCS = 0
+	// a node crashing in its CS leaves it
+	globalMutex.Lock()
+	if HOLDER == n.id {
+		HOLDER = -1
+	}
+	globalMutex.Unlock()
+}
+
+func (n *Node) crash() {
+	log.Print("Node #", n.id, " !!!!!!!!!!!!!!!!!!!!!!!!! CRASH")
+	n.halt()
+}
+
+func (n *Node) restart() {
+	log.Print("Node #", n.id, " !!!!!!!!!!!!!!!!!!!!!!!!! RESTART")
+	n.init()
+	n.start()
+}
+
+func (n *Node) crashAndRestart(crashAfter int, downtime time.Duration, wg *sync.WaitGroup) {
+	for currentIteration() < crashAfter {
+		time.Sleep(10 * time.Millisecond)
+	}
+	n.crash()
+	time.Sleep(downtime)
+	n.restart()
+	wg.Done()
+}
+
 func (n *Node) NaimiTrehel(wg *sync.WaitGroup) {
 	log.Print("Node #", n.id)
 
-	go n.requestCS()
-	go n.waitForReplies()
+	n.start()
 	for {
 		time.Sleep(100 * time.Millisecond)
-		if CURRENT_ITERATION > NB_ITERATIONS {
+		if currentIteration() > NB_ITERATIONS {
 			break
 		}
 	}
@@ -169,17 +405,24 @@ func (n *Node) NaimiTrehel(wg *sync.WaitGroup) {
 	wg.Done()
 }
 
-func main() {
+// run runs the nodes until NB_ITERATIONS CS entries, crashing and restarting
+// crashNode if it is not -1. All the nodes are stopped at the end
+func run(crashNode int, crashAfter int, downtime time.Duration) {
 	var nodes [4]Node	
 	var wg sync.WaitGroup
-	var messages [len(nodes)]chan string
+	var messages [len(nodes)]chan ModelCheck.Message
 	
 	log.Print("nb_process #", len(nodes))
+	globalMutex.Lock()
+	CURRENT_ITERATION = 0
+	HOLDER = -1
+	VIOLATIONS = 0
+	globalMutex.Unlock()
 	
 	for i := 0; i < len(nodes); i++ {
 		nodes[i].id = i
-		nodes[i].nbCS = 0
-		messages[i] = make(chan string)
+		nodes[i].init()
+		messages[i] = make(chan ModelCheck.Message)
 	}
 	for i := 0; i < len(nodes); i++ {
 		nodes[i].messages = messages
@@ -189,8 +432,44 @@ func main() {
 		wg.Add(1)
 		go nodes[i].NaimiTrehel(&wg)
 	}
+	if crashNode >= 0 {
+		wg.Add(1)
+		go nodes[crashNode].crashAndRestart(crashAfter, downtime, &wg)
+	}
 	wg.Wait()
 	for i := 0; i < NB_NODES; i++ {
+		nodes[i].mutex.Lock()
 		log.Print("Node #", nodes[i].id," entered CS ", nodes[i].nbCS," time")	
+		nodes[i].mutex.Unlock()
+		nodes[i].halt()
+	}
+}
+
+func main() {
+	walDirPtr := flag.String("walDir", "", "directory of the write-ahead logs, disabled when empty")
+	crashNodePtr := flag.Int("crashNode", -1, "node to crash and restart during the run, -1 for none, requires -walDir")
+	crashAfterPtr := flag.Int("crashAfter", NB_ITERATIONS / 2, "number of CS entries before the crash")
+	downtimePtr := flag.Duration("downtime", 2 * time.Second, "time the crashed node stays down")
+	flag.Parse()
+
+	WAL_DIR = *walDirPtr
+	if WAL_DIR != "" {
+		err := os.MkdirAll(WAL_DIR, 0755)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *crashNodePtr >= NB_NODES {
+		log.Fatal("-crashNode must be lower than ", NB_NODES)
+	}
+	// without its log, a restarted node would take the initial state back,
+	// and node #0 a second token
+	if *crashNodePtr >= 0 && WAL_DIR == "" {
+		log.Fatal("-crashNode requires -walDir")
+	}
+
+	run(*crashNodePtr, *crashAfterPtr, *downtimePtr)
+	if VIOLATIONS > 0 {
+		log.Fatal(VIOLATIONS, " mutual exclusion violations")
 	}
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// runWithTimeout fails the test if the run does not end in time
+func runWithTimeout(t *testing.T, crashNode int, crashAfter int, downtime time.Duration) {
+	var done = make(chan bool)
+	go func() {
+		run(crashNode, crashAfter, downtime)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(60 * time.Second):
+		t.Fatal("the run did not end, ", currentIteration(), " CS entries")
+	}
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	if VIOLATIONS != 0 {
+		t.Error(VIOLATIONS, " mutual exclusion violations")
+	}
+	if CURRENT_ITERATION <= NB_ITERATIONS {
+		t.Error("only ", CURRENT_ITERATION, " CS entries")
+	}
+}
+
+// A node stopped and restarted mid-run rejoins from its write-ahead log,
+// including when it restarts before the end of the CS it crashed in
+func TestCrashRecovery(t *testing.T) {
+	CS_DURATION = 100 * time.Millisecond
+	var tests = []struct {
+		crashNode  int
+		crashAfter int
+		downtime   time.Duration
+	}{
+		{-1, 0, 0},
+		// node #0 holds the token initially
+		{0, 4, 30 * time.Millisecond},
+		{0, 4, 300 * time.Millisecond},
+		{2, 3, 30 * time.Millisecond},
+		{3, 6, 300 * time.Millisecond},
+	}
+	for _, test := range tests {
+		WAL_DIR = t.TempDir()
+		runWithTimeout(t, test.crashNode, test.crashAfter, test.downtime)
+	}
+}
@@ -0,0 +1,12 @@
+module ricartagrawala
+
+go 1.21
+
+require (
+	drinking v0.0.0
+	modelcheck v0.0.0
+)
+
+replace drinking => "../../../Drinking Philosophers/Go"
+
+replace modelcheck => "../../ModelCheck/Go"
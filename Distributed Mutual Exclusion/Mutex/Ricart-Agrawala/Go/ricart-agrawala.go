@@ -1,12 +1,16 @@
 /*
   Copyright "Guillaume Fraysse <gfraysse dot spam plus code at gmail dot com>"
 
-TODO: 
+TODO:
 - everything
 
-How-to run: 
-  go build ricart-agrawala.go 
+How-to run:
+  go build -o ricart-agrawala .
   ./ricart-agrawala 2>&1 |tee /tmp/tmp.log
+or, with a write-ahead log per node and node #1 crashing after 4 CS entries:
+  ./ricart-agrawala -walDir=/tmp/ra-wal -crashNode=1 -crashAfter=4 2>&1 |tee /tmp/tmp.log
+or, with each node taking 3 named locks at once:
+  ./ricart-agrawala -locks=db,cache,queue 2>&1 |tee /tmp/tmp.log
 
 Terminology
 * A site is any computing device which runs the Ricart-Agrawala Algorithm
@@ -16,13 +20,52 @@ Terminology
 Parameters:
 - Number of nodes is set with NB_NODES global variable
 - Number of CS entries is set with NB_ITERATIONS global variable
-*/ 
+- -locks: names of the locks, each one guards its own critical section
+- -walDir: directory of the write-ahead logs, no log is written when empty (default)
+- -crashNode: node stopped then restarted during the run, -1 (default) for none,
+  requires -walDir
+- -crashAfter: number of CS entries in the system before the crash
+- -downtime: time the crashed node stays down
+
+Named locks
+* Each lock is an instance of the algorithm of its own: the messages carry
+  the name of their lock, and are multiplexed over the channels of the nodes
+* A node requests its locks in parallel, one routine per lock
+* The state of a lock, its sequence number, replies expected and deferred
+  replies, only lives from the request to the release: it is created on
+  request and collected on release. A node receiving a REQUEST for a lock it
+  does not request replies at once, without creating the lock
+* The highest sequence number is shared by all the locks of the node, so that
+  a collected lock loses nothing
+* The transitions of a lock are the ones of the RicartAgrawala spec of
+  Mutex/ModelCheck, the handlers call its Step on the state of the lock, so
+  that the model checked algorithm is the one run. The spec is checked for a
+  single lock, the sharing of the highest sequence number only makes it
+  higher
+A node checks when it enters the CS of a lock that no other node is in it,
+the run ends on a Fatal if one did.
+
+Crash-recovery
+* Each node appends a snapshot of its state to <walDir>/node-<id>.wal before
+  any message depending on that state is sent, so that the log is always ahead
+  of what the other nodes know
+* A crashed node loses its whole memory. Messages sent to it while it is down
+  stay blocked in the channels, they are not lost
+* On restart, the node reads the last snapshot of its log: it gets back
+  highestSeqNumber, its pending requests and their deferred replies, and
+  resumes waiting for the missing replies instead of sending new requests
+* Each incarnation of a node has its own stop channel, closed by the crash:
+  the routines of a crashed incarnation check it before touching the state,
+  and stop. A message received by a crashed incarnation goes back to the
+  channel of the node
+* Logs are kept between runs, remove walDir to start from a clean state
+*/
 
 /*
     Go implementation of Ricart-Agrawala mutual exclusion algorithm
     Algorithm by Glenn Ricart and Ashok Agrawala 1981
 
-References : 
+References :
   - https://doi.org/10.1145%2F358527.358537
   - https://en.wikipedia.org/wiki/Ricart%E2%80%93Agrawala_algorithm
   - https://www.geeksforgeeks.org/ricart-agrawala-algorithm-in-mutual-exclusion-in-distributed-system/
@@ -31,18 +74,37 @@ References :
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"sync"
 	"strings"
-	"strconv"
 	"time"
+
+	"drinking/Clock"
+	"modelcheck/ModelCheck"
 )
 
 /* global variable declaration */
 var NB_NODES int = 4
 var NB_ITERATIONS int = 10
 var CURRENT_ITERATION int = 0
+var CS_DURATION time.Duration = 500 * time.Millisecond
+
+var WAL_DIR string = ""
+
+var LOCK_NAMES = []string{"default"}
+
+// globalMutex protects CURRENT_ITERATION, holders and VIOLATIONS
+var globalMutex sync.Mutex
+// holders is the node in the CS of each lock, to check mutual exclusion
+var holders = make(map[string]int)
+// VIOLATIONS is the number of times a node entered the CS held by another one
+var VIOLATIONS int = 0
 
 /*
 // Debug function
@@ -56,173 +118,390 @@ type Request struct {
 	nodeId         int
 }
 
+// LockState is the state of a node for a lock it requests: SeqNumber, the
+// number of REPLY still expected, and Deferred[j], TRUE when this node is
+// deferring a REPLY to j's REQUEST message. Its HighestSeqNumber is the one
+// of the node, only set for Step
+type LockState = ModelCheck.RicartAgrawalaState
+
+// Message of the lock Lock
+type Message struct {
+	ModelCheck.Message
+	Lock string
+}
+
 type Node struct {
 	id                    int
-	seqNumber             int // The sequence number chosen by a request originating at this node
-	highestSeqNumber      int // The highest sequence number seen in any REQUEST message sent or received
-	outstandingReplyCount int // The number of REPLY messages still expected
-	nbCS                  int // the number of time the node entered its Critical Section
-	isRequestingCS        bool // true when this node is requesting access to its critical section
-	replyDeferred         []bool // Reply_Deferred [j] is TRUE when this node is deferring a REPLY to j's REQUEST message
+	highestSeqNumber      *Clock.Lamport // The highest sequence number seen in any REQUEST message sent or received, for all the locks
+	locks                 map[string]*LockState // the locks requested by the node, created on request and collected on release
+	nbCS                  int // the number of time the node entered a Critical Section
+	mutex                 sync.Mutex // protects highestSeqNumber, locks, nbCS and stop
 	queue                 []Request
-	channel               chan string
-	messages              []chan string
+	messages              []chan Message // the channels of all the nodes, the node receives on its own
+	// Crash-recovery
+	wal                   *os.File
+	stop                  chan bool // closed by the crash of the current incarnation
+}
+
+// walRecord is the snapshot of the state of a node appended to its write-ahead log
+type walRecord struct {
+	HighestSeqNumber      int
+	NbCS                  int
+	Locks                 map[string]*LockState
 }
 
 func (n *Node) String() string {
 	var val string
-	val = fmt.Sprintf("Node #%d, highestSeqNumber=%d, outstandingReplyCount=%d \n",
+	val = fmt.Sprintf("Node #%d, highestSeqNumber=%d, %d locks requested \n",
 		n.id,
-		n.highestSeqNumber,
-		n.outstandingReplyCount)
+		n.highestSeqNumber.Now(),
+		len(n.locks))
 	return val
 }
 
-func (n *Node) enterCS() {
-	log.Print("Node #", n.id, " ######################### enterCS")
+func currentIteration() int {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	return CURRENT_ITERATION
+}
+
+// stopped returns true once the incarnation of stop has crashed
+func stopped(stop chan bool) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// enterCS returns false if the incarnation of stop has crashed
+func (n *Node) enterCS(name string, stop chan bool) bool {
+	n.mutex.Lock()
+	if stopped(stop) {
+		n.mutex.Unlock()
+		return false
+	}
+	log.Print("Node #", n.id, " ######################### enterCS of lock ", name)
+	globalMutex.Lock()
+	if holder, ok := holders[name]; ok {
+		log.Print("Node #", n.id, " enters the CS of lock ", name, " held by Node #", holder)
+		VIOLATIONS ++
+	}
+	holders[name] = n.id
 	CURRENT_ITERATION ++
+	globalMutex.Unlock()
 	n.nbCS ++
+	n.mutex.Unlock()
 	// log.Print(n)
-	time.Sleep(500 * time.Millisecond)
+	time.Sleep(CS_DURATION)
+	return true
 }
 
-func (n *Node) releaseCS() {
-	log.Print("Node #", n.id," releaseCS #########################")	
-	n.isRequestingCS  = false
-	for j := 0; j < NB_NODES; j++ {
-		if (n.replyDeferred[j]) {
-			n.replyDeferred[j] = false
-			n.sendReply(j)
-		}
+// releaseCS sends the deferred replies of the lock, and collects it
+func (n *Node) releaseCS(name string, stop chan bool) {
+	n.mutex.Lock()
+	if stopped(stop) {
+		// the node crashed in its CS
+		n.mutex.Unlock()
+		return
 	}
+	log.Print("Node #", n.id," releaseCS of lock ", name, " #########################")
+	globalMutex.Lock()
+	delete(holders, name)
+	globalMutex.Unlock()
+	var out []ModelCheck.Message = n.step(name, ModelCheck.Message{Type: ModelCheck.RELEASE_CS, From: n.id, To: n.id})
+	n.persist()
+	n.mutex.Unlock()
+	n.send(name, out)
 	// log.Print(n)
 }
 
-func (n *Node) sendRequest(seqNumber int, nodeId int, destNodeId int) {
-	for i := 0; i < len(n.messages); i++ {
-		if i == destNodeId {
-			var content = fmt.Sprintf("REQ%d%d", n.id, seqNumber)
-			log.Print("Node #", n.id, ", SENDING request ", content, " with seqNumber #", seqNumber, " to Node #", destNodeId)	
-			n.messages[i] <- content
+// step handles message of lock name with the spec, it must be called with the
+// mutex held. The lock is created by its request and collected once idle, the
+// highest sequence number of the node follows the one of the lock. It
+// returns the messages to send, once the state is persisted
+func (n *Node) step(name string, message ModelCheck.Message) []ModelCheck.Message {
+	// a lock the node does not request has no state
+	var l LockState = LockState{Id: n.id, Deferred: make([]bool, NB_NODES)}
+	if n.locks[name] != nil {
+		l = *n.locks[name]
+	}
+	l.HighestSeqNumber = n.highestSeqNumber.Now()
+	next, out := ModelCheck.RicartAgrawala{}.Step(l, message)
+	l = next.(LockState)
+	n.highestSeqNumber.Witness(l.HighestSeqNumber)
+	if l.Idle() {
+		delete(n.locks, name)
+	} else {
+		n.locks[name] = &l
+	}
+	return out
+}
+
+func (n *Node) send(name string, out []ModelCheck.Message) {
+	for _, m := range out {
+		log.Print("Node #", n.id, ", SENDING ", m, " of lock ", name)
+		// Messages are sent in a different subroutine
+		go func(m Message) {
+			n.messages[m.To] <- m
+		}(Message{Message: m, Lock: name})
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Crash-recovery
+////////////////////////////////////////////////////////////
+func walPath(id int) string {
+	return filepath.Join(WAL_DIR, fmt.Sprintf("node-%d.wal", id))
+}
+
+// persist appends the current state of the node to its write-ahead log, it
+// must be called with the mutex held, before sending any message that
+// depends on this state
+func (n *Node) persist() {
+	if n.wal == nil {
+		return
+	}
+	var record walRecord
+	record.HighestSeqNumber = n.highestSeqNumber.Now()
+	record.NbCS = n.nbCS
+	record.Locks = n.locks
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = n.wal.Write(append(content, '\n'))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = n.wal.Sync()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// openWAL opens the write-ahead log of the node and restores the last
+// snapshot it contains. It returns false when there was nothing to restore
+func (n *Node) openWAL() bool {
+	if WAL_DIR == "" {
+		return false
+	}
+	var restored bool = false
+	file, err := os.OpenFile(walPath(n.id), os.O_RDWR | os.O_CREATE, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var last walRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record walRecord
+		// a record truncated by a crash is ignored, the previous one is kept
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			last = record
+			restored = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if restored {
+		n.highestSeqNumber = Clock.NewLamport(last.HighestSeqNumber)
+		n.nbCS = last.NbCS
+		for name, l := range last.Locks {
+			n.locks[name] = l
+		}
+		log.Print("Node #", n.id, " restored from ", walPath(n.id), ": ", n)
+	}
+	n.wal = file
+	return restored
+}
+
+func (n *Node) init() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.highestSeqNumber = Clock.NewLamport(0)
+	n.locks = make(map[string]*LockState)
+	n.stop = make(chan bool)
+	if n.openWAL() == false {
+		n.nbCS = 0
+		n.persist()
+	}
+}
+
+// start runs the routines of the current incarnation of the node
+func (n *Node) start() {
+	n.mutex.Lock()
+	var stop chan bool = n.stop
+	n.mutex.Unlock()
+	for _, name := range LOCK_NAMES {
+		go n.requestCS(name, stop)
+	}
+	go n.waitForReplies(stop)
+}
+
+// halt stops the current incarnation of the node and wipes everything it had
+// in memory, only its write-ahead log survives
+func (n *Node) halt() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	close(n.stop)
+	if n.wal != nil {
+		n.wal.Close()
+		n.wal = nil
+	}
+	n.highestSeqNumber = Clock.NewLamport(0)
+	n.nbCS = 0
+	n.locks = nil
+	// a node crashing in a CS leaves it
+	globalMutex.Lock()
+	for name, holder := range holders {
+		if holder == n.id {
+			delete(holders, name)
 		}
 	}
+	globalMutex.Unlock()
 }
 
-func (n *Node) sendReply(destNodeId int) {
-	var content = fmt.Sprintf("REP%d", n.id)
-	log.Print("Node #", n.id, ", SENDING reply ", content, " to Node #", destNodeId)	
-	n.messages[destNodeId] <- content
+func (n *Node) crash() {
+	log.Print("Node #", n.id, " !!!!!!!!!!!!!!!!!!!!!!!!! CRASH")
+	n.halt()
+}
+
+func (n *Node) restart() {
+	log.Print("Node #", n.id, " !!!!!!!!!!!!!!!!!!!!!!!!! RESTART")
+	n.init()
+	n.start()
+}
+
+func (n *Node) crashAndRestart(crashAfter int, downtime time.Duration, wg *sync.WaitGroup) {
+	for currentIteration() < crashAfter {
+		time.Sleep(10 * time.Millisecond)
+	}
+	n.crash()
+	time.Sleep(downtime)
+	n.restart()
+	wg.Done()
 }
 
-func (n *Node) waitForReplies() {	
-	// log.Print("Node #", n.id," waitForReplies")	
+func (n *Node) waitForReplies(stop chan bool) {
+	// log.Print("Node #", n.id," waitForReplies")
 	for {
 		select {
+		case <-stop:
+			return
 		case msg := <-n.messages[n.id]:
-			if (strings.Contains(msg, "REQ")) {
-				// requester is the variable j in the paper
-				var requester, err = strconv.Atoi(msg[3:4])
-				if err != nil {
-					log.Fatal(err)
-				}
-				// k is seqNumber,
-				// k is the name of the variable in the paper
-				var k, err2 = strconv.Atoi(msg[4:])
-				if err2 != nil {
-					log.Fatal(err2)
-				}
-
-				if k > n.highestSeqNumber {
-					n.highestSeqNumber = k
-				}
-				var defer_it bool = n.isRequestingCS && ((k > n.seqNumber) || (k == n.seqNumber && requester > n.id))
-				if defer_it {
-					n.replyDeferred[requester] = true
-				} else {
-					n.sendReply(requester)
-				}
-			}  else if (strings.Contains(msg, "REP")) {
-				var sender, err = strconv.Atoi(msg[3:4])
-				if err != nil {
-					log.Fatal(err)
-				}
-				log.Print("Node #", n.id, ", RECEIVED reply from Node #", sender, ",", msg)
-				n.outstandingReplyCount --
+			n.mutex.Lock()
+			if stopped(stop) {
+				n.mutex.Unlock()
+				n.requeue(msg)
+				return
+			}
+			if msg.Type == ModelCheck.REPLY {
+				log.Print("Node #", n.id, ", RECEIVED ", msg.Message, " of lock ", msg.Lock)
+			}
+			var out []ModelCheck.Message
+			if msg.Type == ModelCheck.REPLY && n.locks[msg.Lock] == nil {
+				log.Print("Node #", n.id, ", reply from Node #", msg.From, " for lock ", msg.Lock, " not requested")
+			} else if msg.Type == ModelCheck.REQUEST || msg.Type == ModelCheck.REPLY {
+				out = n.step(msg.Lock, msg.Message)
+				n.persist()
 			} else {
-				log.Fatal("WTF")
+				log.Fatal("Node #", n.id, ", malformed message ", msg)
 			}
+			n.mutex.Unlock()
+			n.send(msg.Lock, out)
 		}
 	}
 	// log.Print(n)
 	// log.Print("Node #", n.id, " end waitForReplies")
 }
 
-func (n *Node) requestCS() {
+// requeue gives a message received by a crashed incarnation back to the
+// channel of the node, for the next incarnation
+func (n *Node) requeue(msg Message) {
+	go func() {
+		n.messages[n.id] <- msg
+	}()
+}
+
+// requestCS enters the CS of lock name again and again, until the
+// incarnation of stop crashes
+func (n *Node) requestCS(name string, stop chan bool) {
 	// log.Print("Node #", n.id, " requestCS")
 
 	for {
 		time.Sleep(100 * time.Millisecond)
-		// Mutex on shared variable
-		n.isRequestingCS = true
-		n.seqNumber = n.highestSeqNumber + 1
-		// end mutex on shared variable
-		n.outstandingReplyCount = NB_NODES - 1
-
-		for j := 0; j < NB_NODES; j ++ {
-			if (j != n.id) {
-				n.sendRequest(n.seqNumber, n.id, j)
-			}
+		n.mutex.Lock()
+		if stopped(stop) {
+			n.mutex.Unlock()
+			return
 		}
+		// A node restarted while requesting keeps waiting for the
+		// replies to its previous request
+		var out []ModelCheck.Message
+		if n.locks[name] == nil {
+			out = n.step(name, ModelCheck.Message{Type: ModelCheck.REQUEST_CS, From: n.id, To: n.id})
+			n.persist()
+		}
+		n.mutex.Unlock()
+		n.send(name, out)
 		for {
 			time.Sleep(100 * time.Millisecond)
-			if (n.outstandingReplyCount == 0) {
-				n.enterCS()
-				n.releaseCS()
+			n.mutex.Lock()
+			if stopped(stop) {
+				n.mutex.Unlock()
+				return
+			}
+			var granted bool = n.locks[name] != nil && n.locks[name].InCS()
+			n.mutex.Unlock()
+			if granted {
+				if n.enterCS(name, stop) == false {
+					return
+				}
+				n.releaseCS(name, stop)
 				break
 			}
-		} 
-	}	
-	// log.Print("Node #", n.id," END")	
+		}
+	}
+	// log.Print("Node #", n.id," END")
 }
 
 func (n *Node) RicartAgrawala(wg *sync.WaitGroup) {
 	log.Print("Node #", n.id)
 
-	go n.requestCS()
-	go n.waitForReplies()
+	n.start()
 	for {
 		time.Sleep(100 * time.Millisecond)
-		if CURRENT_ITERATION > NB_ITERATIONS {
+		if currentIteration() > NB_ITERATIONS {
 			break
 		}
 	}
 
-	log.Print("Node #", n.id," END after ", NB_ITERATIONS," CS entries")	
+	log.Print("Node #", n.id," END after ", NB_ITERATIONS," CS entries")
 	wg.Done()
 }
 
-func main() {
+// run runs the nodes until NB_ITERATIONS CS entries, crashing and restarting
+// crashNode if it is not -1. All the nodes are stopped at the end
+func run(crashNode int, crashAfter int, downtime time.Duration) {
 	var nodes = make([]Node, NB_NODES)
 	var wg sync.WaitGroup
-	var messages = make([]chan string, NB_NODES)
-	
+	var messages = make([]chan Message, NB_NODES)
+
 	log.Print("nb_process #", NB_NODES)
+	globalMutex.Lock()
+	CURRENT_ITERATION = 0
+	holders = make(map[string]int)
+	VIOLATIONS = 0
+	globalMutex.Unlock()
 
 	// Initialization
 	for i := 0; i < NB_NODES; i++ {
 		nodes[i].id = i
-		nodes[i].nbCS = 0 
-		nodes[i].seqNumber = 0
-		nodes[i].highestSeqNumber = 0
-		nodes[i].outstandingReplyCount = 0
-		nodes[i].isRequestingCS = false
-		nodes[i].replyDeferred = make([]bool, NB_NODES)
-		for j := 0; j < NB_NODES; j++ {
-			nodes[i].replyDeferred[j] = false
-		}
-
-		nodes[i].channel = messages[i]
-		messages[i] = make(chan string)
+		nodes[i].init()
+		messages[i] = make(chan Message)
 	}
 	for i := 0; i < NB_NODES; i++ {
 		nodes[i].messages = messages
@@ -233,9 +512,55 @@ func main() {
 		wg.Add(1)
 		go nodes[i].RicartAgrawala(&wg)
 	}
+	if crashNode >= 0 {
+		wg.Add(1)
+		go nodes[crashNode].crashAndRestart(crashAfter, downtime, &wg)
+	}
 	wg.Wait()
 	for i := 0; i < NB_NODES; i++ {
-		log.Print("Node #", nodes[i].id," entered CS ", nodes[i].nbCS," time")	
+		nodes[i].mutex.Lock()
+		log.Print("Node #", nodes[i].id," entered CS ", nodes[i].nbCS," time")
+		nodes[i].mutex.Unlock()
+		nodes[i].halt()
+	}
+}
+
+func main() {
+	locksPtr := flag.String("locks", strings.Join(LOCK_NAMES, ","), "comma separated names of the locks, requested in parallel by each node")
+	walDirPtr := flag.String("walDir", "", "directory of the write-ahead logs, disabled when empty")
+	crashNodePtr := flag.Int("crashNode", -1, "node to crash and restart during the run, -1 for none, requires -walDir")
+	crashAfterPtr := flag.Int("crashAfter", NB_ITERATIONS / 2, "number of CS entries before the crash")
+	downtimePtr := flag.Duration("downtime", 2 * time.Second, "time the crashed node stays down")
+	flag.Parse()
+
+	LOCK_NAMES = nil
+	var seen = make(map[string]bool)
+	for _, name := range strings.Split(*locksPtr, ",") {
+		if name == "" || strings.ContainsAny(name, " \t\n") || seen[name] {
+			log.Fatal("-locks: lock names must be distinct, non empty and without spaces, got ", *locksPtr)
+		}
+		seen[name] = true
+		LOCK_NAMES = append(LOCK_NAMES, name)
+	}
+	log.Print("locks ", LOCK_NAMES)
+	WAL_DIR = *walDirPtr
+	if WAL_DIR != "" {
+		err := os.MkdirAll(WAL_DIR, 0755)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *crashNodePtr >= NB_NODES {
+		log.Fatal("-crashNode must be lower than ", NB_NODES)
+	}
+	// without its log, a restarted node would forget the replies it defers
+	if *crashNodePtr >= 0 && WAL_DIR == "" {
+		log.Fatal("-crashNode requires -walDir")
+	}
+
+	run(*crashNodePtr, *crashAfterPtr, *downtimePtr)
+	if VIOLATIONS > 0 {
+		log.Fatal(VIOLATIONS, " mutual exclusion violations")
 	}
 }
 /*